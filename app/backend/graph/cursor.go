@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vault/internal/apperr"
+	"vault/internal/db"
+)
+
+// fileCursorVersion prefixes every encoded file cursor. Bump it when the
+// payload changes so cursors issued by an older server are rejected instead of
+// being misread.
+const fileCursorVersion = "f1"
+
+// encodeFileCursor turns a listing position into an opaque token. Clients must
+// pass it back unchanged.
+func encodeFileCursor(c db.FileCursor) string {
+	raw := fileCursorVersion + ":" + strconv.FormatInt(c.UploadedAt.UnixMicro(), 10) + ":" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeFileCursor parses a token from encodeFileCursor. A nil token yields a
+// nil cursor, i.e. the first page.
+func decodeFileCursor(token *string) (*db.FileCursor, error) {
+	if token == nil {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(*token)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, apperr.InvalidInput("invalid cursor")
+	}
+	if parts[0] != fileCursorVersion {
+		return nil, apperr.InvalidInput("cursor is from an unsupported format; restart from the first page")
+	}
+	micros, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid cursor")
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid cursor")
+	}
+	return &db.FileCursor{UploadedAt: time.UnixMicro(micros), ID: id}, nil
+}
@@ -1,19 +1,27 @@
 package graph
 
 import (
+	"errors"
+	"strings"
 	"time"
 	"vault/graph/model"
+	"vault/internal/apperr"
 	"vault/internal/db"
+	filesvc "vault/internal/files"
+	"vault/internal/webhooks"
+
+	"github.com/google/uuid"
 )
 
 func mapUser(u db.User) *model.User {
 	return &model.User{
-		ID:         u.ID.String(),
-		Email:      u.Email,
-		Name:       u.Name,
-		Role:       model.Role(u.Role),
-		QuotaBytes: int(u.QuotaBytes),
-		CreatedAt:  u.CreatedAt,
+		ID:          u.ID.String(),
+		Email:       u.Email,
+		Name:        u.Name,
+		Role:        model.Role(u.Role),
+		QuotaBytes:  int(u.QuotaBytes),
+		CreatedAt:   u.CreatedAt,
+		LastLoginAt: u.LastLoginAt,
 	}
 }
 
@@ -23,6 +31,10 @@ func mapFile(rec db.FileRecord, blob db.FileBlob, owner *model.User, deduped boo
 		md := blob.MimeDetected
 		detected = &md
 	}
+	declared := ""
+	if rec.MimeDeclared != nil {
+		declared = *rec.MimeDeclared
+	}
 	return &model.File{
 		ID:                rec.ID.String(),
 		Owner:             owner,
@@ -34,17 +46,342 @@ func mapFile(rec db.FileRecord, blob db.FileBlob, owner *model.User, deduped boo
 		DownloadCount:     int(rec.DownloadCount),
 		Deduped:           deduped,
 		Tags:              rec.Tags,
+		Width:             blob.Width,
+		Height:            blob.Height,
+		ExpiresAt:         rec.ExpiresAt,
+		MimeMismatch:      filesvc.MimeMismatch(rec.FilenameOriginal, declared, blob.MimeDetected),
+		Description:       rec.Description,
+		HiddenFromPublic:  rec.HiddenFromPublic,
 	}
 }
 
 func mapShare(s db.ShareRecord, file *model.File) *model.Share {
 	return &model.Share{
-		ID:         s.ID.String(),
-		File:       file,
-		Visibility: model.ShareVisibility(s.Visibility),
-		Token:      s.Token,
-		ExpiresAt:  s.ExpiresAt,
+		ID:               s.ID.String(),
+		File:             file,
+		Visibility:       model.ShareVisibility(s.Visibility),
+		Token:            s.Token,
+		ExpiresAt:        s.ExpiresAt,
+		NotifyOnDownload: s.NotifyOnDownload,
 	}
 }
 
 func toTimePtr(t *time.Time) *time.Time { return t }
+
+func mapFolder(f db.Folder) *model.Folder {
+	var parentID *string
+	if f.ParentID != nil {
+		pid := f.ParentID.String()
+		parentID = &pid
+	}
+	return &model.Folder{
+		ID:        f.ID.String(),
+		ParentID:  parentID,
+		Name:      f.Name,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+func mapStorageStats(original, deduped int64) *model.StorageStats {
+	savings := original - deduped
+	percent := 0.0
+	if original > 0 {
+		percent = float64(savings) / float64(original) * 100
+	}
+
+	return &model.StorageStats{
+		TotalUsageBytes:    int(deduped),
+		OriginalUsageBytes: int(original),
+		SavingsBytes:       int(savings),
+		SavingsPercent:     percent,
+	}
+}
+
+func mapFolderShare(s db.FolderShareRecord, folder *model.Folder) *model.FolderShare {
+	return &model.FolderShare{
+		ID:                s.ID.String(),
+		Folder:            folder,
+		Token:             s.Token,
+		ExpiresAt:         s.ExpiresAt,
+		PasswordProtected: s.PasswordHash != nil && *s.PasswordHash != "",
+	}
+}
+
+func mapShareGrant(g db.ShareGrant) *model.ShareGrant {
+	return &model.ShareGrant{
+		ID:           g.ID.String(),
+		FileID:       g.FileID.String(),
+		GranteeEmail: g.GranteeEmail,
+		Permission:   model.GrantPermission(g.Permission),
+		CreatedAt:    g.CreatedAt,
+	}
+}
+
+func mapFileAccess(a db.FileAccess) *model.FileAccess {
+	return &model.FileAccess{
+		ID:         a.ID.String(),
+		Kind:       a.Kind,
+		ShareToken: a.ShareToken,
+		IP:         a.IP,
+		UserAgent:  a.UserAgent,
+		AccessedAt: a.AccessedAt,
+	}
+}
+
+// toDBFileFilter converts the GraphQL filter input; a nil input yields a nil filter.
+func toDBFileFilter(filter *model.FileFilter) (*db.FileFilter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	dbFilter := &db.FileFilter{}
+	if filter.Search != nil {
+		dbFilter.Search = filter.Search
+	}
+	if len(filter.MimeTypes) > 0 {
+		dbFilter.MimeTypes = filter.MimeTypes
+	}
+	if filter.MinSize != nil {
+		min := int64(*filter.MinSize)
+		dbFilter.MinSize = &min
+	}
+	if filter.MaxSize != nil {
+		max := int64(*filter.MaxSize)
+		dbFilter.MaxSize = &max
+	}
+	if filter.MinDownloads != nil {
+		min := int64(*filter.MinDownloads)
+		dbFilter.MinDownloads = &min
+	}
+	if filter.MaxDownloads != nil {
+		max := int64(*filter.MaxDownloads)
+		dbFilter.MaxDownloads = &max
+	}
+	if len(filter.Tags) > 0 {
+		dbFilter.Tags = filter.Tags
+	}
+	if filter.UploaderName != nil {
+		name := strings.TrimSpace(*filter.UploaderName)
+		if name != "" {
+			dbFilter.UploaderName = &name
+		}
+	}
+	if filter.UploaderID != nil {
+		if uid, err := uuid.Parse(*filter.UploaderID); err == nil {
+			dbFilter.UploaderID = &uid
+		}
+	}
+	if filter.UploadedFrom != nil {
+		from := *filter.UploadedFrom
+		dbFilter.UploadedFrom = &from
+	}
+	if filter.UploadedTo != nil {
+		to := *filter.UploadedTo
+		dbFilter.UploadedTo = &to
+	}
+	if filter.FolderID != nil {
+		folderID, err := uuid.Parse(*filter.FolderID)
+		if err != nil {
+			return nil, apperr.InvalidInput("invalid folder id")
+		}
+		dbFilter.FolderID = &folderID
+		dbFilter.Recursive = filter.Recursive != nil && *filter.Recursive
+	}
+	return dbFilter, nil
+}
+
+func mapSavedSearch(s db.SavedSearch) *model.SavedSearch {
+	f := s.Filter
+	out := &model.SavedFileFilter{
+		Search:       f.Search,
+		Tags:         f.Tags,
+		MimeTypes:    f.MimeTypes,
+		UploadedFrom: f.UploadedFrom,
+		UploadedTo:   f.UploadedTo,
+	}
+	if f.MinSize != nil {
+		min := int(*f.MinSize)
+		out.MinSize = &min
+	}
+	if f.MaxSize != nil {
+		max := int(*f.MaxSize)
+		out.MaxSize = &max
+	}
+	if f.MinDownloads != nil {
+		min := int(*f.MinDownloads)
+		out.MinDownloads = &min
+	}
+	if f.MaxDownloads != nil {
+		max := int(*f.MaxDownloads)
+		out.MaxDownloads = &max
+	}
+	if f.FolderID != nil {
+		folderID := f.FolderID.String()
+		out.FolderID = &folderID
+		recursive := f.Recursive
+		out.Recursive = &recursive
+	}
+	return &model.SavedSearch{
+		ID:        s.ID.String(),
+		Name:      s.Name,
+		Filter:    out,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+// mapUploadFailure classifies a failed per-file upload result. Unexpected errors
+// are reported as INTERNAL without leaking their details.
+func mapUploadFailure(res filesvc.UploadResult) *model.UploadFailure {
+	failure := &model.UploadFailure{Filename: res.Filename, Reason: model.UploadFailureReasonInternal, Message: "upload failed"}
+	switch {
+	case errors.Is(res.Err, filesvc.ErrFileTooLarge):
+		failure.Reason, failure.Message = model.UploadFailureReasonTooLarge, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrQuotaExceeded):
+		failure.Reason, failure.Message = model.UploadFailureReasonQuotaExceeded, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrFileLimitReached):
+		failure.Reason, failure.Message = model.UploadFailureReasonFileLimitReached, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrExecutableBlocked):
+		failure.Reason, failure.Message = model.UploadFailureReasonExecutableBlocked, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrMimeMismatch):
+		failure.Reason, failure.Message = model.UploadFailureReasonMimeMismatch, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrSizeMismatch):
+		failure.Reason, failure.Message = model.UploadFailureReasonSizeMismatch, res.Err.Error()
+	case errors.Is(res.Err, filesvc.ErrInvalidUpload):
+		failure.Reason, failure.Message = model.UploadFailureReasonInvalidUpload, res.Err.Error()
+	}
+	return failure
+}
+
+func mapFileReport(r db.FileReport) *model.FileReport {
+	return &model.FileReport{
+		ID:         r.ID.String(),
+		FileID:     r.FileID.String(),
+		Reason:     model.ReportReason(r.Reason),
+		Note:       r.Note,
+		Status:     model.ReportStatus(r.Status),
+		ReporterIP: r.ReporterIP,
+		CreatedAt:  r.CreatedAt,
+		ResolvedAt: r.ResolvedAt,
+	}
+}
+
+// newFileConnection wraps one page of a listing; endCursor is nil on the last
+// page.
+func newFileConnection(nodes []*model.File, total, limit int, endCursor *string) *model.FileConnection {
+	return &model.FileConnection{
+		Nodes:       nodes,
+		TotalCount:  total,
+		Limit:       limit,
+		Truncated:   total > len(nodes),
+		EndCursor:   endCursor,
+		HasNextPage: endCursor != nil,
+	}
+}
+
+// pageEntries trims a listing fetched with limit+1 rows to limit. The extra row
+// only signals that another page follows; the returned cursor then points after
+// the last kept entry, and is nil otherwise.
+func pageEntries(entries []db.FileWithBlob, limit int) ([]db.FileWithBlob, *string) {
+	if len(entries) <= limit {
+		return entries, nil
+	}
+	entries = entries[:limit]
+	cursor := encodeFileCursor(db.CursorOf(entries[len(entries)-1].File))
+	return entries, &cursor
+}
+
+func mapLoginEvent(e db.LoginEvent) *model.LoginEvent {
+	return &model.LoginEvent{
+		ID:        e.ID.String(),
+		Provider:  e.Provider,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		CreatedAt: e.CreatedAt,
+	}
+}
+
+// webhookEvents maps GraphQL webhook events to the event names used in payloads.
+var webhookEvents = map[model.WebhookEvent]string{
+	model.WebhookEventFileUploaded:   webhooks.EventFileUploaded,
+	model.WebhookEventShareCreated:   webhooks.EventShareCreated,
+	model.WebhookEventFileDownloaded: webhooks.EventFileDownloaded,
+}
+
+func mapWebhookEvent(event string) model.WebhookEvent {
+	for key, name := range webhookEvents {
+		if name == event {
+			return key
+		}
+	}
+	return model.WebhookEvent(event)
+}
+
+func mapWebhook(h db.Webhook) *model.Webhook {
+	events := make([]model.WebhookEvent, 0, len(h.Events))
+	for _, event := range h.Events {
+		events = append(events, mapWebhookEvent(event))
+	}
+	return &model.Webhook{
+		ID:        h.ID.String(),
+		URL:       h.URL,
+		Events:    events,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func mapWebhookDelivery(d db.WebhookDelivery) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             d.ID.String(),
+		Event:          mapWebhookEvent(d.Event),
+		Status:         model.WebhookDeliveryStatus(d.Status),
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		Error:          d.LastError,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+		DeliveredAt:    d.DeliveredAt,
+	}
+}
+
+// maxFilesByIDs bounds how many ids one filesByIds query accepts.
+const maxFilesByIDs = 500
+
+// parseFileIDs parses client file IDs, rejecting the request on the first
+// malformed one.
+func parseFileIDs(raw []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(raw))
+	for _, id := range raw {
+		fileID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, apperr.InvalidInput("invalid file id")
+		}
+		ids = append(ids, fileID)
+	}
+	return ids, nil
+}
+
+func mapTagResults(results []filesvc.TagResult) []*model.TagFileResult {
+	out := make([]*model.TagFileResult, 0, len(results))
+	for _, res := range results {
+		item := &model.TagFileResult{FileID: res.FileID.String(), Ok: res.Err == nil}
+		if res.Err != nil {
+			code := apperr.Code(res.Err)
+			item.ErrorCode = &code
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func mapLinkResults(results []filesvc.LinkResult) []*model.FolderLinkResult {
+	out := make([]*model.FolderLinkResult, 0, len(results))
+	for _, res := range results {
+		item := &model.FolderLinkResult{FileID: res.FileID.String(), Ok: res.Err == nil}
+		if res.Err != nil {
+			code := apperr.Code(res.Err)
+			item.ErrorCode = &code
+		}
+		out = append(out, item)
+	}
+	return out
+}
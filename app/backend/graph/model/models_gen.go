@@ -9,21 +9,69 @@ import (
 	"time"
 )
 
+type ColdBlob struct {
+	Sha256          string     `json:"sha256"`
+	SizeBytes       int        `json:"sizeBytes"`
+	StoredSizeBytes int        `json:"storedSizeBytes"`
+	MimeDetected    string     `json:"mimeDetected"`
+	RefCount        int        `json:"refCount"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	LastAccessedAt  *time.Time `json:"lastAccessedAt,omitempty"`
+}
+
+type DedupSavings struct {
+	OriginalBytes   int     `json:"originalBytes"`
+	DedupedBytes    int     `json:"dedupedBytes"`
+	SavedBytes      int     `json:"savedBytes"`
+	SavingsPercent  float64 `json:"savingsPercent"`
+	SharedFileCount int     `json:"sharedFileCount"`
+}
+
 type DeletePayload struct {
 	Ok bool `json:"ok"`
 }
 
+type DuplicateFileGroup struct {
+	Sha256         string  `json:"sha256"`
+	SizeBytes      int     `json:"sizeBytes"`
+	Files          []*File `json:"files"`
+	RedundantBytes int     `json:"redundantBytes"`
+}
+
+type EmptyTrashPayload struct {
+	FilesDeleted   int  `json:"filesDeleted"`
+	OriginalBytes  int  `json:"originalBytes"`
+	ReclaimedBytes int  `json:"reclaimedBytes"`
+	BlobsDeleted   int  `json:"blobsDeleted"`
+	DryRun         bool `json:"dryRun"`
+}
+
 type File struct {
-	ID                string    `json:"id"`
-	Owner             *User     `json:"owner"`
-	FilenameOriginal  string    `json:"filenameOriginal"`
-	SizeBytesOriginal int       `json:"sizeBytesOriginal"`
-	MimeDeclared      *string   `json:"mimeDeclared,omitempty"`
-	MimeDetected      *string   `json:"mimeDetected,omitempty"`
-	UploadedAt        time.Time `json:"uploadedAt"`
-	DownloadCount     int       `json:"downloadCount"`
-	Deduped           bool      `json:"deduped"`
-	Tags              []string  `json:"tags"`
+	ID                string     `json:"id"`
+	Owner             *User      `json:"owner"`
+	FilenameOriginal  string     `json:"filenameOriginal"`
+	SizeBytesOriginal int        `json:"sizeBytesOriginal"`
+	MimeDeclared      *string    `json:"mimeDeclared,omitempty"`
+	MimeDetected      *string    `json:"mimeDetected,omitempty"`
+	UploadedAt        time.Time  `json:"uploadedAt"`
+	DownloadCount     int        `json:"downloadCount"`
+	Deduped           bool       `json:"deduped"`
+	Tags              []string   `json:"tags"`
+	Width             *int       `json:"width,omitempty"`
+	Height            *int       `json:"height,omitempty"`
+	ExpiresAt         *time.Time `json:"expiresAt,omitempty"`
+	MimeMismatch      bool       `json:"mimeMismatch"`
+	Description       *string    `json:"description,omitempty"`
+	HiddenFromPublic  bool       `json:"hiddenFromPublic"`
+}
+
+type FileAccess struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	ShareToken *string   `json:"shareToken,omitempty"`
+	IP         *string   `json:"ip,omitempty"`
+	UserAgent  *string   `json:"userAgent,omitempty"`
+	AccessedAt time.Time `json:"accessedAt"`
 }
 
 type FileBlobInfo struct {
@@ -33,8 +81,12 @@ type FileBlobInfo struct {
 }
 
 type FileConnection struct {
-	Nodes      []*File `json:"nodes"`
-	TotalCount int     `json:"totalCount"`
+	Nodes       []*File `json:"nodes"`
+	TotalCount  int     `json:"totalCount"`
+	Limit       int     `json:"limit"`
+	Truncated   bool    `json:"truncated"`
+	EndCursor   *string `json:"endCursor,omitempty"`
+	HasNextPage bool    `json:"hasNextPage"`
 }
 
 type FileFilter struct {
@@ -43,10 +95,88 @@ type FileFilter struct {
 	MimeTypes    []string   `json:"mimeTypes,omitempty"`
 	MinSize      *int       `json:"minSize,omitempty"`
 	MaxSize      *int       `json:"maxSize,omitempty"`
+	MinDownloads *int       `json:"minDownloads,omitempty"`
+	MaxDownloads *int       `json:"maxDownloads,omitempty"`
 	UploaderName *string    `json:"uploaderName,omitempty"`
 	UploaderID   *string    `json:"uploaderId,omitempty"`
 	UploadedFrom *time.Time `json:"uploadedFrom,omitempty"`
 	UploadedTo   *time.Time `json:"uploadedTo,omitempty"`
+	FolderID     *string    `json:"folderId,omitempty"`
+	Recursive    *bool      `json:"recursive,omitempty"`
+}
+
+type FileReport struct {
+	ID         string       `json:"id"`
+	FileID     string       `json:"fileId"`
+	Reason     ReportReason `json:"reason"`
+	Note       *string      `json:"note,omitempty"`
+	Status     ReportStatus `json:"status"`
+	ReporterIP *string      `json:"reporterIp,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	ResolvedAt *time.Time   `json:"resolvedAt,omitempty"`
+}
+
+type Folder struct {
+	ID           string        `json:"id"`
+	ParentID     *string       `json:"parentId,omitempty"`
+	Name         string        `json:"name"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+	StorageStats *StorageStats `json:"storageStats"`
+}
+
+type FolderContents struct {
+	Folder       *Folder   `json:"folder,omitempty"`
+	Folders      []*Folder `json:"folders"`
+	Files        []*File   `json:"files"`
+	EndCursor    *string   `json:"endCursor,omitempty"`
+	HasMoreFiles bool      `json:"hasMoreFiles"`
+}
+
+type FolderDeletePayload struct {
+	Ok             bool `json:"ok"`
+	FilesDeleted   int  `json:"filesDeleted"`
+	FoldersDeleted int  `json:"foldersDeleted"`
+}
+
+type FolderLinkResult struct {
+	FileID    string  `json:"fileId"`
+	Ok        bool    `json:"ok"`
+	ErrorCode *string `json:"errorCode,omitempty"`
+}
+
+type FolderShare struct {
+	ID                string     `json:"id"`
+	Folder            *Folder    `json:"folder"`
+	Token             string     `json:"token"`
+	ExpiresAt         *time.Time `json:"expiresAt,omitempty"`
+	PasswordProtected bool       `json:"passwordProtected"`
+}
+
+type FolderShareInput struct {
+	FolderID  string     `json:"folderId"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Password  *string    `json:"password,omitempty"`
+}
+
+type GrantInput struct {
+	FileID     string           `json:"fileId"`
+	Email      string           `json:"email"`
+	Permission *GrantPermission `json:"permission,omitempty"`
+}
+
+type LoginEvent struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	IP        *string   `json:"ip,omitempty"`
+	UserAgent *string   `json:"userAgent,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type MoveFileResult struct {
+	FileID    string  `json:"fileId"`
+	Ok        bool    `json:"ok"`
+	ErrorCode *string `json:"errorCode,omitempty"`
 }
 
 type Mutation struct {
@@ -55,38 +185,135 @@ type Mutation struct {
 type Query struct {
 }
 
+type RecentDownload struct {
+	File         *File     `json:"file"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+type RotateShareTokenPayload struct {
+	Share        *Share `json:"share"`
+	DownloadPath string `json:"downloadPath"`
+}
+
+type SavedFileFilter struct {
+	Search       *string    `json:"search,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	MimeTypes    []string   `json:"mimeTypes,omitempty"`
+	MinSize      *int       `json:"minSize,omitempty"`
+	MaxSize      *int       `json:"maxSize,omitempty"`
+	MinDownloads *int       `json:"minDownloads,omitempty"`
+	MaxDownloads *int       `json:"maxDownloads,omitempty"`
+	UploadedFrom *time.Time `json:"uploadedFrom,omitempty"`
+	UploadedTo   *time.Time `json:"uploadedTo,omitempty"`
+	FolderID     *string    `json:"folderId,omitempty"`
+	Recursive    *bool      `json:"recursive,omitempty"`
+}
+
+type SavedSearch struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Filter    *SavedFileFilter `json:"filter"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
 type Share struct {
-	ID         string          `json:"id"`
-	File       *File           `json:"file"`
-	Visibility ShareVisibility `json:"visibility"`
-	Token      *string         `json:"token,omitempty"`
-	ExpiresAt  *time.Time      `json:"expiresAt,omitempty"`
+	ID               string          `json:"id"`
+	File             *File           `json:"file"`
+	Visibility       ShareVisibility `json:"visibility"`
+	Token            *string         `json:"token,omitempty"`
+	ExpiresAt        *time.Time      `json:"expiresAt,omitempty"`
+	NotifyOnDownload bool            `json:"notifyOnDownload"`
+}
+
+type ShareGrant struct {
+	ID           string          `json:"id"`
+	FileID       string          `json:"fileId"`
+	GranteeEmail string          `json:"granteeEmail"`
+	Permission   GrantPermission `json:"permission"`
+	CreatedAt    time.Time       `json:"createdAt"`
 }
 
 type ShareInput struct {
-	FileID     string          `json:"fileId"`
-	Visibility ShareVisibility `json:"visibility"`
-	ExpiresAt  *time.Time      `json:"expiresAt,omitempty"`
+	FileID           string          `json:"fileId"`
+	Visibility       ShareVisibility `json:"visibility"`
+	ExpiresAt        *time.Time      `json:"expiresAt,omitempty"`
+	NotifyOnDownload *bool           `json:"notifyOnDownload,omitempty"`
 }
 
 type StorageStats struct {
-	TotalUsageBytes    int     `json:"totalUsageBytes"`
-	OriginalUsageBytes int     `json:"originalUsageBytes"`
-	SavingsBytes       int     `json:"savingsBytes"`
-	SavingsPercent     float64 `json:"savingsPercent"`
+	TotalUsageBytes         int     `json:"totalUsageBytes"`
+	OriginalUsageBytes      int     `json:"originalUsageBytes"`
+	SavingsBytes            int     `json:"savingsBytes"`
+	SavingsPercent          float64 `json:"savingsPercent"`
+	StoredUsageBytes        *int    `json:"storedUsageBytes,omitempty"`
+	CompressionSavingsBytes *int    `json:"compressionSavingsBytes,omitempty"`
+	QuotaBytes              *int    `json:"quotaBytes,omitempty"`
+	RemainingBytes          *int    `json:"remainingBytes,omitempty"`
+	NearingQuota            bool    `json:"nearingQuota"`
+}
+
+type StorageUsagePoint struct {
+	Day                time.Time `json:"day"`
+	OriginalUsageBytes int       `json:"originalUsageBytes"`
+	DedupedUsageBytes  int       `json:"dedupedUsageBytes"`
+}
+
+type TagFileResult struct {
+	FileID    string  `json:"fileId"`
+	Ok        bool    `json:"ok"`
+	ErrorCode *string `json:"errorCode,omitempty"`
+}
+
+type TrashUsage struct {
+	FileCount        int `json:"fileCount"`
+	OriginalBytes    int `json:"originalBytes"`
+	ReclaimableBytes int `json:"reclaimableBytes"`
+}
+
+type UploadFailure struct {
+	Filename string              `json:"filename"`
+	Reason   UploadFailureReason `json:"reason"`
+	Message  string              `json:"message"`
 }
 
 type UploadResult struct {
-	Files []*File `json:"files"`
+	Files    []*File          `json:"files"`
+	Failures []*UploadFailure `json:"failures"`
 }
 
 type User struct {
-	ID         string    `json:"id"`
-	Email      string    `json:"email"`
-	Name       *string   `json:"name,omitempty"`
-	Role       Role      `json:"role"`
-	QuotaBytes int       `json:"quotaBytes"`
-	CreatedAt  time.Time `json:"createdAt"`
+	ID          string     `json:"id"`
+	Email       string     `json:"email"`
+	Name        *string    `json:"name,omitempty"`
+	Role        Role       `json:"role"`
+	QuotaBytes  int        `json:"quotaBytes"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	LastLoginAt *time.Time `json:"lastLoginAt,omitempty"`
+}
+
+type Webhook struct {
+	ID        string         `json:"id"`
+	URL       string         `json:"url"`
+	Events    []WebhookEvent `json:"events"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	Event          WebhookEvent          `json:"event"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus *int                  `json:"responseStatus,omitempty"`
+	Error          *string               `json:"error,omitempty"`
+	NextAttemptAt  time.Time             `json:"nextAttemptAt"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+}
+
+type WebhookInput struct {
+	URL    string         `json:"url"`
+	Secret string         `json:"secret"`
+	Events []WebhookEvent `json:"events"`
 }
 
 type FileScope string
@@ -94,16 +321,18 @@ type FileScope string
 const (
 	FileScopeOwn    FileScope = "OWN"
 	FileScopePublic FileScope = "PUBLIC"
+	FileScopeShared FileScope = "SHARED"
 )
 
 var AllFileScope = []FileScope{
 	FileScopeOwn,
 	FileScopePublic,
+	FileScopeShared,
 }
 
 func (e FileScope) IsValid() bool {
 	switch e {
-	case FileScopeOwn, FileScopePublic:
+	case FileScopeOwn, FileScopePublic, FileScopeShared:
 		return true
 	}
 	return false
@@ -130,6 +359,139 @@ func (e FileScope) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+type GrantPermission string
+
+const (
+	GrantPermissionView     GrantPermission = "VIEW"
+	GrantPermissionDownload GrantPermission = "DOWNLOAD"
+)
+
+var AllGrantPermission = []GrantPermission{
+	GrantPermissionView,
+	GrantPermissionDownload,
+}
+
+func (e GrantPermission) IsValid() bool {
+	switch e {
+	case GrantPermissionView, GrantPermissionDownload:
+		return true
+	}
+	return false
+}
+
+func (e GrantPermission) String() string {
+	return string(e)
+}
+
+func (e *GrantPermission) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GrantPermission(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GrantPermission", str)
+	}
+	return nil
+}
+
+func (e GrantPermission) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportReason string
+
+const (
+	ReportReasonSpam       ReportReason = "SPAM"
+	ReportReasonMalware    ReportReason = "MALWARE"
+	ReportReasonIllegal    ReportReason = "ILLEGAL"
+	ReportReasonCopyright  ReportReason = "COPYRIGHT"
+	ReportReasonHarassment ReportReason = "HARASSMENT"
+	ReportReasonOther      ReportReason = "OTHER"
+)
+
+var AllReportReason = []ReportReason{
+	ReportReasonSpam,
+	ReportReasonMalware,
+	ReportReasonIllegal,
+	ReportReasonCopyright,
+	ReportReasonHarassment,
+	ReportReasonOther,
+}
+
+func (e ReportReason) IsValid() bool {
+	switch e {
+	case ReportReasonSpam, ReportReasonMalware, ReportReasonIllegal, ReportReasonCopyright, ReportReasonHarassment, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+func (e ReportReason) String() string {
+	return string(e)
+}
+
+func (e *ReportReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportReason", str)
+	}
+	return nil
+}
+
+func (e ReportReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "OPEN"
+	ReportStatusDismissed ReportStatus = "DISMISSED"
+	ReportStatusTakenDown ReportStatus = "TAKEN_DOWN"
+)
+
+var AllReportStatus = []ReportStatus{
+	ReportStatusOpen,
+	ReportStatusDismissed,
+	ReportStatusTakenDown,
+}
+
+func (e ReportStatus) IsValid() bool {
+	switch e {
+	case ReportStatusOpen, ReportStatusDismissed, ReportStatusTakenDown:
+		return true
+	}
+	return false
+}
+
+func (e ReportStatus) String() string {
+	return string(e)
+}
+
+func (e *ReportStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReportStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReportStatus", str)
+	}
+	return nil
+}
+
+func (e ReportStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type Role string
 
 const (
@@ -211,3 +573,142 @@ func (e *ShareVisibility) UnmarshalGQL(v interface{}) error {
 func (e ShareVisibility) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type UploadFailureReason string
+
+const (
+	UploadFailureReasonTooLarge          UploadFailureReason = "TOO_LARGE"
+	UploadFailureReasonQuotaExceeded     UploadFailureReason = "QUOTA_EXCEEDED"
+	UploadFailureReasonFileLimitReached  UploadFailureReason = "FILE_LIMIT_REACHED"
+	UploadFailureReasonExecutableBlocked UploadFailureReason = "EXECUTABLE_BLOCKED"
+	UploadFailureReasonMimeMismatch      UploadFailureReason = "MIME_MISMATCH"
+	UploadFailureReasonSizeMismatch      UploadFailureReason = "SIZE_MISMATCH"
+	UploadFailureReasonInvalidUpload     UploadFailureReason = "INVALID_UPLOAD"
+	UploadFailureReasonInternal          UploadFailureReason = "INTERNAL"
+)
+
+var AllUploadFailureReason = []UploadFailureReason{
+	UploadFailureReasonTooLarge,
+	UploadFailureReasonQuotaExceeded,
+	UploadFailureReasonFileLimitReached,
+	UploadFailureReasonExecutableBlocked,
+	UploadFailureReasonMimeMismatch,
+	UploadFailureReasonSizeMismatch,
+	UploadFailureReasonInvalidUpload,
+	UploadFailureReasonInternal,
+}
+
+func (e UploadFailureReason) IsValid() bool {
+	switch e {
+	case UploadFailureReasonTooLarge, UploadFailureReasonQuotaExceeded, UploadFailureReasonFileLimitReached, UploadFailureReasonExecutableBlocked, UploadFailureReasonMimeMismatch, UploadFailureReasonSizeMismatch, UploadFailureReasonInvalidUpload, UploadFailureReasonInternal:
+		return true
+	}
+	return false
+}
+
+func (e UploadFailureReason) String() string {
+	return string(e)
+}
+
+func (e *UploadFailureReason) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UploadFailureReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UploadFailureReason", str)
+	}
+	return nil
+}
+
+func (e UploadFailureReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusDelivered WebhookDeliveryStatus = "DELIVERED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusDelivered,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusDelivered, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEvent string
+
+const (
+	WebhookEventFileUploaded   WebhookEvent = "FILE_UPLOADED"
+	WebhookEventShareCreated   WebhookEvent = "SHARE_CREATED"
+	WebhookEventFileDownloaded WebhookEvent = "FILE_DOWNLOADED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventFileUploaded,
+	WebhookEventShareCreated,
+	WebhookEventFileDownloaded,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventFileUploaded, WebhookEventShareCreated, WebhookEventFileDownloaded:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -1,16 +1,49 @@
 package graph
 
 import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"vault/graph/model"
+	"vault/internal/apperr"
+	"vault/internal/auth"
 	"vault/internal/db"
 	"vault/internal/files"
+	"vault/internal/webhooks"
 )
 
 // Resolver wires application dependencies into GraphQL resolvers.
 type Resolver struct {
-	DB      *db.Pool
-	FileSvc *files.Service
+	DB       *db.Pool
+	FileSvc  *files.Service
+	Webhooks *webhooks.Dispatcher
+}
+
+func NewResolver(pool *db.Pool, fileSvc *files.Service, dispatcher *webhooks.Dispatcher) *Resolver {
+	return &Resolver{DB: pool, FileSvc: fileSvc, Webhooks: dispatcher}
 }
 
-func NewResolver(pool *db.Pool, fileSvc *files.Service) *Resolver {
-	return &Resolver{DB: pool, FileSvc: fileSvc}
+// requireAdmin loads the session user and rejects callers without the ADMIN
+// role. The role is read from the database so demotions apply immediately.
+func (r *Resolver) requireAdmin(ctx context.Context) (*db.User, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	userID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	user, err := r.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != string(model.RoleAdmin) {
+		return nil, apperr.ErrForbidden
+	}
+	return &user, nil
 }
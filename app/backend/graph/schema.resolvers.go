@@ -11,20 +11,49 @@ import (
 	"io"
 	"log"
 	"strings"
+	"time"
 	"vault/graph/model"
+	"vault/internal/apperr"
 	"vault/internal/auth"
 	"vault/internal/db"
 	filesvc "vault/internal/files"
+	"vault/internal/webhooks"
 
 	"github.com/99designs/gqlgen/graphql"
 	"github.com/google/uuid"
 )
 
+// StorageStats is the resolver for the storageStats field.
+func (r *folderResolver) StorageStats(ctx context.Context, obj *model.Folder) (*model.StorageStats, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	folderID, err := uuid.Parse(obj.ID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	original, deduped, err := r.DB.FolderStorageUsage(ctx, folderID, ownerID)
+	if err != nil {
+		log.Printf("folder storage stats failed: %v", err)
+		return nil, err
+	}
+
+	return mapStorageStats(original, deduped), nil
+}
+
 // UploadFiles is the resolver for the uploadFiles field.
 func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upload) (*model.UploadResult, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -39,9 +68,9 @@ func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upl
 	}
 
 	inputs := make([]filesvc.UploadInput, 0, len(files))
-	for _, upload := range files {
+	for i, upload := range files {
 		if upload == nil || upload.File == nil {
-			continue
+			return nil, apperr.InvalidInput(fmt.Sprintf("files[%d] has no file part; check the multipart map field", i))
 		}
 		inputs = append(inputs, filesvc.UploadInput{
 			Filename:     upload.Filename,
@@ -55,7 +84,7 @@ func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upl
 	}
 
 	if len(inputs) == 0 {
-		return &model.UploadResult{Files: []*model.File{}}, nil
+		return &model.UploadResult{Files: []*model.File{}, Failures: []*model.UploadFailure{}}, nil
 	}
 
 	results, err := r.FileSvc.Upload(ctx, owner, inputs)
@@ -66,19 +95,96 @@ func (r *mutationResolver) UploadFiles(ctx context.Context, files []*graphql.Upl
 
 	ownerModel := mapUser(owner)
 	out := make([]*model.File, 0, len(results))
+	failures := make([]*model.UploadFailure, 0)
 	for _, res := range results {
+		if res.Err != nil {
+			log.Printf("upload of %q failed: %v", res.Filename, res.Err)
+			failures = append(failures, mapUploadFailure(res))
+			continue
+		}
 		deduped := !res.IsNew && res.Blob.RefCount > 1
 		out = append(out, mapFile(res.File, res.Blob, ownerModel, deduped))
+		r.Webhooks.Publish(ownerID, webhooks.EventFileUploaded, webhooks.FileDataFor(res.File))
+	}
+
+	return &model.UploadResult{Files: out, Failures: failures}, nil
+}
+
+// UploadFromURL is the resolver for the uploadFromUrl field.
+func (r *mutationResolver) UploadFromURL(ctx context.Context, url string, filename *string) (*model.UploadResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if filename != nil {
+		name = *filename
+	}
+
+	res, err := r.FileSvc.UploadFromURL(ctx, owner, url, name)
+	if err != nil {
+		log.Printf("remote upload failed: %v", err)
+		return nil, err
+	}
+	r.Webhooks.Publish(ownerID, webhooks.EventFileUploaded, webhooks.FileDataFor(res.File))
+
+	deduped := !res.IsNew && res.Blob.RefCount > 1
+	return &model.UploadResult{
+		Files:    []*model.File{mapFile(res.File, res.Blob, mapUser(owner), deduped)},
+		Failures: []*model.UploadFailure{},
+	}, nil
+}
+
+// CreateFileFromContent is the resolver for the createFileFromContent field.
+func (r *mutationResolver) CreateFileFromContent(ctx context.Context, sha256 string, filename string) (*model.File, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	if strings.TrimSpace(filename) == "" {
+		return nil, apperr.InvalidInput("filename is required")
+	}
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.FileSvc.CreateFromContent(ctx, owner, sha256, filename)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("content not found")
+		}
+		log.Printf("create file from content failed: %v", err)
+		return nil, err
 	}
+	r.Webhooks.Publish(ownerID, webhooks.EventFileUploaded, webhooks.FileDataFor(res.File))
 
-	return &model.UploadResult{Files: out}, nil
+	return mapFile(res.File, res.Blob, mapUser(owner), res.Blob.RefCount > 1), nil
 }
 
 // DeleteFile is the resolver for the deleteFile field.
 func (r *mutationResolver) DeleteFile(ctx context.Context, id string) (*model.DeletePayload, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -88,7 +194,7 @@ func (r *mutationResolver) DeleteFile(ctx context.Context, id string) (*model.De
 
 	fileID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file id")
+		return nil, apperr.InvalidInput("invalid file id")
 	}
 
 	deleted, err := r.FileSvc.DeleteFile(ctx, fileID, ownerID)
@@ -106,11 +212,116 @@ func (r *mutationResolver) DeleteFile(ctx context.Context, id string) (*model.De
 	return &model.DeletePayload{Ok: true}, nil
 }
 
+// EmptyTrash is the resolver for the emptyTrash field.
+func (r *mutationResolver) EmptyTrash(ctx context.Context, dryRun *bool) (*model.EmptyTrashPayload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	result, err := r.FileSvc.EmptyTrash(ctx, ownerID, dryRun != nil && *dryRun)
+	if err != nil {
+		log.Printf("empty trash failed: %v", err)
+		return nil, err
+	}
+
+	return &model.EmptyTrashPayload{
+		FilesDeleted:   result.Files,
+		OriginalBytes:  int(result.OriginalBytes),
+		ReclaimedBytes: int(result.ReclaimedBytes),
+		BlobsDeleted:   result.Blobs,
+		DryRun:         result.DryRun,
+	}, nil
+}
+
+// SetFileExpiry is the resolver for the setFileExpiry field.
+func (r *mutationResolver) SetFileExpiry(ctx context.Context, id string, expiresAt *time.Time) (*model.File, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	fileID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	if err := r.FileSvc.SetFileExpiry(ctx, fileID, ownerID, expiresAt); err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("file not found")
+		}
+		return nil, err
+	}
+
+	entry, err := r.DB.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, apperr.NotFound("file not found")
+	}
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapFile(entry.File, entry.Blob, mapUser(owner), entry.Blob.RefCount > 1), nil
+}
+
+// SetFileDescription is the resolver for the setFileDescription field.
+func (r *mutationResolver) SetFileDescription(ctx context.Context, id string, description *string) (*model.File, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	fileID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	if err := r.FileSvc.SetFileDescription(ctx, fileID, ownerID, description); err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("file not found")
+		}
+		return nil, err
+	}
+
+	entry, err := r.DB.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, apperr.NotFound("file not found")
+	}
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapFile(entry.File, entry.Blob, mapUser(owner), entry.Blob.RefCount > 1), nil
+}
+
 // CreateShare is the resolver for the createShare field.
 func (r *mutationResolver) CreateShare(ctx context.Context, input model.ShareInput) (*model.Share, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -120,31 +331,37 @@ func (r *mutationResolver) CreateShare(ctx context.Context, input model.ShareInp
 
 	fileID, err := uuid.Parse(input.FileID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file id")
+		return nil, apperr.InvalidInput("invalid file id")
 	}
 
-	fileWithBlob, err := r.DB.GetFileWithBlob(ctx, fileID, ownerID)
+	fileWithBlob, err := r.DB.GetFileWithBlobPrimary(ctx, fileID, ownerID)
 	if err != nil {
 		return nil, err
 	}
 	if fileWithBlob == nil {
-		return nil, errors.New("file not found")
+		return nil, apperr.NotFound("file not found")
 	}
 
-	// Always ensure a token exists and is stable across visibility changes
-	var token *string
-	if existing, _ := r.DB.GetShareByFileID(ctx, fileID); existing != nil && existing.Token != nil && *existing.Token != "" {
-		token = existing.Token
+	notify := false
+	existing, _ := r.DB.GetShareByFileID(ctx, fileID)
+	if existing != nil {
+		notify = existing.NotifyOnDownload
 	}
-	if token == nil {
-		generated := uuid.NewString()
-		token = &generated
+	if input.NotifyOnDownload != nil {
+		notify = *input.NotifyOnDownload
 	}
 
-	shareRec, err := r.FileSvc.ShareFile(ctx, fileID, string(input.Visibility), token, toTimePtr(input.ExpiresAt))
+	shareRec, err := r.FileSvc.ShareFile(ctx, fileID, ownerID, string(input.Visibility), toTimePtr(input.ExpiresAt), notify)
 	if err != nil {
 		return nil, err
 	}
+	r.Webhooks.Publish(ownerID, webhooks.EventShareCreated, webhooks.ShareData{
+		ShareID:    shareRec.ID.String(),
+		FileID:     fileID.String(),
+		Filename:   fileWithBlob.File.FilenameOriginal,
+		Visibility: shareRec.Visibility,
+		ExpiresAt:  shareRec.ExpiresAt,
+	})
 
 	owner, err := r.DB.GetUserByID(ctx, ownerID)
 	if err != nil {
@@ -161,7 +378,7 @@ func (r *mutationResolver) CreateShare(ctx context.Context, input model.ShareInp
 func (r *mutationResolver) RevokeShare(ctx context.Context, id string) (*model.DeletePayload, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -171,10 +388,10 @@ func (r *mutationResolver) RevokeShare(ctx context.Context, id string) (*model.D
 
 	fileID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid file id")
+		return nil, apperr.InvalidInput("invalid file id")
 	}
 
-	fileWithBlob, err := r.DB.GetFileWithBlob(ctx, fileID, ownerID)
+	fileWithBlob, err := r.DB.GetFileWithBlobPrimary(ctx, fileID, ownerID)
 	if err != nil || fileWithBlob == nil {
 		return &model.DeletePayload{Ok: false}, nil
 	}
@@ -186,11 +403,11 @@ func (r *mutationResolver) RevokeShare(ctx context.Context, id string) (*model.D
 	return &model.DeletePayload{Ok: true}, nil
 }
 
-// Viewer is the resolver for the viewer field.
-func (r *queryResolver) Viewer(ctx context.Context) (*model.User, error) {
+// SetShareExpiry is the resolver for the setShareExpiry field.
+func (r *mutationResolver) SetShareExpiry(ctx context.Context, fileID string, expiresAt *time.Time) (*model.Share, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, nil
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -198,19 +415,41 @@ func (r *queryResolver) Viewer(ctx context.Context) (*model.User, error) {
 		return nil, fmt.Errorf("invalid session user: %w", err)
 	}
 
-	user, err := r.DB.GetUserByID(ctx, ownerID)
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	fileWithBlob, err := r.DB.GetFileWithBlobPrimary(ctx, parsedFileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil {
+		return nil, apperr.NotFound("file not found")
+	}
+
+	shareRec, err := r.FileSvc.UpdateShareExpiry(ctx, parsedFileID, ownerID, expiresAt)
 	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("share not found")
+		}
 		return nil, err
 	}
 
-	return mapUser(user), nil
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileModel := mapFile(fileWithBlob.File, fileWithBlob.Blob, mapUser(owner), fileWithBlob.Blob.RefCount > 1)
+	return mapShare(*shareRec, fileModel), nil
 }
 
-// Files is the resolver for the files field.
-func (r *queryResolver) Files(ctx context.Context, scope *model.FileScope, filter *model.FileFilter) (*model.FileConnection, error) {
+// RotateShareToken is the resolver for the rotateShareToken field.
+func (r *mutationResolver) RotateShareToken(ctx context.Context, fileID string) (*model.RotateShareTokenPayload, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -218,101 +457,45 @@ func (r *queryResolver) Files(ctx context.Context, scope *model.FileScope, filte
 		return nil, fmt.Errorf("invalid session user: %w", err)
 	}
 
-	var dbFilter *db.FileFilter
-	if filter != nil {
-		dbFilter = &db.FileFilter{}
-		if filter.Search != nil {
-			dbFilter.Search = filter.Search
-		}
-		if len(filter.MimeTypes) > 0 {
-			dbFilter.MimeTypes = filter.MimeTypes
-		}
-		if filter.MinSize != nil {
-			min := int64(*filter.MinSize)
-			dbFilter.MinSize = &min
-		}
-		if filter.MaxSize != nil {
-			max := int64(*filter.MaxSize)
-			dbFilter.MaxSize = &max
-		}
-		if len(filter.Tags) > 0 {
-			dbFilter.Tags = filter.Tags
-		}
-		if filter.UploaderName != nil {
-			name := strings.TrimSpace(*filter.UploaderName)
-			if name != "" {
-				dbFilter.UploaderName = &name
-			}
-		}
-		if filter.UploaderID != nil {
-			if uid, err := uuid.Parse(*filter.UploaderID); err == nil {
-				dbFilter.UploaderID = &uid
-			}
-		}
-		if filter.UploadedFrom != nil {
-			from := *filter.UploadedFrom
-			dbFilter.UploadedFrom = &from
-		}
-		if filter.UploadedTo != nil {
-			to := *filter.UploadedTo
-			dbFilter.UploadedTo = &to
-		}
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
 	}
 
-	// Default to OWN if not provided
-	effScope := model.FileScopeOwn
-	if scope != nil {
-		effScope = *scope
+	fileWithBlob, err := r.DB.GetFileWithBlobPrimary(ctx, parsedFileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil {
+		return nil, apperr.NotFound("file not found")
 	}
 
-	switch effScope {
-	case model.FileScopePublic:
-		entries, total, err := r.FileSvc.ListPublicFiles(ctx, dbFilter)
-		if err != nil {
-			log.Printf("public files query failed: %v", err)
-			return nil, err
-		}
-		nodes := make([]*model.File, 0, len(entries))
-		for _, entry := range entries {
-			uploader, err := r.DB.GetUserByID(ctx, entry.File.OwnerID)
-			if err != nil {
-				return nil, err
-			}
-			ownerModel := mapUser(uploader)
-			deduped := entry.Blob.RefCount > 1
-			nodes = append(nodes, mapFile(entry.File, entry.Blob, ownerModel, deduped))
-		}
-		return &model.FileConnection{Nodes: nodes, TotalCount: total}, nil
-	default: // OWN
-		// Ignore uploader filters in OWN scope
-		if dbFilter != nil {
-			dbFilter.UploaderID = nil
-			dbFilter.UploaderName = nil
-		}
-		entries, total, err := r.FileSvc.ListFiles(ctx, ownerID, dbFilter)
-		if err != nil {
-			log.Printf("files query failed: %v", err)
-			return nil, err
-		}
-		owner, err := r.DB.GetUserByID(ctx, ownerID)
-		if err != nil {
-			return nil, err
-		}
-		ownerModel := mapUser(owner)
-		nodes := make([]*model.File, 0, len(entries))
-		for _, entry := range entries {
-			deduped := entry.Blob.RefCount > 1
-			nodes = append(nodes, mapFile(entry.File, entry.Blob, ownerModel, deduped))
+	shareRec, err := r.FileSvc.RotateShareToken(ctx, parsedFileID, ownerID)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("share not found")
 		}
-		return &model.FileConnection{Nodes: nodes, TotalCount: total}, nil
+		log.Printf("rotate share token failed: %v", err)
+		return nil, err
 	}
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	fileModel := mapFile(fileWithBlob.File, fileWithBlob.Blob, mapUser(owner), fileWithBlob.Blob.RefCount > 1)
+	return &model.RotateShareTokenPayload{
+		Share:        mapShare(*shareRec, fileModel),
+		DownloadPath: "/shares/" + *shareRec.Token + "/download",
+	}, nil
 }
 
-// StorageStats is the resolver for the storageStats field.
-func (r *queryResolver) StorageStats(ctx context.Context) (*model.StorageStats, error) {
+// DeleteFolder is the resolver for the deleteFolder field.
+func (r *mutationResolver) DeleteFolder(ctx context.Context, id string) (*model.FolderDeletePayload, error) {
 	session, ok := auth.SessionFromContext(ctx)
 	if !ok {
-		return nil, errors.New("unauthenticated")
+		return nil, apperr.ErrUnauthenticated
 	}
 
 	ownerID, err := uuid.Parse(session.UserID)
@@ -320,31 +503,1368 @@ func (r *queryResolver) StorageStats(ctx context.Context) (*model.StorageStats,
 		return nil, fmt.Errorf("invalid session user: %w", err)
 	}
 
-	original, deduped, err := r.FileSvc.StorageStats(ctx, ownerID)
+	folderID, err := uuid.Parse(id)
 	if err != nil {
-		log.Printf("storage stats failed: %v", err)
-		return nil, err
+		return nil, apperr.InvalidInput("invalid folder id")
 	}
 
-	savings := original - deduped
-	percent := 0.0
-	if original > 0 {
-		percent = float64(savings) / float64(original) * 100
+	summary, err := r.FileSvc.DeleteFolderRecursive(ctx, folderID, ownerID)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return &model.FolderDeletePayload{Ok: false}, nil
+		}
+		log.Printf("delete folder failed: %v", err)
+		return nil, err
 	}
 
-	return &model.StorageStats{
-		TotalUsageBytes:    int(deduped),
-		OriginalUsageBytes: int(original),
-		SavingsBytes:       int(savings),
-		SavingsPercent:     percent,
+	return &model.FolderDeletePayload{
+		Ok:             true,
+		FilesDeleted:   summary.FilesDeleted,
+		FoldersDeleted: summary.FoldersDeleted,
 	}, nil
 }
 
+// ShareFolder is the resolver for the shareFolder field.
+func (r *mutationResolver) ShareFolder(ctx context.Context, input model.FolderShareInput) (*model.FolderShare, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	folderID, err := uuid.Parse(input.FolderID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	shareRec, err := r.FileSvc.ShareFolder(ctx, folderID, ownerID, toTimePtr(input.ExpiresAt), input.Password)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("folder not found")
+		}
+		return nil, err
+	}
+
+	folder, err := r.DB.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		return nil, apperr.NotFound("folder not found")
+	}
+
+	return mapFolderShare(*shareRec, mapFolder(*folder)), nil
+}
+
+// RevokeFolderShare is the resolver for the revokeFolderShare field.
+func (r *mutationResolver) RevokeFolderShare(ctx context.Context, id string) (*model.DeletePayload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	folderID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	removed, err := r.FileSvc.RevokeFolderShare(ctx, folderID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.DeletePayload{Ok: removed}, nil
+}
+
+// GrantFileAccess is the resolver for the grantFileAccess field.
+func (r *mutationResolver) GrantFileAccess(ctx context.Context, input model.GrantInput) (*model.ShareGrant, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	fileID, err := uuid.Parse(input.FileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	permission := ""
+	if input.Permission != nil {
+		permission = string(*input.Permission)
+	}
+
+	grant, err := r.FileSvc.GrantAccess(ctx, fileID, ownerID, input.Email, permission)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("file not found")
+		}
+		return nil, err
+	}
+
+	return mapShareGrant(*grant), nil
+}
+
+// RevokeFileAccess is the resolver for the revokeFileAccess field.
+func (r *mutationResolver) RevokeFileAccess(ctx context.Context, fileID string, email string) (*model.DeletePayload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	removed, err := r.FileSvc.RevokeAccess(ctx, parsedFileID, ownerID, email)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return &model.DeletePayload{Ok: false}, nil
+		}
+		return nil, err
+	}
+
+	return &model.DeletePayload{Ok: removed}, nil
+}
+
+// CreateSavedSearch is the resolver for the createSavedSearch field.
+func (r *mutationResolver) CreateSavedSearch(ctx context.Context, name string, filter model.FileFilter) (*model.SavedSearch, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, apperr.InvalidInput("name is required")
+	}
+
+	dbFilter, err := toDBFileFilter(&filter)
+	if err != nil {
+		return nil, err
+	}
+	// Saved searches only run against the owner's files
+	dbFilter.UploaderID = nil
+	dbFilter.UploaderName = nil
+
+	search, err := r.DB.InsertSavedSearch(ctx, ownerID, name, *dbFilter)
+	if err != nil {
+		log.Printf("create saved search failed: %v", err)
+		return nil, err
+	}
+
+	return mapSavedSearch(*search), nil
+}
+
+// DeleteSavedSearch is the resolver for the deleteSavedSearch field.
+func (r *mutationResolver) DeleteSavedSearch(ctx context.Context, id string) (*model.DeletePayload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	searchID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid saved search id")
+	}
+
+	removed, err := r.DB.DeleteSavedSearch(ctx, searchID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.DeletePayload{Ok: removed}, nil
+}
+
+// TakeDownFile is the resolver for the takeDownFile field.
+func (r *mutationResolver) TakeDownFile(ctx context.Context, fileID string) (*model.DeletePayload, error) {
+	admin, err := r.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	if err := r.FileSvc.TakeDownFile(ctx, parsedFileID, admin.ID); err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return &model.DeletePayload{Ok: false}, nil
+		}
+		log.Printf("take down failed: %v", err)
+		return nil, err
+	}
+
+	return &model.DeletePayload{Ok: true}, nil
+}
+
+// SetFileHiddenFromPublic is the resolver for the setFileHiddenFromPublic field.
+func (r *mutationResolver) SetFileHiddenFromPublic(ctx context.Context, fileID string, hidden bool) (*model.DeletePayload, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	if err := r.FileSvc.SetHiddenFromPublic(ctx, parsedFileID, hidden); err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return &model.DeletePayload{Ok: false}, nil
+		}
+		log.Printf("set file hidden failed: %v", err)
+		return nil, err
+	}
+
+	return &model.DeletePayload{Ok: true}, nil
+}
+
+// DismissReport is the resolver for the dismissReport field.
+func (r *mutationResolver) DismissReport(ctx context.Context, id string) (*model.FileReport, error) {
+	admin, err := r.requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reportID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid report id")
+	}
+
+	report, err := r.FileSvc.DismissReport(ctx, reportID, admin.ID)
+	if err != nil {
+		if errors.Is(err, filesvc.ErrNotFound) {
+			return nil, apperr.NotFound("open report not found")
+		}
+		return nil, err
+	}
+
+	return mapFileReport(*report), nil
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, userID string, role model.Role) (*model.User, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid user id")
+	}
+
+	user, err := r.DB.SetUserRole(ctx, uid, string(role))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, apperr.NotFound("user not found")
+	}
+
+	return mapUser(*user), nil
+}
+
+// MoveFiles is the resolver for the moveFiles field.
+func (r *mutationResolver) MoveFiles(ctx context.Context, fileIds []string, folderID *string) ([]*model.MoveFileResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	ids, err := parseFileIDs(fileIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var destID *uuid.UUID
+	if folderID != nil {
+		parsed, err := uuid.Parse(*folderID)
+		if err != nil {
+			return nil, apperr.InvalidInput("invalid folder id")
+		}
+		destID = &parsed
+	}
+
+	results, err := r.FileSvc.MoveFiles(ctx, ids, ownerID, destID)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrFolderNotFound) && !errors.Is(err, filesvc.ErrTooManyFiles) {
+			log.Printf("move files failed: %v", err)
+		}
+		return nil, err
+	}
+
+	out := make([]*model.MoveFileResult, 0, len(results))
+	for _, res := range results {
+		item := &model.MoveFileResult{FileID: res.FileID.String(), Ok: res.Err == nil}
+		if res.Err != nil {
+			code := apperr.Code(res.Err)
+			item.ErrorCode = &code
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// AddTagToFiles is the resolver for the addTagToFiles field.
+func (r *mutationResolver) AddTagToFiles(ctx context.Context, fileIds []string, tag string) ([]*model.TagFileResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	ids, err := parseFileIDs(fileIds)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := r.FileSvc.AddTagToFiles(ctx, ids, ownerID, tag)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrInvalidTag) && !errors.Is(err, filesvc.ErrTooManyFiles) {
+			log.Printf("add tag failed: %v", err)
+		}
+		return nil, err
+	}
+	return mapTagResults(results), nil
+}
+
+// RemoveTagFromFiles is the resolver for the removeTagFromFiles field.
+func (r *mutationResolver) RemoveTagFromFiles(ctx context.Context, fileIds []string, tag string) ([]*model.TagFileResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	ids, err := parseFileIDs(fileIds)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := r.FileSvc.RemoveTagFromFiles(ctx, ids, ownerID, tag)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrInvalidTag) && !errors.Is(err, filesvc.ErrTooManyFiles) {
+			log.Printf("remove tag failed: %v", err)
+		}
+		return nil, err
+	}
+	return mapTagResults(results), nil
+}
+
+// RenameTag is the resolver for the renameTag field.
+func (r *mutationResolver) RenameTag(ctx context.Context, oldTag string, newTag string) (int, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return 0, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	renamed, err := r.FileSvc.RenameTag(ctx, ownerID, oldTag, newTag)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrInvalidTag) {
+			log.Printf("rename tag failed: %v", err)
+		}
+		return 0, err
+	}
+	return renamed, nil
+}
+
+// LinkFilesToFolder is the resolver for the linkFilesToFolder field.
+func (r *mutationResolver) LinkFilesToFolder(ctx context.Context, fileIds []string, folderID string) ([]*model.FolderLinkResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	ids, err := parseFileIDs(fileIds)
+	if err != nil {
+		return nil, err
+	}
+	parsedFolderID, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	results, err := r.FileSvc.LinkFilesToFolder(ctx, ids, ownerID, parsedFolderID)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrFolderNotFound) && !errors.Is(err, filesvc.ErrTooManyFiles) && !errors.Is(err, filesvc.ErrFolderLabelsDisabled) {
+			log.Printf("link files failed: %v", err)
+		}
+		return nil, err
+	}
+	return mapLinkResults(results), nil
+}
+
+// UnlinkFilesFromFolder is the resolver for the unlinkFilesFromFolder field.
+func (r *mutationResolver) UnlinkFilesFromFolder(ctx context.Context, fileIds []string, folderID string) ([]*model.FolderLinkResult, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	ids, err := parseFileIDs(fileIds)
+	if err != nil {
+		return nil, err
+	}
+	parsedFolderID, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	results, err := r.FileSvc.UnlinkFilesFromFolder(ctx, ids, ownerID, parsedFolderID)
+	if err != nil {
+		if !errors.Is(err, filesvc.ErrFolderNotFound) && !errors.Is(err, filesvc.ErrTooManyFiles) && !errors.Is(err, filesvc.ErrFolderLabelsDisabled) {
+			log.Printf("unlink files failed: %v", err)
+		}
+		return nil, err
+	}
+	return mapLinkResults(results), nil
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input model.WebhookInput) (*model.Webhook, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	url := strings.TrimSpace(input.URL)
+	if err := webhooks.ValidateURL(url); err != nil {
+		return nil, apperr.InvalidInput(err.Error())
+	}
+	if len(input.Secret) < webhooks.MinSecretLength {
+		return nil, apperr.InvalidInput(fmt.Sprintf("webhook secret must be at least %d characters", webhooks.MinSecretLength))
+	}
+	if len(input.Events) == 0 {
+		return nil, apperr.InvalidInput("at least one webhook event is required")
+	}
+
+	events := make([]string, 0, len(input.Events))
+	seen := make(map[string]bool, len(input.Events))
+	for _, event := range input.Events {
+		name, ok := webhookEvents[event]
+		if !ok {
+			return nil, apperr.InvalidInput("unknown webhook event")
+		}
+		if !seen[name] {
+			seen[name] = true
+			events = append(events, name)
+		}
+	}
+
+	hook, err := r.DB.CreateWebhook(ctx, ownerID, url, input.Secret, events)
+	if err != nil {
+		log.Printf("create webhook failed: %v", err)
+		return nil, err
+	}
+	return mapWebhook(*hook), nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (*model.DeletePayload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	webhookID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid webhook id")
+	}
+
+	deleted, err := r.DB.DeleteWebhook(ctx, webhookID, ownerID)
+	if err != nil {
+		log.Printf("delete webhook failed: %v", err)
+		return nil, err
+	}
+	if !deleted {
+		return nil, apperr.NotFound("webhook not found")
+	}
+	return &model.DeletePayload{Ok: true}, nil
+}
+
+// Viewer is the resolver for the viewer field.
+func (r *queryResolver) Viewer(ctx context.Context) (*model.User, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	user, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapUser(user), nil
+}
+
+// Files is the resolver for the files field.
+func (r *queryResolver) Files(ctx context.Context, scope *model.FileScope, filter *model.FileFilter, after *string) (*model.FileConnection, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	dbFilter, err := toDBFileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeFileCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// Default to OWN if not provided
+	effScope := model.FileScopeOwn
+	if scope != nil {
+		effScope = *scope
+	}
+
+	switch effScope {
+	case model.FileScopePublic:
+		limit := r.FileSvc.PublicListLimit()
+		// One extra row tells whether another page follows.
+		entries, total, err := r.FileSvc.ListPublicFiles(ctx, dbFilter, cursor, limit+1)
+		if err != nil {
+			log.Printf("public files query failed: %v", err)
+			return nil, err
+		}
+		entries, endCursor := pageEntries(entries, limit)
+		nodes := make([]*model.File, 0, len(entries))
+		for _, entry := range entries {
+			uploader, err := r.DB.GetUserByID(ctx, entry.File.OwnerID)
+			if err != nil {
+				return nil, err
+			}
+			ownerModel := mapUser(uploader)
+			deduped := entry.Blob.RefCount > 1
+			nodes = append(nodes, mapFile(entry.File, entry.Blob, ownerModel, deduped))
+		}
+		return newFileConnection(nodes, total, limit, endCursor), nil
+	case model.FileScopeShared:
+		// Grants are listed by grant time, which file cursors cannot express.
+		if cursor != nil {
+			return nil, apperr.InvalidInput("after is not supported for SHARED listings")
+		}
+		// Files other users granted the viewer access to; uploader filters do not apply
+		if dbFilter != nil {
+			dbFilter.UploaderID = nil
+			dbFilter.UploaderName = nil
+			dbFilter.FolderID = nil
+		}
+		entries, total, err := r.FileSvc.ListSharedWithMe(ctx, ownerID, dbFilter)
+		if err != nil {
+			log.Printf("shared files query failed: %v", err)
+			return nil, err
+		}
+		nodes := make([]*model.File, 0, len(entries))
+		for _, entry := range entries {
+			grantor, err := r.DB.GetUserByID(ctx, entry.File.OwnerID)
+			if err != nil {
+				return nil, err
+			}
+			deduped := entry.Blob.RefCount > 1
+			nodes = append(nodes, mapFile(entry.File, entry.Blob, mapUser(grantor), deduped))
+		}
+		return newFileConnection(nodes, total, db.DefaultListLimit, nil), nil
+	default: // OWN
+		// Ignore uploader filters in OWN scope
+		if dbFilter != nil {
+			dbFilter.UploaderID = nil
+			dbFilter.UploaderName = nil
+		}
+		entries, total, err := r.FileSvc.ListFiles(ctx, ownerID, dbFilter, cursor, db.DefaultListLimit+1)
+		if err != nil {
+			log.Printf("files query failed: %v", err)
+			return nil, err
+		}
+		entries, endCursor := pageEntries(entries, db.DefaultListLimit)
+		owner, err := r.DB.GetUserByID(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		ownerModel := mapUser(owner)
+		nodes := make([]*model.File, 0, len(entries))
+		for _, entry := range entries {
+			deduped := entry.Blob.RefCount > 1
+			nodes = append(nodes, mapFile(entry.File, entry.Blob, ownerModel, deduped))
+		}
+		return newFileConnection(nodes, total, db.DefaultListLimit, endCursor), nil
+	}
+}
+
+// StorageStats is the resolver for the storageStats field.
+func (r *queryResolver) StorageStats(ctx context.Context) (*model.StorageStats, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	quota, err := r.FileSvc.QuotaStatus(ctx, ownerID)
+	if err != nil {
+		log.Printf("storage stats failed: %v", err)
+		return nil, err
+	}
+
+	stored, err := r.FileSvc.StoredBytes(ctx, ownerID)
+	if err != nil {
+		log.Printf("storage stats failed: %v", err)
+		return nil, err
+	}
+
+	stats := mapStorageStats(quota.OriginalBytes, quota.DedupedBytes)
+	storedBytes := int(stored)
+	compressionSavings := int(quota.DedupedBytes - stored)
+	stats.StoredUsageBytes = &storedBytes
+	stats.CompressionSavingsBytes = &compressionSavings
+	if quota.QuotaBytes > 0 {
+		quotaBytes, remaining := int(quota.QuotaBytes), int(*quota.RemainingBytes)
+		stats.QuotaBytes = &quotaBytes
+		stats.RemainingBytes = &remaining
+	}
+	stats.NearingQuota = quota.NearingQuota
+	return stats, nil
+}
+
+// DedupSavings is the resolver for the dedupSavings field.
+func (r *queryResolver) DedupSavings(ctx context.Context) (*model.DedupSavings, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	summary, err := r.FileSvc.DedupSummary(ctx, ownerID)
+	if err != nil {
+		log.Printf("dedup savings failed: %v", err)
+		return nil, err
+	}
+
+	stats := mapStorageStats(summary.OriginalBytes, summary.DedupedBytes)
+	return &model.DedupSavings{
+		OriginalBytes:   stats.OriginalUsageBytes,
+		DedupedBytes:    stats.TotalUsageBytes,
+		SavedBytes:      stats.SavingsBytes,
+		SavingsPercent:  stats.SavingsPercent,
+		SharedFileCount: summary.SharedFiles,
+	}, nil
+}
+
+// DuplicateFiles is the resolver for the duplicateFiles field.
+func (r *queryResolver) DuplicateFiles(ctx context.Context) ([]*model.DuplicateFileGroup, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	groups, err := r.DB.FindDuplicateFiles(ctx, ownerID)
+	if err != nil {
+		log.Printf("duplicate files query failed: %v", err)
+		return nil, err
+	}
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	ownerModel := mapUser(owner)
+
+	out := make([]*model.DuplicateFileGroup, 0, len(groups))
+	for _, group := range groups {
+		nodes := make([]*model.File, 0, len(group.Files))
+		for _, file := range group.Files {
+			nodes = append(nodes, mapFile(file, group.Blob, ownerModel, group.Blob.RefCount > 1))
+		}
+		out = append(out, &model.DuplicateFileGroup{
+			Sha256:         group.Blob.Sha256,
+			SizeBytes:      int(group.Blob.SizeBytes),
+			Files:          nodes,
+			RedundantBytes: int(group.Blob.SizeBytes) * (len(group.Files) - 1),
+		})
+	}
+	return out, nil
+}
+
+// FilesByIds is the resolver for the filesByIds field.
+func (r *queryResolver) FilesByIds(ctx context.Context, ids []string) ([]*model.File, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	if len(ids) > maxFilesByIDs {
+		return nil, filesvc.ErrTooManyFiles
+	}
+	fileIDs, err := parseFileIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.DB.GetFilesByIDs(ctx, ownerID, fileIDs)
+	if err != nil {
+		log.Printf("files by ids query failed: %v", err)
+		return nil, err
+	}
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	ownerModel := mapUser(owner)
+
+	out := make([]*model.File, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, mapFile(entry.File, entry.Blob, ownerModel, entry.Blob.RefCount > 1))
+	}
+	return out, nil
+}
+
+// TrashUsage is the resolver for the trashUsage field.
+func (r *queryResolver) TrashUsage(ctx context.Context) (*model.TrashUsage, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	usage, err := r.DB.TrashUsage(ctx, ownerID)
+	if err != nil {
+		log.Printf("trash usage query failed: %v", err)
+		return nil, err
+	}
+
+	return &model.TrashUsage{
+		FileCount:        usage.Files,
+		OriginalBytes:    int(usage.OriginalBytes),
+		ReclaimableBytes: int(usage.ReclaimableBytes),
+	}, nil
+}
+
+// StorageUsageHistory is the resolver for the storageUsageHistory field.
+func (r *queryResolver) StorageUsageHistory(ctx context.Context, from time.Time, to time.Time) ([]*model.StorageUsagePoint, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	if to.Before(from) {
+		return nil, apperr.InvalidInput("from must not be after to")
+	}
+
+	snapshots, err := r.FileSvc.StorageUsageHistory(ctx, ownerID, from, to)
+	if err != nil {
+		log.Printf("storage usage history failed: %v", err)
+		return nil, err
+	}
+
+	points := make([]*model.StorageUsagePoint, 0, len(snapshots))
+	for _, snap := range snapshots {
+		points = append(points, &model.StorageUsagePoint{
+			Day:                snap.Day,
+			OriginalUsageBytes: int(snap.OriginalBytes),
+			DedupedUsageBytes:  int(snap.DedupedBytes),
+		})
+	}
+	return points, nil
+}
+
+// FolderPath is the resolver for the folderPath field.
+func (r *queryResolver) FolderPath(ctx context.Context, id string) ([]*model.Folder, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	folderID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid folder id")
+	}
+
+	path, err := r.DB.FolderPath(ctx, folderID, ownerID)
+	if err != nil {
+		log.Printf("folder path failed: %v", err)
+		return nil, err
+	}
+	if path == nil {
+		return nil, apperr.NotFound("folder not found")
+	}
+
+	out := make([]*model.Folder, 0, len(path))
+	for _, folder := range path {
+		out = append(out, mapFolder(folder))
+	}
+	return out, nil
+}
+
+// FolderContents is the resolver for the folderContents field.
+func (r *queryResolver) FolderContents(ctx context.Context, folderID *string, first *int, after *string) (*model.FolderContents, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	out := &model.FolderContents{}
+	var parentID *uuid.UUID
+	if folderID != nil {
+		parsed, err := uuid.Parse(*folderID)
+		if err != nil {
+			return nil, apperr.InvalidInput("invalid folder id")
+		}
+		folder, err := r.DB.GetFolderByID(ctx, parsed)
+		if err != nil {
+			return nil, err
+		}
+		if folder == nil || folder.OwnerID != ownerID {
+			return nil, apperr.NotFound("folder not found")
+		}
+		parentID = &parsed
+		out.Folder = mapFolder(*folder)
+	}
+
+	cursor, err := decodeFileCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	max := 50
+	if first != nil && *first > 0 {
+		max = min(*first, db.DefaultListLimit)
+	}
+
+	folders, err := r.DB.ListFolders(ctx, ownerID, parentID)
+	if err != nil {
+		log.Printf("folder contents query failed: %v", err)
+		return nil, err
+	}
+	// One extra row tells whether another page follows.
+	entries, err := r.FileSvc.ListFolderFiles(ctx, ownerID, parentID, cursor, max+1)
+	if err != nil {
+		log.Printf("folder contents query failed: %v", err)
+		return nil, err
+	}
+	entries, out.EndCursor = pageEntries(entries, max)
+	out.HasMoreFiles = out.EndCursor != nil
+
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	ownerModel := mapUser(owner)
+
+	out.Folders = make([]*model.Folder, 0, len(folders))
+	for _, folder := range folders {
+		out.Folders = append(out.Folders, mapFolder(folder))
+	}
+	out.Files = make([]*model.File, 0, len(entries))
+	for _, entry := range entries {
+		out.Files = append(out.Files, mapFile(entry.File, entry.Blob, ownerModel, entry.Blob.RefCount > 1))
+	}
+	return out, nil
+}
+
+// FileGrants is the resolver for the fileGrants field.
+func (r *queryResolver) FileGrants(ctx context.Context, fileID string) ([]*model.ShareGrant, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	fileWithBlob, err := r.DB.GetFileWithBlob(ctx, parsedFileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil {
+		return nil, apperr.NotFound("file not found")
+	}
+
+	grants, err := r.DB.ListShareGrants(ctx, parsedFileID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*model.ShareGrant, 0, len(grants))
+	for _, grant := range grants {
+		out = append(out, mapShareGrant(grant))
+	}
+	return out, nil
+}
+
+// FileAccessLog is the resolver for the fileAccessLog field.
+func (r *queryResolver) FileAccessLog(ctx context.Context, fileID string, limit *int) ([]*model.FileAccess, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	parsedFileID, err := uuid.Parse(fileID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid file id")
+	}
+
+	max := 50
+	if limit != nil && *limit > 0 && *limit <= 500 {
+		max = *limit
+	}
+
+	entries, err := r.DB.FileAccessLog(ctx, parsedFileID, ownerID, max)
+	if err != nil {
+		log.Printf("file access log failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.FileAccess, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, mapFileAccess(entry))
+	}
+	return out, nil
+}
+
+// RecentDownloads is the resolver for the recentDownloads field.
+func (r *queryResolver) RecentDownloads(ctx context.Context, limit *int) ([]*model.RecentDownload, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	userID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	max := 20
+	if limit != nil && *limit > 0 && *limit < 100 {
+		max = *limit
+	}
+
+	entries, err := r.DB.RecentDownloads(ctx, userID, max)
+	if err != nil {
+		log.Printf("recent downloads query failed: %v", err)
+		return nil, err
+	}
+
+	owners := make(map[uuid.UUID]*model.User)
+	out := make([]*model.RecentDownload, 0, len(entries))
+	for _, entry := range entries {
+		owner, ok := owners[entry.File.OwnerID]
+		if !ok {
+			user, err := r.DB.GetUserByID(ctx, entry.File.OwnerID)
+			if err != nil {
+				return nil, err
+			}
+			owner = mapUser(user)
+			owners[entry.File.OwnerID] = owner
+		}
+		out = append(out, &model.RecentDownload{
+			File:         mapFile(entry.File, entry.Blob, owner, entry.Blob.RefCount > 1),
+			DownloadedAt: entry.DownloadedAt,
+		})
+	}
+	return out, nil
+}
+
+// SavedSearches is the resolver for the savedSearches field.
+func (r *queryResolver) SavedSearches(ctx context.Context) ([]*model.SavedSearch, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	searches, err := r.DB.ListSavedSearches(ctx, ownerID)
+	if err != nil {
+		log.Printf("saved searches query failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.SavedSearch, 0, len(searches))
+	for _, search := range searches {
+		out = append(out, mapSavedSearch(search))
+	}
+	return out, nil
+}
+
+// RunSavedSearch is the resolver for the runSavedSearch field.
+func (r *queryResolver) RunSavedSearch(ctx context.Context, id string) (*model.FileConnection, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	searchID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid saved search id")
+	}
+
+	search, err := r.DB.GetSavedSearch(ctx, searchID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if search == nil {
+		return nil, apperr.NotFound("saved search not found")
+	}
+
+	entries, total, err := r.FileSvc.ListFiles(ctx, ownerID, &search.Filter, nil, db.DefaultListLimit)
+	if err != nil {
+		log.Printf("saved search query failed: %v", err)
+		return nil, err
+	}
+	owner, err := r.DB.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	ownerModel := mapUser(owner)
+	nodes := make([]*model.File, 0, len(entries))
+	for _, entry := range entries {
+		deduped := entry.Blob.RefCount > 1
+		nodes = append(nodes, mapFile(entry.File, entry.Blob, ownerModel, deduped))
+	}
+	return newFileConnection(nodes, total, db.DefaultListLimit, nil), nil
+}
+
+// FileReports is the resolver for the fileReports field.
+func (r *queryResolver) FileReports(ctx context.Context, status *model.ReportStatus, limit *int) ([]*model.FileReport, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	var statusFilter *string
+	if status != nil {
+		value := string(*status)
+		statusFilter = &value
+	}
+	max := 100
+	if limit != nil && *limit > 0 && *limit < max {
+		max = *limit
+	}
+
+	reports, err := r.DB.ListFileReports(ctx, statusFilter, max)
+	if err != nil {
+		log.Printf("file reports query failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.FileReport, 0, len(reports))
+	for _, report := range reports {
+		out = append(out, mapFileReport(report))
+	}
+	return out, nil
+}
+
+// ColdBlobs is the resolver for the coldBlobs field.
+func (r *queryResolver) ColdBlobs(ctx context.Context, limit *int) ([]*model.ColdBlob, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	max := 100
+	if limit != nil && *limit > 0 && *limit < max {
+		max = *limit
+	}
+
+	blobs, err := r.DB.ListColdBlobs(ctx, max)
+	if err != nil {
+		log.Printf("cold blobs query failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.ColdBlob, 0, len(blobs))
+	for _, b := range blobs {
+		out = append(out, &model.ColdBlob{
+			Sha256:          b.Sha256,
+			SizeBytes:       int(b.SizeBytes),
+			StoredSizeBytes: int(b.StoredSizeBytes),
+			MimeDetected:    b.MimeDetected,
+			RefCount:        b.RefCount,
+			CreatedAt:       b.CreatedAt,
+			LastAccessedAt:  b.LastAccessedAt,
+		})
+	}
+	return out, nil
+}
+
+// BlobOwnerCount is the resolver for the blobOwnerCount field.
+func (r *queryResolver) BlobOwnerCount(ctx context.Context, sha256 string) (int, error) {
+	if _, err := r.requireAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	hash, err := filesvc.NormalizeHash(sha256)
+	if err != nil {
+		return 0, err
+	}
+	blob, err := r.DB.GetBlobByHash(ctx, hash)
+	if err != nil {
+		log.Printf("blob owner count failed: %v", err)
+		return 0, err
+	}
+	if blob == nil {
+		return 0, apperr.NotFound("blob not found")
+	}
+
+	owners, err := r.DB.BlobOwnerCount(ctx, blob.ID)
+	if err != nil {
+		log.Printf("blob owner count failed: %v", err)
+		return 0, err
+	}
+	return owners, nil
+}
+
+// LoginHistory is the resolver for the loginHistory field.
+func (r *queryResolver) LoginHistory(ctx context.Context, userID *string, limit *int) ([]*model.LoginEvent, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	targetID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+	if userID != nil && *userID != session.UserID {
+		if _, err := r.requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+		targetID, err = uuid.Parse(*userID)
+		if err != nil {
+			return nil, apperr.InvalidInput("invalid user id")
+		}
+	}
+
+	max := 50
+	if limit != nil && *limit > 0 && *limit < max {
+		max = *limit
+	}
+
+	events, err := r.DB.ListLoginEvents(ctx, targetID, max)
+	if err != nil {
+		log.Printf("login history query failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.LoginEvent, 0, len(events))
+	for _, event := range events {
+		out = append(out, mapLoginEvent(event))
+	}
+	return out, nil
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	hooks, err := r.DB.ListWebhooks(ctx, ownerID)
+	if err != nil {
+		log.Printf("list webhooks failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		out = append(out, mapWebhook(hook))
+	}
+	return out, nil
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID string, limit *int) ([]*model.WebhookDelivery, error) {
+	session, ok := auth.SessionFromContext(ctx)
+	if !ok {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session user: %w", err)
+	}
+
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, apperr.InvalidInput("invalid webhook id")
+	}
+
+	hook, err := r.DB.GetWebhook(ctx, id, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if hook == nil {
+		return nil, apperr.NotFound("webhook not found")
+	}
+
+	max := 50
+	if limit != nil && *limit > 0 && *limit < max {
+		max = *limit
+	}
+
+	deliveries, err := r.DB.ListWebhookDeliveries(ctx, hook.ID, max)
+	if err != nil {
+		log.Printf("webhook deliveries query failed: %v", err)
+		return nil, err
+	}
+
+	out := make([]*model.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		out = append(out, mapWebhookDelivery(d))
+	}
+	return out, nil
+}
+
+// Folder returns FolderResolver implementation.
+func (r *Resolver) Folder() FolderResolver { return &folderResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+type folderResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
@@ -5,34 +5,73 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 
 	"vault/internal/auth"
 	"vault/internal/config"
 	"vault/internal/db"
 	"vault/internal/files"
 	httpserver "vault/internal/http"
+	"vault/internal/jobs"
 	"vault/internal/storage"
+	"vault/internal/webhooks"
 )
 
 // Application wires together config, database connections, and HTTP server.
 type Application struct {
-	cfg    config.Config
-	dbPool *db.Pool
-	srv    *httpserver.Server
+	cfg      config.Config
+	dbPool   *db.Pool
+	fileSvc  *files.Service
+	webhooks *webhooks.Dispatcher
+	srv      *httpserver.Server
+	jobsCtx  context.Context
+	stopJobs context.CancelFunc
 }
 
 func NewApplication(ctx context.Context, cfg config.Config) (*Application, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	pool, err := db.NewPool(ctx, cfg.SupabaseDBURL)
 	if err != nil {
 		return nil, err
 	}
+	pool.SetQueryTimeout(cfg.DBQueryTimeout)
+	pool.SetDefaultQuotas(cfg.DefaultUserQuotaBytes, cfg.RoleQuotaBytes)
+	if cfg.SupabaseDBReplicaURL != "" {
+		if err := pool.UseReplica(ctx, cfg.SupabaseDBReplicaURL); err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("read replica: %w", err)
+		}
+	}
 
 	if cfg.SupabaseURL == "" || cfg.SupabaseServiceRoleKey == "" {
 		return nil, errors.New("supabase storage is not configured")
 	}
 
 	storageClient := storage.NewSupabaseClient(cfg.SupabaseURL, cfg.StorageBucket, cfg.SupabaseServiceRoleKey)
-	fileSvc := files.NewService(pool, storageClient, cfg.MaxUploadBytes)
+	fileSvc := files.NewService(pool, storageClient, files.Options{
+		MaxUploadBytes:      cfg.MaxUploadBytes,
+		DedupWindow:         cfg.UploadDedupWindow,
+		RemoteFetchTimeout:  cfg.RemoteFetchTimeout,
+		ChunkedDedup:        cfg.ChunkedDedup,
+		Compression:         cfg.BlobCompression,
+		BlockExecutables:    cfg.BlockExecutables,
+		PublicListLimit:     int(cfg.PublicListLimit),
+		RoleQuotaBytes:      cfg.RoleQuotaBytes,
+		StrictMIME:          cfg.StrictMIME,
+		UploadConcurrency:   cfg.UploadConcurrency,
+		StorageKeyPrefix:    cfg.StorageKeyPrefix,
+		MaxFilesPerUser:     int(cfg.MaxFilesPerUser),
+		MaxShareExpiry:      cfg.MaxShareExpiry,
+		ReportHideThreshold: int(cfg.ReportHideThreshold),
+		VerifyUploads:       cfg.VerifyUploads,
+		FolderLabels:        cfg.FolderLabels,
+		MaxUploadFiles:      cfg.MaxUploadFiles,
+		MimeUploadLimits:    cfg.MimeUploadLimits,
+		MimeSampleBytes:     cfg.MimeSampleBytes,
+	})
 
 	oauth, err := auth.NewGoogleOAuth(cfg)
 	if err != nil {
@@ -40,21 +79,80 @@ func NewApplication(ctx context.Context, cfg config.Config) (*Application, error
 	}
 
 	jwtMgr := auth.NewJWTManager(cfg.JWTSecret, cfg.SessionTTL)
-	srv := httpserver.NewServer(cfg, pool, fileSvc, oauth, jwtMgr)
+	persistedQueries, err := httpserver.LoadPersistedQueries(cfg.GraphQLPersistedQueriesFile)
+	if err != nil {
+		return nil, fmt.Errorf("persisted queries: %w", err)
+	}
+	if cfg.GraphQLPersistedOnly && len(persistedQueries) == 0 {
+		return nil, errors.New("GRAPHQL_PERSISTED_ONLY requires a non-empty GRAPHQL_PERSISTED_QUERIES_FILE")
+	}
+
+	// Webhook URLs are user-supplied, so deliveries may only reach public
+	// addresses outside dev mode.
+	webhookClient := files.RemoteClient()
+	if cfg.DevMode {
+		webhookClient = &http.Client{}
+	}
+	dispatcher := webhooks.NewDispatcher(pool, webhookClient, cfg.WebhookTimeout, cfg.WebhookMaxAttempts)
+
+	srv := httpserver.NewServer(cfg, pool, fileSvc, dispatcher, oauth, jwtMgr, persistedQueries)
+
+	jobsCtx, stopJobs := context.WithCancel(ctx)
 
 	return &Application{
-		cfg:    cfg,
-		dbPool: pool,
-		srv:    srv,
+		cfg:      cfg,
+		dbPool:   pool,
+		fileSvc:  fileSvc,
+		webhooks: dispatcher,
+		srv:      srv,
+		jobsCtx:  jobsCtx,
+		stopJobs: stopJobs,
 	}, nil
 }
 
 func (a *Application) Start() error {
+	a.startJobs()
 	log.Printf("connected to Supabase Postgres, starting HTTP server on :%s", a.cfg.Port)
 	return a.srv.Start()
 }
 
+// startJobs schedules the periodic jobs. Each job runs on one replica per
+// interval: a run takes a Postgres advisory lock and is skipped when job_runs
+// shows another replica ran the job within the interval. Jobs stop on Shutdown.
+func (a *Application) startJobs() {
+	runner := jobs.NewRunner(a.dbPool)
+	runner.Every(a.jobsCtx, "file-expiry", a.cfg.FileExpirySweepInterval, func(ctx context.Context) error {
+		deleted, err := a.fileSvc.SweepExpiredFiles(ctx)
+		if deleted > 0 {
+			log.Printf("expired %d files", deleted)
+		}
+		return err
+	})
+	runner.Every(a.jobsCtx, "storage-usage-snapshot", a.cfg.StorageSnapshotInterval, func(ctx context.Context) error {
+		recorded, err := a.fileSvc.SnapshotStorageUsage(ctx)
+		if recorded > 0 {
+			log.Printf("recorded storage usage for %d users", recorded)
+		}
+		return err
+	})
+	runner.Every(a.jobsCtx, "storage-gc", a.cfg.StorageGCInterval, func(ctx context.Context) error {
+		deleted, err := a.fileSvc.SweepStorageGC(ctx)
+		if deleted > 0 {
+			log.Printf("deleted %d queued storage objects", deleted)
+		}
+		return err
+	})
+	runner.Every(a.jobsCtx, "webhook-delivery", a.cfg.WebhookDeliveryInterval, func(ctx context.Context) error {
+		_, err := a.webhooks.DeliverPending(ctx)
+		return err
+	})
+	runner.Every(a.jobsCtx, "stored-bytes-gauge", a.cfg.MetricsRefreshInterval, a.fileSvc.RefreshStoredBytesGauge)
+}
+
 func (a *Application) Shutdown(ctx context.Context) {
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
 	if a.dbPool != nil {
 		a.dbPool.Close()
 	}
@@ -0,0 +1,101 @@
+// Package apperr maps application errors to stable machine-readable codes shared
+// by the GraphQL and REST APIs.
+package apperr
+
+import (
+	"errors"
+
+	"vault/internal/db"
+	"vault/internal/files"
+	"vault/internal/storage"
+)
+
+// Error codes exposed to API clients.
+const (
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeForbidden            = "FORBIDDEN"
+	CodeNotFound             = "NOT_FOUND"
+	CodeConflict             = "CONFLICT"
+	CodeBadRequest           = "BAD_REQUEST"
+	CodeQuotaExceeded        = "QUOTA_EXCEEDED"
+	CodeFileTooLarge         = "FILE_TOO_LARGE"
+	CodeExecutableBlocked    = "EXECUTABLE_BLOCKED"
+	CodePasswordRequired     = "PASSWORD_REQUIRED"
+	CodePasswordInvalid      = "PASSWORD_INVALID"
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	CodeRateLimited          = "RATE_LIMITED"
+	CodeTimeout              = "TIMEOUT"
+	CodeStorageUnavailable   = "STORAGE_UNAVAILABLE"
+	CodeInternal             = "INTERNAL"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+	ErrRateLimited     = errors.New("rate limit exceeded")
+)
+
+// InvalidInput is a client error whose text is the message shown to the caller.
+type InvalidInput string
+
+func (e InvalidInput) Error() string { return string(e) }
+
+// NotFound reports a missing resource with a resource-specific message.
+type NotFound string
+
+func (e NotFound) Error() string { return string(e) }
+
+// Code returns the code for err, falling back to CodeInternal.
+func Code(err error) string {
+	var invalid InvalidInput
+	var notFound NotFound
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrUnauthenticated):
+		return CodeUnauthorized
+	case errors.Is(err, ErrForbidden):
+		return CodeForbidden
+	case errors.Is(err, ErrRateLimited):
+		return CodeRateLimited
+	case errors.Is(err, files.ErrNotFound), errors.Is(err, db.ErrFolderNotFound), errors.As(err, &notFound):
+		return CodeNotFound
+	case errors.Is(err, files.ErrQuotaExceeded), errors.Is(err, files.ErrFileLimitReached):
+		return CodeQuotaExceeded
+	case errors.Is(err, files.ErrFileTooLarge):
+		return CodeFileTooLarge
+	case errors.Is(err, files.ErrExecutableBlocked):
+		return CodeExecutableBlocked
+	case errors.Is(err, files.ErrSharePasswordRequired):
+		return CodePasswordRequired
+	case errors.Is(err, files.ErrSharePasswordInvalid):
+		return CodePasswordInvalid
+	case errors.Is(err, files.ErrNotPreviewable), errors.Is(err, files.ErrMimeMismatch):
+		return CodeUnsupportedMediaType
+	case errors.Is(err, db.ErrLastAdmin), errors.Is(err, db.ErrFolderNameConflict):
+		return CodeConflict
+	case errors.Is(err, db.ErrQueryTimeout):
+		return CodeTimeout
+	case errors.Is(err, storage.ErrUnavailable):
+		return CodeStorageUnavailable
+	case errors.As(err, &invalid),
+		errors.Is(err, db.ErrInvalidRole),
+		errors.Is(err, files.ErrInvalidGrant),
+		errors.Is(err, files.ErrInvalidReport),
+		errors.Is(err, files.ErrTooManyFiles),
+		errors.Is(err, files.ErrSizeMismatch),
+		errors.Is(err, files.ErrDescriptionTooLong),
+		errors.Is(err, files.ErrInvalidHash),
+		errors.Is(err, files.ErrInvalidShareExpiry),
+		errors.Is(err, files.ErrInvalidFileExpiry),
+		errors.Is(err, files.ErrInvalidTag),
+		errors.Is(err, files.ErrFolderLabelsDisabled),
+		errors.Is(err, files.ErrInvalidUpload),
+		errors.Is(err, files.ErrTooManyUploads),
+		errors.Is(err, files.ErrInvalidRemoteURL),
+		errors.Is(err, files.ErrBlockedAddress):
+		return CodeBadRequest
+	default:
+		return CodeInternal
+	}
+}
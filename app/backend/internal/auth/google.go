@@ -20,6 +20,9 @@ const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
 type GoogleOAuth struct {
 	config *oauth2.Config
 	http   *http.Client
+	// authOpts are the access type and prompt sent with every authorization
+	// request.
+	authOpts []oauth2.AuthCodeOption
 }
 
 // GoogleUser represents the subset of Google profile fields we rely on.
@@ -40,6 +43,14 @@ func NewGoogleOAuth(cfg config.Config) (*GoogleOAuth, error) {
 		redirect = fmt.Sprintf("http://localhost:%s/auth/google/callback", cfg.Port)
 	}
 
+	authOpts := []oauth2.AuthCodeOption{oauth2.AccessTypeOnline}
+	if cfg.GoogleAccessType == "offline" {
+		authOpts = []oauth2.AuthCodeOption{oauth2.AccessTypeOffline}
+	}
+	if prompt := strings.Join(strings.Fields(cfg.GooglePrompt), " "); prompt != "" {
+		authOpts = append(authOpts, oauth2.SetAuthURLParam("prompt", prompt))
+	}
+
 	return &GoogleOAuth{
 		config: &oauth2.Config{
 			ClientID:     cfg.GoogleClientID,
@@ -52,13 +63,14 @@ func NewGoogleOAuth(cfg config.Config) (*GoogleOAuth, error) {
 			},
 			Endpoint: google.Endpoint,
 		},
-		http: http.DefaultClient,
+		http:     http.DefaultClient,
+		authOpts: authOpts,
 	}, nil
 }
 
 // AuthCodeURL returns the Google authorization URL for the provided state token.
 func (g *GoogleOAuth) AuthCodeURL(state string) string {
-	return g.config.AuthCodeURL(state, oauth2.AccessTypeOnline)
+	return g.config.AuthCodeURL(state, g.authOpts...)
 }
 
 // Exchange verifies the OAuth code and retrieves basic profile information.
@@ -1,53 +1,368 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Port                   string
-	FrontendURL            string
-	JWTSecret              string
-	SessionCookieName      string
-	SessionTTL             time.Duration
-	RateLimitRPS           float64
-	DefaultUserQuotaBytes  int64
-	MaxUploadBytes         int64
+	Port              string
+	FrontendURL       string
+	JWTSecret         string
+	SessionCookieName string
+	SessionTTL        time.Duration
+	// CookieDomain sets Domain on session and OAuth state cookies so they are
+	// shared across subdomains; empty keeps them host-only.
+	CookieDomain string
+	// CookieSameSite is "lax", "strict" or "none". Empty derives it from
+	// FrontendURL: None for https frontends, Lax otherwise.
+	CookieSameSite string
+	// RateLimitRPS limits anonymous callers per IP; AuthenticatedRateLimitRPS
+	// limits signed-in users per user ID.
+	RateLimitRPS              float64
+	AuthenticatedRateLimitRPS float64
+	// RateLimitAlgorithm is "token_bucket" (default, tolerates short bursts) or
+	// "sliding_window" (hard cap of RateLimitRPS*RateLimitWindow per window).
+	RateLimitAlgorithm string
+	RateLimitWindow    time.Duration
+	// PublicDownloadRateLimitRPS limits public and share downloads per IP, and
+	// ShareTokenRateLimitRPS per share token, independently of the API limiters.
+	// Zero disables either.
+	PublicDownloadRateLimitRPS float64
+	ShareTokenRateLimitRPS     float64
+	DefaultUserQuotaBytes      int64
+	// RoleQuotaBytes overrides DefaultUserQuotaBytes per role, e.g. "ADMIN=0"
+	// for unlimited admins. Applied when a user is created.
+	RoleQuotaBytes     map[string]int64
+	MaxUploadBytes     int64
+	UploadDedupWindow  time.Duration
+	RemoteFetchTimeout time.Duration
+	// DownloadBytesPerSec caps public and share downloads; zero means unlimited.
+	DownloadBytesPerSec int64
+	// OwnerDownloadBytesPerSec caps authenticated downloads; zero means unlimited.
+	OwnerDownloadBytesPerSec int64
+	MaxConcurrentDownloads   int
+	// MaxConcurrentUploads caps simultaneous multipart upload requests per user;
+	// zero means unlimited.
+	MaxConcurrentUploads int
+	// HotlinkProtection rejects public/share downloads whose Referer host is not
+	// the frontend or one of HotlinkAllowedDomains.
+	HotlinkProtection      bool
+	HotlinkAllowedDomains  []string
+	HotlinkAllowNoReferrer bool
+	// ChunkedDedup enables content-defined chunking for newly stored blobs.
+	ChunkedDedup bool
+	// BlobCompression gzips compressible blobs before they are stored.
+	BlobCompression bool
+	// PublicAPIURL is the externally reachable base URL of this API, used when
+	// building absolute links. Derived from the request when empty.
+	PublicAPIURL string
+	// BlockExecutables rejects uploads whose content is a native executable or script.
+	BlockExecutables bool
+	// FileExpirySweepInterval is how often expired files are deleted; zero disables the sweep.
+	FileExpirySweepInterval time.Duration
+	// StorageSnapshotInterval is how often the daily storage usage snapshot is
+	// attempted; only the first run of each UTC day writes.
+	StorageSnapshotInterval time.Duration
+	// MetricsRefreshInterval is how often gauges backed by database queries are refreshed.
+	MetricsRefreshInterval time.Duration
+	// DevMode relaxes Validate for local development: problems are logged
+	// instead of preventing startup.
+	DevMode bool
+	// DBQueryTimeout bounds each database statement; zero disables the limit.
+	DBQueryTimeout time.Duration
+	// ReportRateLimitRPS limits abuse report submissions per IP.
+	ReportRateLimitRPS float64
+	// PreviewMaxBytes caps the text returned by the preview endpoints.
+	PreviewMaxBytes int64
+	// LoginFailureLimit failed OAuth callbacks from one IP within
+	// LoginFailureWindow block that IP from the auth endpoints for
+	// LoginBlockDuration. Zero disables the check.
+	LoginFailureLimit  int
+	LoginFailureWindow time.Duration
+	LoginBlockDuration time.Duration
+	// RateLimitExemptAdmins skips request rate limiting for ADMIN sessions.
+	RateLimitExemptAdmins bool
+	// PublicListLimit caps public listings (hard maximum 1000).
+	PublicListLimit int64
+	// GraphQLAPQCacheSize bounds the automatic persisted query cache.
+	GraphQLAPQCacheSize int64
+	// GraphQLPersistedQueriesFile is a JSON manifest of sha256 hash to query text
+	// that pre-registers the frontend's operations.
+	GraphQLPersistedQueriesFile string
+	// GraphQLPersistedOnly rejects any operation not listed in the manifest.
+	GraphQLPersistedOnly bool
+	// StrictMIME rejects uploads whose extension, declared MIME and detected MIME
+	// disagree; otherwise mismatches are only flagged on the file.
+	StrictMIME bool
+	// UploadConcurrency bounds parallel per-file work within one upload batch.
+	UploadConcurrency int
+	// WebhookDeliveryInterval is how often queued webhook deliveries are sent;
+	// zero disables delivery.
+	WebhookDeliveryInterval time.Duration
+	// WebhookTimeout bounds each delivery attempt.
+	WebhookTimeout time.Duration
+	// WebhookMaxAttempts is how many times a delivery is tried before it is
+	// marked failed.
+	WebhookMaxAttempts int
+	// SMTPHost enables outgoing email (share download notifications) when set.
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of outgoing email.
+	SMTPFrom string
+	// ShareNotifyInterval is the minimum gap between two download notifications
+	// for the same share.
+	ShareNotifyInterval time.Duration
+	// ShareNotifyIncludeIP adds the downloader's IP address to notifications.
+	ShareNotifyIncludeIP bool
+	// AllowShareFilenameOverride lets ?filename= rename share and grant
+	// downloads; owners can always rename their own downloads.
+	AllowShareFilenameOverride bool
+	// StorageKeyPrefix namespaces new objects within STORAGE_BUCKET.
+	StorageKeyPrefix string
+	// StorageGCInterval is how often queued storage deletes are retried; zero
+	// disables the retries.
+	StorageGCInterval time.Duration
+	// MaxFilesPerUser caps how many live files a user may store; zero means
+	// unlimited.
+	MaxFilesPerUser int64
+	// MaxShareExpiry is the furthest in the future a share may expire; zero
+	// means no limit.
+	MaxShareExpiry time.Duration
+	// ReportHideThreshold hides a file from the public gallery once it has this
+	// many open reports; zero disables automatic hiding.
+	ReportHideThreshold int64
+	// VerifyUploads re-downloads each new storage object after upload and
+	// rejects the upload when it does not match.
+	VerifyUploads bool
+	// FolderLabels lets a file appear in several folders besides its home
+	// folder.
+	FolderLabels bool
+	// MaxUploadFiles bounds how many files one uploadFiles request may carry;
+	// zero means no limit.
+	MaxUploadFiles int
+	// MimeUploadLimits overrides MaxUploadBytes by detected MIME type or
+	// category, e.g. "image=20971520,video=524288000,application/pdf=10485760".
+	MimeUploadLimits map[string]int64
+	// MimeSampleBytes is how much of each upload MIME detection inspects; zip
+	// based office formats need a few KiB to be recognised.
+	MimeSampleBytes        int
 	SupabaseURL            string
 	SupabaseAnonKey        string
 	SupabaseServiceRoleKey string
 	SupabaseDBURL          string
-	StorageBucket          string
-	RedisURL               string
-	OAuthRedirectURL       string
-	GoogleClientID         string
-	GoogleClientSecret     string
+	// SupabaseDBReplicaURL points heavy read queries at a read replica; empty
+	// means all queries use SupabaseDBURL.
+	SupabaseDBReplicaURL string
+	StorageBucket        string
+	RedisURL             string
+	OAuthRedirectURL     string
+	GoogleClientID       string
+	GoogleClientSecret   string
+	// GoogleAccessType is "online" or "offline"; offline asks Google for a
+	// refresh token.
+	GoogleAccessType string
+	// GooglePrompt is passed as the prompt parameter, e.g. "select_account" to
+	// always show the account chooser; empty leaves it to Google.
+	GooglePrompt string
 }
 
 func Load() Config {
 	return Config{
-		Port:                   getEnv("PORT", "8080"),
-		FrontendURL:            getEnv("FRONTEND_URL", "http://localhost:3000"),
-		JWTSecret:              getEnv("JWT_SECRET", "change-me"),
-		SessionCookieName:      getEnv("SESSION_COOKIE_NAME", "vault_session"),
-		SessionTTL:             getDuration("SESSION_TTL", 24*time.Hour),
-		RateLimitRPS:           getFloat("RATE_LIMIT_RPS", 2),
-		DefaultUserQuotaBytes:  getInt("DEFAULT_USER_QUOTA_BYTES", 10485760),
-		MaxUploadBytes:         getInt("MAX_UPLOAD_BYTES", 10_485_760),
-		SupabaseURL:            os.Getenv("SUPABASE_URL"),
-		SupabaseAnonKey:        os.Getenv("SUPABASE_ANON_KEY"),
-		SupabaseServiceRoleKey: os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
-		SupabaseDBURL:          os.Getenv("SUPABASE_DB_URL"),
-		StorageBucket:          getEnv("STORAGE_BUCKET", "blobs"),
-		RedisURL:               getEnv("REDIS_URL", "redis://redis:6379"),
-		OAuthRedirectURL:       os.Getenv("OAUTH_REDIRECT_URL"),
-		GoogleClientID:         os.Getenv("GOOGLE_CLIENT_ID"),
-		GoogleClientSecret:     os.Getenv("GOOGLE_CLIENT_SECRET"),
+		Port:                        getEnv("PORT", "8080"),
+		FrontendURL:                 getEnv("FRONTEND_URL", "http://localhost:3000"),
+		JWTSecret:                   getEnv("JWT_SECRET", defaultJWTSecret),
+		SessionCookieName:           getEnv("SESSION_COOKIE_NAME", "vault_session"),
+		SessionTTL:                  getDuration("SESSION_TTL", 24*time.Hour),
+		CookieDomain:                os.Getenv("COOKIE_DOMAIN"),
+		CookieSameSite:              strings.ToLower(os.Getenv("COOKIE_SAMESITE")),
+		RateLimitRPS:                getFloat("RATE_LIMIT_RPS", 2),
+		AuthenticatedRateLimitRPS:   getFloat("AUTH_RATE_LIMIT_RPS", 10),
+		RateLimitAlgorithm:          getEnv("RATE_LIMIT_ALGORITHM", "token_bucket"),
+		RateLimitWindow:             getDuration("RATE_LIMIT_WINDOW", time.Minute),
+		PublicDownloadRateLimitRPS:  getFloat("PUBLIC_DOWNLOAD_RATE_LIMIT_RPS", 5),
+		ShareTokenRateLimitRPS:      getFloat("SHARE_TOKEN_RATE_LIMIT_RPS", 0),
+		DefaultUserQuotaBytes:       getInt("DEFAULT_USER_QUOTA_BYTES", 10485760),
+		RoleQuotaBytes:              getIntMap("ROLE_QUOTA_BYTES"),
+		MaxUploadBytes:              getInt("MAX_UPLOAD_BYTES", 10_485_760),
+		UploadDedupWindow:           getDuration("UPLOAD_DEDUP_WINDOW", 10*time.Minute),
+		RemoteFetchTimeout:          getDuration("REMOTE_FETCH_TIMEOUT", 30*time.Second),
+		DownloadBytesPerSec:         getInt("DOWNLOAD_BYTES_PER_SEC", 0),
+		OwnerDownloadBytesPerSec:    getInt("OWNER_DOWNLOAD_BYTES_PER_SEC", 0),
+		MaxConcurrentDownloads:      int(getInt("MAX_CONCURRENT_DOWNLOADS", 4)),
+		MaxConcurrentUploads:        int(getInt("MAX_CONCURRENT_UPLOADS", 2)),
+		HotlinkProtection:           getBool("HOTLINK_PROTECTION", false),
+		HotlinkAllowedDomains:       getList("HOTLINK_ALLOWED_DOMAINS"),
+		HotlinkAllowNoReferrer:      getBool("HOTLINK_ALLOW_NO_REFERRER", true),
+		ChunkedDedup:                getBool("CHUNKED_DEDUP", false),
+		BlobCompression:             getBool("BLOB_COMPRESSION", true),
+		PublicAPIURL:                os.Getenv("PUBLIC_API_URL"),
+		BlockExecutables:            getBool("BLOCK_EXECUTABLES", false),
+		FileExpirySweepInterval:     getDuration("FILE_EXPIRY_SWEEP_INTERVAL", 5*time.Minute),
+		StorageSnapshotInterval:     getDuration("STORAGE_SNAPSHOT_INTERVAL", time.Hour),
+		MetricsRefreshInterval:      getDuration("METRICS_REFRESH_INTERVAL", time.Minute),
+		DevMode:                     getBool("DEV_MODE", false),
+		DBQueryTimeout:              getDuration("DB_QUERY_TIMEOUT", 10*time.Second),
+		ReportRateLimitRPS:          getFloat("REPORT_RATE_LIMIT_RPS", 0.05),
+		PreviewMaxBytes:             getInt("PREVIEW_MAX_BYTES", 64<<10),
+		LoginFailureLimit:           int(getInt("LOGIN_FAILURE_LIMIT", 5)),
+		LoginFailureWindow:          getDuration("LOGIN_FAILURE_WINDOW", 10*time.Minute),
+		LoginBlockDuration:          getDuration("LOGIN_BLOCK_DURATION", 15*time.Minute),
+		RateLimitExemptAdmins:       getBool("RATE_LIMIT_EXEMPT_ADMINS", true),
+		PublicListLimit:             getInt("PUBLIC_LIST_LIMIT", 200),
+		GraphQLAPQCacheSize:         getInt("GRAPHQL_APQ_CACHE_SIZE", 1000),
+		GraphQLPersistedQueriesFile: os.Getenv("GRAPHQL_PERSISTED_QUERIES_FILE"),
+		GraphQLPersistedOnly:        getBool("GRAPHQL_PERSISTED_ONLY", false),
+		StrictMIME:                  getBool("STRICT_MIME", false),
+		UploadConcurrency:           int(getInt("UPLOAD_CONCURRENCY", 4)),
+		WebhookDeliveryInterval:     getDuration("WEBHOOK_DELIVERY_INTERVAL", 15*time.Second),
+		WebhookTimeout:              getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:          int(getInt("WEBHOOK_MAX_ATTEMPTS", 6)),
+		SMTPHost:                    os.Getenv("SMTP_HOST"),
+		SMTPPort:                    int(getInt("SMTP_PORT", 587)),
+		SMTPUsername:                os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:                os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                    os.Getenv("SMTP_FROM"),
+		ShareNotifyInterval:         getDuration("SHARE_NOTIFY_INTERVAL", time.Hour),
+		ShareNotifyIncludeIP:        getBool("SHARE_NOTIFY_INCLUDE_IP", false),
+		AllowShareFilenameOverride:  getBool("ALLOW_SHARE_FILENAME_OVERRIDE", false),
+		StorageKeyPrefix:            os.Getenv("STORAGE_KEY_PREFIX"),
+		StorageGCInterval:           getDuration("STORAGE_GC_INTERVAL", 10*time.Minute),
+		MaxFilesPerUser:             getInt("MAX_FILES_PER_USER", 0),
+		MaxShareExpiry:              getDuration("MAX_SHARE_EXPIRY", 365*24*time.Hour),
+		ReportHideThreshold:         getInt("REPORT_HIDE_THRESHOLD", 3),
+		VerifyUploads:               getBool("VERIFY_UPLOADS", false),
+		FolderLabels:                getBool("FOLDER_LABELS", false),
+		MaxUploadFiles:              int(getInt("MAX_UPLOAD_FILES", 20)),
+		MimeUploadLimits:            getIntMap("MIME_UPLOAD_LIMITS"),
+		MimeSampleBytes:             int(getInt("MIME_SAMPLE_BYTES", 4096)),
+		SupabaseURL:                 os.Getenv("SUPABASE_URL"),
+		SupabaseAnonKey:             os.Getenv("SUPABASE_ANON_KEY"),
+		SupabaseServiceRoleKey:      os.Getenv("SUPABASE_SERVICE_ROLE_KEY"),
+		SupabaseDBURL:               os.Getenv("SUPABASE_DB_URL"),
+		SupabaseDBReplicaURL:        os.Getenv("SUPABASE_DB_REPLICA_URL"),
+		StorageBucket:               getEnv("STORAGE_BUCKET", "blobs"),
+		RedisURL:                    getEnv("REDIS_URL", "redis://redis:6379"),
+		OAuthRedirectURL:            os.Getenv("OAUTH_REDIRECT_URL"),
+		GoogleClientID:              os.Getenv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:          os.Getenv("GOOGLE_CLIENT_SECRET"),
+		GoogleAccessType:            getEnv("GOOGLE_ACCESS_TYPE", "online"),
+		GooglePrompt:                strings.TrimSpace(os.Getenv("GOOGLE_PROMPT")),
 	}
 }
 
+// validateGooglePrompt accepts a space-separated list of Google's prompt values;
+// "none" cannot be combined with the others.
+func validateGooglePrompt(prompt string) error {
+	values := strings.Fields(prompt)
+	for _, value := range values {
+		switch value {
+		case "consent", "select_account":
+		case "none":
+			if len(values) > 1 {
+				return errors.New("GOOGLE_PROMPT=none cannot be combined with other values")
+			}
+		default:
+			return fmt.Errorf("GOOGLE_PROMPT values must be none, consent or select_account, got %q", value)
+		}
+	}
+	return nil
+}
+
+// defaultJWTSecret is the placeholder Load falls back to when JWT_SECRET is unset.
+const defaultJWTSecret = "change-me"
+
+// Validate reports missing or insecure settings that must not reach production.
+// In DevMode the problems are only logged.
+func (c Config) Validate() error {
+	var problems []error
+	if c.SupabaseURL == "" {
+		problems = append(problems, errors.New("SUPABASE_URL is required"))
+	}
+	if c.SupabaseServiceRoleKey == "" {
+		problems = append(problems, errors.New("SUPABASE_SERVICE_ROLE_KEY is required"))
+	}
+	if c.SupabaseDBURL == "" {
+		problems = append(problems, errors.New("SUPABASE_DB_URL is required"))
+	}
+	if c.JWTSecret == defaultJWTSecret || len(c.JWTSecret) < 32 {
+		problems = append(problems, errors.New("JWT_SECRET must be set to a random value of at least 32 characters"))
+	}
+	if c.MaxUploadBytes <= 0 {
+		problems = append(problems, fmt.Errorf("MAX_UPLOAD_BYTES must be positive, got %d", c.MaxUploadBytes))
+	}
+	if c.MimeSampleBytes < 0 {
+		problems = append(problems, fmt.Errorf("MIME_SAMPLE_BYTES must not be negative, got %d", c.MimeSampleBytes))
+	}
+	if c.MaxUploadFiles < 0 {
+		problems = append(problems, fmt.Errorf("MAX_UPLOAD_FILES must not be negative, got %d", c.MaxUploadFiles))
+	}
+	if c.DefaultUserQuotaBytes <= 0 {
+		problems = append(problems, fmt.Errorf("DEFAULT_USER_QUOTA_BYTES must be positive, got %d", c.DefaultUserQuotaBytes))
+	}
+	switch c.CookieSameSite {
+	case "", "lax", "strict":
+	case "none":
+		if !c.SecureCookies() {
+			problems = append(problems, errors.New("COOKIE_SAMESITE=none requires an https FRONTEND_URL so cookies are Secure"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("COOKIE_SAMESITE must be lax, strict or none, got %q", c.CookieSameSite))
+	}
+	if c.SMTPHost != "" && c.SMTPFrom == "" {
+		problems = append(problems, errors.New("SMTP_FROM is required when SMTP_HOST is set"))
+	}
+	if c.GoogleAccessType != "online" && c.GoogleAccessType != "offline" {
+		problems = append(problems, fmt.Errorf("GOOGLE_ACCESS_TYPE must be online or offline, got %q", c.GoogleAccessType))
+	}
+	if err := validateGooglePrompt(c.GooglePrompt); err != nil {
+		problems = append(problems, err)
+	}
+	for role, quota := range c.RoleQuotaBytes {
+		if quota < 0 {
+			problems = append(problems, fmt.Errorf("ROLE_QUOTA_BYTES for %s must not be negative, got %d", role, quota))
+		}
+	}
+	for mimeType, limit := range c.MimeUploadLimits {
+		if limit <= 0 {
+			problems = append(problems, fmt.Errorf("MIME_UPLOAD_LIMITS for %s must be positive, got %d", strings.ToLower(mimeType), limit))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	err := errors.Join(problems...)
+	if c.DevMode {
+		log.Printf("config: ignoring invalid settings in dev mode:\n%v", err)
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", err)
+}
+
+// MaxUploadBodyBytes is the largest upload any MIME type may have, which bounds
+// the size of a multipart request.
+func (c Config) MaxUploadBodyBytes() int64 {
+	largest := c.MaxUploadBytes
+	for _, limit := range c.MimeUploadLimits {
+		largest = max(largest, limit)
+	}
+	return largest
+}
+
+// SecureCookies reports whether cookies are marked Secure, which is the case
+// when the frontend is served over https.
+func (c Config) SecureCookies() bool {
+	return strings.HasPrefix(strings.ToLower(c.FrontendURL), "https://")
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -73,6 +388,44 @@ func getFloat(key string, fallback float64) float64 {
 	return fallback
 }
 
+func getBool(key string, fallback bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// getList splits a comma-separated variable, dropping blank entries.
+func getList(key string) []string {
+	var out []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// getIntMap parses "KEY=value" pairs separated by commas. Keys are upper-cased
+// and malformed entries are skipped.
+func getIntMap(key string) map[string]int64 {
+	out := map[string]int64{}
+	for _, item := range getList(key) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		out[strings.ToUpper(strings.TrimSpace(name))] = parsed
+	}
+	return out
+}
+
 func getDuration(key string, fallback time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := time.ParseDuration(value); err == nil {
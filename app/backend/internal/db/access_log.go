@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Access kinds recorded in file_access_log.
+const (
+	AccessKindOwner       = "OWNER"
+	AccessKindGrant       = "GRANT"
+	AccessKindShare       = "SHARE"
+	AccessKindPublic      = "PUBLIC"
+	AccessKindFolderShare = "FOLDER_SHARE"
+	// AccessKindAdmin is an administrator fetching a file for moderation.
+	AccessKindAdmin = "ADMIN"
+)
+
+type FileAccess struct {
+	ID     uuid.UUID
+	FileID uuid.UUID
+	Kind   string
+	// UserID is the signed-in downloader, nil for anonymous downloads.
+	UserID     *uuid.UUID
+	ShareToken *string
+	IP         *string
+	UserAgent  *string
+	AccessedAt time.Time
+}
+
+func (p *Pool) InsertFileAccess(ctx context.Context, entry FileAccess) error {
+	const stmt = `
+        insert into file_access_log (file_id, access_kind, share_token, ip, user_agent, user_id)
+        values ($1, $2, $3, nullif($4, ''), nullif($5, ''), $6)
+    `
+	ip, userAgent := "", ""
+	if entry.IP != nil {
+		ip = *entry.IP
+	}
+	if entry.UserAgent != nil {
+		userAgent = *entry.UserAgent
+	}
+	_, err := p.Exec(ctx, stmt, entry.FileID, entry.Kind, entry.ShareToken, ip, userAgent, entry.UserID)
+	return err
+}
+
+// FileAccessLog returns the most recent downloads of an owned file, newest first.
+func (p *Pool) FileAccessLog(ctx context.Context, fileID, ownerID uuid.UUID, limit int) ([]FileAccess, error) {
+	const query = `
+        select l.id, l.file_id, l.access_kind, l.share_token, l.ip, l.user_agent, l.accessed_at
+        from file_access_log l
+        join files f on f.id = l.file_id
+        where l.file_id = $1 and f.owner_id = $2
+        order by l.accessed_at desc
+        limit $3
+    `
+	rows, err := p.Query(ctx, query, fileID, ownerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]FileAccess, 0)
+	for rows.Next() {
+		var entry FileAccess
+		if err := rows.Scan(&entry.ID, &entry.FileID, &entry.Kind, &entry.ShareToken, &entry.IP, &entry.UserAgent, &entry.AccessedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// RecentDownload is a file the user downloaded, with the time of the latest download.
+type RecentDownload struct {
+	FileWithBlob
+	DownloadedAt time.Time
+}
+
+// RecentDownloads returns the distinct files userID downloaded most recently,
+// newest first. Files that were deleted or expired since, or that the user can no
+// longer reach as owner or grantee, are left out.
+func (p *Pool) RecentDownloads(ctx context.Context, userID uuid.UUID, limit int) ([]RecentDownload, error) {
+	query := fmt.Sprintf(`
+        with recent as (
+            select file_id, max(accessed_at) as downloaded_at
+            from file_access_log
+            where user_id = $1
+            group by file_id
+        )
+        select %s, r.downloaded_at
+        from recent r
+        join users u on u.id = $1
+        join files f on f.id = r.file_id
+        join file_blobs b on f.blob_id = b.id
+        where f.is_deleted = false
+          and (f.expires_at is null or f.expires_at > now())
+          and (
+              f.owner_id = u.id
+              or exists (
+                  select 1 from share_grants g
+                  where g.file_id = f.id
+                    and (g.grantee_id = u.id or lower(g.grantee_email) = lower(u.email))
+              )
+          )
+        order by r.downloaded_at desc
+        limit $2
+    `, fileWithBlobColumns)
+
+	rows, err := p.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]RecentDownload, 0)
+	for rows.Next() {
+		var entry RecentDownload
+		entry.FileWithBlob, err = scanFileWithBlob(rows, &entry.DownloadedAt)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
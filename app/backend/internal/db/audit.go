@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions recorded in audit_logs.
+const (
+	// AuditAdminFileDownload is an administrator fetching another user's file.
+	AuditAdminFileDownload = "ADMIN_FILE_DOWNLOAD"
+)
+
+// AuditEntry is one row of audit_logs. Metadata is stored as JSON.
+type AuditEntry struct {
+	ActorID    uuid.UUID
+	Action     string
+	EntityType string
+	EntityID   uuid.UUID
+	Metadata   map[string]any
+}
+
+// InsertAuditLog records entry on the primary.
+func (p *Pool) InsertAuditLog(ctx context.Context, entry AuditEntry) error {
+	const stmt = `
+        insert into audit_logs (actor_id, action, entity_type, entity_id, metadata)
+        values ($1, $2, $3, $4, $5)
+    `
+	metadata, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = p.Exec(ctx, stmt, entry.ActorID, entry.Action, entry.EntityType, entry.EntityID, string(metadata))
+	return err
+}
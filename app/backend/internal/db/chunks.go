@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Chunk is a content-defined slice of one or more chunked blobs.
+type Chunk struct {
+	ID         uuid.UUID
+	Sha256     string
+	SizeBytes  int64
+	StorageKey string
+	RefCount   int
+	CreatedAt  time.Time
+}
+
+// ReferenceChunk adds a reference to the chunk with the given hash and returns
+// it, or nil when no such chunk exists. Unlike looking the chunk up first, the
+// reference is taken atomically, so a concurrent purge cannot remove the chunk
+// in between.
+func (p *Pool) ReferenceChunk(ctx context.Context, hash string) (*Chunk, error) {
+	const stmt = `
+        update chunks
+        set ref_count = ref_count + 1
+        where sha256 = $1
+        returning id, sha256, size_bytes, storage_key, ref_count, created_at
+    `
+	var chunk Chunk
+	err := p.QueryRow(ctx, stmt, hash).Scan(&chunk.ID, &chunk.Sha256, &chunk.SizeBytes, &chunk.StorageKey, &chunk.RefCount, &chunk.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &chunk, nil
+}
+
+// UpsertChunk records a reference to the chunk with the given hash, inserting it
+// with a ref count of one or incrementing the existing row.
+func (p *Pool) UpsertChunk(ctx context.Context, hash string, size int64, storageKey string) (*Chunk, error) {
+	const stmt = `
+        insert into chunks (sha256, size_bytes, storage_key, ref_count)
+        values ($1, $2, $3, 1)
+        on conflict (sha256)
+            do update set ref_count = chunks.ref_count + 1
+        returning id, sha256, size_bytes, storage_key, ref_count, created_at
+    `
+	var chunk Chunk
+	err := p.QueryRow(ctx, stmt, hash, size, storageKey).Scan(&chunk.ID, &chunk.Sha256, &chunk.SizeBytes, &chunk.StorageKey, &chunk.RefCount, &chunk.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &chunk, nil
+}
+
+// InsertBlobChunks stores the ordered chunk list that makes up blobID.
+func (p *Pool) InsertBlobChunks(ctx context.Context, blobID uuid.UUID, chunkIDs []uuid.UUID) error {
+	const stmt = `
+        insert into blob_chunks (blob_id, seq, chunk_id)
+        select $1, c.seq - 1, c.chunk_id
+        from unnest($2::uuid[]) with ordinality as c(chunk_id, seq)
+    `
+	_, err := p.Exec(ctx, stmt, blobID, chunkIDs)
+	return err
+}
+
+// ReleaseChunks drops one reference per listed chunk ID, undoing UpsertChunk for
+// chunks that ended up unused.
+func (p *Pool) ReleaseChunks(ctx context.Context, chunkIDs []uuid.UUID) error {
+	const stmt = `
+        update chunks c
+        set ref_count = c.ref_count - r.cnt
+        from (select id, count(*) as cnt from unnest($1::uuid[]) as id group by id) r
+        where c.id = r.id
+    `
+	_, err := p.Exec(ctx, stmt, chunkIDs)
+	return err
+}
+
+// ListBlobChunks returns the chunks of blobID in content order.
+func (p *Pool) ListBlobChunks(ctx context.Context, blobID uuid.UUID) ([]Chunk, error) {
+	const query = `
+        select c.id, c.sha256, c.size_bytes, c.storage_key, c.ref_count, c.created_at
+        from blob_chunks bc
+        join chunks c on c.id = bc.chunk_id
+        where bc.blob_id = $1
+        order by bc.seq
+    `
+	rows, err := p.Query(ctx, query, blobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chunks := make([]Chunk, 0)
+	for rows.Next() {
+		var chunk Chunk
+		if err := rows.Scan(&chunk.ID, &chunk.Sha256, &chunk.SizeBytes, &chunk.StorageKey, &chunk.RefCount, &chunk.CreatedAt); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// ReleaseBlobChunks drops blobID's chunk list and decrements the referenced
+// chunks, deleting those that reach zero. It returns the storage keys of the
+// deleted chunks so the caller can remove the objects.
+func (p *Pool) ReleaseBlobChunks(ctx context.Context, blobID uuid.UUID) ([]string, error) {
+	const releaseStmt = `
+        with removed as (
+            delete from blob_chunks
+            where blob_id = $1
+            returning chunk_id
+        )
+        update chunks c
+        set ref_count = c.ref_count - r.cnt
+        from (select chunk_id, count(*) as cnt from removed group by chunk_id) r
+        where c.id = r.chunk_id
+    `
+	const deleteStmt = `
+        delete from chunks
+        where ref_count <= 0
+          and not exists (select 1 from blob_chunks bc where bc.chunk_id = chunks.id)
+        returning storage_key
+    `
+
+	keys := make([]string, 0)
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, releaseStmt, blobID); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, deleteStmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, translateTimeout(err)
+	}
+	return keys, nil
+}
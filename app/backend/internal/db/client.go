@@ -5,35 +5,115 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const defaultPoolMaxConnLifetime = time.Hour
 
-// Pool wraps pgx connection pooling for reuse across services.
+// Pool wraps pgx connection pooling for reuse across services. Writes always go
+// to the embedded primary pool; selected read-heavy queries use reader().
 type Pool struct {
 	*pgxpool.Pool
+	replica      *pgxpool.Pool
+	queryTimeout time.Duration
+	defaultQuota int64
+	roleQuotas   map[string]int64
 }
 
 func NewPool(ctx context.Context, connString string) (*Pool, error) {
-	cfg, err := pgxpool.ParseConfig(connString)
+	pool, err := newPgxPool(ctx, connString)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.MaxConnLifetime = defaultPoolMaxConnLifetime
-	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+	return &Pool{Pool: pool}, nil
+}
+
+// SetQueryTimeout bounds every statement issued through the pool; zero disables
+// the limit.
+func (p *Pool) SetQueryTimeout(timeout time.Duration) {
+	p.queryTimeout = timeout
+}
+
+// SetDefaultQuotas sets the quota_bytes assigned to new users: the byRole entry
+// for their role if present, otherwise defaultQuota.
+func (p *Pool) SetDefaultQuotas(defaultQuota int64, byRole map[string]int64) {
+	p.defaultQuota = defaultQuota
+	p.roleQuotas = byRole
+}
 
-	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+// defaultQuotaFor falls back to the users.quota_bytes column default when no
+// quotas were configured.
+func (p *Pool) defaultQuotaFor(role string) int64 {
+	if quota, ok := p.roleQuotas[role]; ok {
+		return quota
+	}
+	if p.defaultQuota > 0 {
+		return p.defaultQuota
+	}
+	return 10485760
+}
+
+// Exec, Query and QueryRow shadow the embedded pgxpool methods so every
+// statement on the primary runs under the query timeout.
+func (p *Pool) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return querier{pool: p.Pool, timeout: p.queryTimeout}.Exec(ctx, sql, args...)
+}
+
+func (p *Pool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return querier{pool: p.Pool, timeout: p.queryTimeout}.Query(ctx, sql, args...)
+}
+
+func (p *Pool) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return querier{pool: p.Pool, timeout: p.queryTimeout}.QueryRow(ctx, sql, args...)
+}
+
+// UseReplica connects to a read replica that reader() routes queries to.
+func (p *Pool) UseReplica(ctx context.Context, connString string) error {
+	replica, err := newPgxPool(ctx, connString)
+	if err != nil {
+		return err
+	}
+	p.replica = replica
+	return nil
+}
+
+// reader returns the replica when one is configured and the primary otherwise.
+// Only use it for queries that tolerate replication lag.
+func (p *Pool) reader() querier {
+	if p.replica != nil {
+		return querier{pool: p.replica, timeout: p.queryTimeout}
+	}
+	return querier{pool: p.Pool, timeout: p.queryTimeout}
+}
+
+// primary returns the primary as a querier, for reads that must see the
+// caller's latest writes.
+func (p *Pool) primary() querier {
+	return querier{pool: p.Pool, timeout: p.queryTimeout}
+}
+
+func newPgxPool(ctx context.Context, connString string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(connString)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Pool{Pool: pool}, nil
+	cfg.MaxConnLifetime = defaultPoolMaxConnLifetime
+	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+
+	return pgxpool.NewWithConfig(ctx, cfg)
 }
 
 func (p *Pool) Close() {
-	if p != nil && p.Pool != nil {
+	if p == nil {
+		return
+	}
+	if p.replica != nil {
+		p.replica.Close()
+	}
+	if p.Pool != nil {
 		p.Pool.Close()
 	}
 }
@@ -0,0 +1,145 @@
+// Package dbtest gives tests a Postgres database with every migration applied.
+// Tests using it are skipped unless TEST_DATABASE_URL names a database they may
+// create schemas in.
+package dbtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"vault/internal/db"
+)
+
+// EnvDSN names the variable holding the test database connection string.
+const EnvDSN = "TEST_DATABASE_URL"
+
+// NewPool migrates a fresh schema of the test database and returns a pool
+// confined to it. The schema is dropped when the test ends.
+func NewPool(t testing.TB) *db.Pool {
+	t.Helper()
+
+	dsn := os.Getenv(EnvDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", EnvDSN)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	schema := "vault_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	if _, err := admin.Exec(ctx, "create schema "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if _, err := admin.Exec(ctx, "drop schema "+schema+" cascade"); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+		admin.Close(ctx)
+	})
+
+	pool, err := db.NewPool(ctx, withSearchPath(dsn, schema+",public"))
+	if err != nil {
+		t.Fatalf("open pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	for _, path := range migrations(t) {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read migration: %v", err)
+		}
+		// Some migrations were saved with a byte order mark.
+		if _, err := pool.Exec(ctx, strings.TrimPrefix(string(sql), "\ufeff")); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+	return pool
+}
+
+// CreateUser inserts a user with the given email and returns it.
+func CreateUser(t testing.TB, pool *db.Pool, email string) db.User {
+	t.Helper()
+	user, err := pool.UpsertUser(context.Background(), email, email)
+	if err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user
+}
+
+// InsertFile records content as a blob, reusing an existing blob with the same
+// hash, and gives ownerID a file named name that references it. Nothing is
+// written to storage; the blob's storage key is "blobs/<sha256>".
+func InsertFile(t testing.TB, pool *db.Pool, ownerID uuid.UUID, name, content string) db.FileWithBlob {
+	t.Helper()
+	ctx := context.Background()
+
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	blob := &db.FileBlob{
+		Sha256:       hash,
+		SizeBytes:    int64(len(content)),
+		MimeDetected: "text/plain",
+		StorageKey:   "blobs/" + hash,
+	}
+	if _, err := pool.InsertBlob(ctx, blob); err != nil {
+		t.Fatalf("insert blob: %v", err)
+	}
+	record := &db.FileRecord{
+		OwnerID:            ownerID,
+		BlobID:             blob.ID,
+		FilenameOriginal:   name,
+		FilenameNormalized: strings.ToLower(name),
+		SizeBytesOriginal:  int64(len(content)),
+		Tags:               []string{},
+	}
+	if err := pool.InsertFile(ctx, record); err != nil {
+		t.Fatalf("insert file: %v", err)
+	}
+	return db.FileWithBlob{File: *record, Blob: *blob}
+}
+
+func migrations(t testing.TB) []string {
+	t.Helper()
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("locate migrations: no caller information")
+	}
+	paths, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "..", "migrations", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("locate migrations: %v", err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// withSearchPath adds a search_path runtime parameter to a URL or key/value
+// connection string.
+func withSearchPath(dsn, path string) string {
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + strings.ReplaceAll(path, ",", "%2C")
+	}
+	return fmt.Sprintf("%s search_path=%s", dsn, path)
+}
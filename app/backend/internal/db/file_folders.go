@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LinkFilesToFolder makes every live file in fileIDs owned by ownerID also
+// appear in folderID, without changing its home folder. It returns the IDs of
+// the owned files, including those already linked. The folder row is locked so
+// it cannot be deleted while the links are written.
+func (p *Pool) LinkFilesToFolder(ctx context.Context, ownerID uuid.UUID, fileIDs []uuid.UUID, folderID uuid.UUID) ([]uuid.UUID, error) {
+	const linkStmt = `
+        with owned as (
+            select id from files
+            where owner_id = $1 and id = any($2) and is_deleted = false
+        ), linked as (
+            insert into file_folders (file_id, folder_id)
+            select id, $3 from owned
+            on conflict do nothing
+        )
+        select id from owned
+    `
+	return p.updateFileFolderLinks(ctx, linkStmt, ownerID, fileIDs, folderID)
+}
+
+// UnlinkFilesFromFolder removes the folderID links of the owner's files and
+// returns the IDs of the owned files. Home folders are left unchanged.
+func (p *Pool) UnlinkFilesFromFolder(ctx context.Context, ownerID uuid.UUID, fileIDs []uuid.UUID, folderID uuid.UUID) ([]uuid.UUID, error) {
+	const unlinkStmt = `
+        with owned as (
+            select id from files
+            where owner_id = $1 and id = any($2) and is_deleted = false
+        ), unlinked as (
+            delete from file_folders
+            where folder_id = $3 and file_id in (select id from owned)
+        )
+        select id from owned
+    `
+	return p.updateFileFolderLinks(ctx, unlinkStmt, ownerID, fileIDs, folderID)
+}
+
+func (p *Pool) updateFileFolderLinks(ctx context.Context, stmt string, ownerID uuid.UUID, fileIDs []uuid.UUID, folderID uuid.UUID) ([]uuid.UUID, error) {
+	const lockFolderStmt = `select 1 from folders where id = $1 and owner_id = $2 for share`
+
+	var updated []uuid.UUID
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		var exists int
+		if err := tx.QueryRow(ctx, lockFolderStmt, folderID, ownerID).Scan(&exists); err != nil {
+			if err == pgx.ErrNoRows {
+				return ErrFolderNotFound
+			}
+			return err
+		}
+
+		rows, err := tx.Query(ctx, stmt, ownerID, fileIDs, folderID)
+		if err != nil {
+			return err
+		}
+		updated, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrFolderNotFound) {
+			return nil, err
+		}
+		return nil, translateTimeout(err)
+	}
+	return updated, nil
+}
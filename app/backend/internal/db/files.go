@@ -3,12 +3,14 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -20,6 +22,16 @@ type FileBlob struct {
 	StorageKey   string
 	RefCount     int
 	CreatedAt    time.Time
+	// Chunked blobs have no object at StorageKey; their content is the ordered
+	// concatenation of the chunks listed in blob_chunks.
+	Chunked bool
+	// Encoding is the transform applied before storing ("identity" or "gzip").
+	// SizeBytes and Sha256 always describe the decoded content.
+	Encoding        string
+	StoredSizeBytes int64
+	// Width and Height are the pixel dimensions of image blobs, when known.
+	Width  *int
+	Height *int
 }
 
 type FileRecord struct {
@@ -34,8 +46,24 @@ type FileRecord struct {
 	IsDeleted          bool
 	Tags               []string
 	DownloadCount      int64
+	// ExpiresAt schedules the file for automatic deletion; nil means never.
+	ExpiresAt *time.Time
+	// Description is optional owner-provided text, matched by FileFilter.Search.
+	Description *string
+	// HiddenFromPublic keeps the file out of the public gallery, either because
+	// it was reported too often or because an admin hid it.
+	HiddenFromPublic bool
 }
 
+// Expired reports whether the file's expiry has passed at now. Expired files are
+// no longer served even before the sweep job soft-deletes them.
+func (f FileRecord) Expired(now time.Time) bool {
+	return f.ExpiresAt != nil && !f.ExpiresAt.After(now)
+}
+
+// DefaultListLimit caps the rows returned by file listings that take no explicit limit.
+const DefaultListLimit = 200
+
 type FileWithBlob struct {
 	File FileRecord
 	Blob FileBlob
@@ -47,23 +75,157 @@ type ShareRecord struct {
 	Visibility string
 	Token      *string
 	ExpiresAt  *time.Time
+	// NotifyOnDownload opts the owner into an email when the share is downloaded.
+	NotifyOnDownload bool
 }
 
+// Active reports whether the share's token link can be downloaded at now: it
+// must have a token and must not have expired. This mirrors the conditions in
+// GetFileByShareToken.
+func (s ShareRecord) Active(now time.Time) bool {
+	return s.Token != nil && *s.Token != "" && (s.ExpiresAt == nil || s.ExpiresAt.After(now))
+}
+
+// FileCursor is a position in a listing ordered newest first: the listing
+// continues with files strictly after the file it was taken from.
+type FileCursor struct {
+	UploadedAt time.Time
+	ID         uuid.UUID
+}
+
+// CursorOf returns the cursor that continues a listing after file.
+func CursorOf(file FileRecord) FileCursor {
+	return FileCursor{UploadedAt: file.UploadedAt, ID: file.ID}
+}
+
+// FileFilter narrows file listings. It is also persisted as JSON for saved searches.
 type FileFilter struct {
-	Search       *string
-	MimeTypes    []string
-	MinSize      *int64
-	MaxSize      *int64
-	Tags         []string
-	UploaderName *string
-	UploaderID   *uuid.UUID
-	UploadedFrom *time.Time
-	UploadedTo   *time.Time
+	Search       *string    `json:"search,omitempty"`
+	MimeTypes    []string   `json:"mimeTypes,omitempty"`
+	MinSize      *int64     `json:"minSize,omitempty"`
+	MaxSize      *int64     `json:"maxSize,omitempty"`
+	MinDownloads *int64     `json:"minDownloads,omitempty"`
+	MaxDownloads *int64     `json:"maxDownloads,omitempty"`
+	Tags         []string   `json:"tags,omitempty"`
+	UploaderName *string    `json:"uploaderName,omitempty"`
+	UploaderID   *uuid.UUID `json:"uploaderId,omitempty"`
+	UploadedFrom *time.Time `json:"uploadedFrom,omitempty"`
+	UploadedTo   *time.Time `json:"uploadedTo,omitempty"`
+	FolderID     *uuid.UUID `json:"folderId,omitempty"`
+	// Recursive widens a FolderID filter to every descendant folder.
+	Recursive bool `json:"recursive,omitempty"`
+	// IncludeLinked makes a FolderID filter also match files linked to the
+	// folders through file_folders. It follows the FOLDER_LABELS setting rather
+	// than being saved with a search.
+	IncludeLinked bool `json:"-"`
+}
+
+// fileWithBlobColumns is the select list read by scanFileWithBlob. Queries alias
+// files as f and file_blobs as b.
+const fileWithBlobColumns = `f.id, f.owner_id, f.blob_id, f.filename_original, f.filename_normalized,
+               f.mime_declared, f.size_bytes_original, f.uploaded_at, f.is_deleted, f.tags, f.download_count,
+               f.expires_at, b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at, b.chunked,
+               b.encoding, coalesce(b.stored_size_bytes, b.size_bytes), b.width, b.height, f.description, f.hidden_from_public`
+
+// scanFileWithBlob reads a row selected with fileWithBlobColumns followed by any
+// extra destinations.
+func scanFileWithBlob(row pgx.Row, extra ...any) (FileWithBlob, error) {
+	var rec FileRecord
+	var blob FileBlob
+	var tagsJSON []byte
+
+	dest := []any{
+		&rec.ID,
+		&rec.OwnerID,
+		&rec.BlobID,
+		&rec.FilenameOriginal,
+		&rec.FilenameNormalized,
+		&rec.MimeDeclared,
+		&rec.SizeBytesOriginal,
+		&rec.UploadedAt,
+		&rec.IsDeleted,
+		&tagsJSON,
+		&rec.DownloadCount,
+		&rec.ExpiresAt,
+		&blob.ID,
+		&blob.Sha256,
+		&blob.SizeBytes,
+		&blob.MimeDetected,
+		&blob.StorageKey,
+		&blob.RefCount,
+		&blob.CreatedAt,
+		&blob.Chunked,
+		&blob.Encoding,
+		&blob.StoredSizeBytes,
+		&blob.Width,
+		&blob.Height,
+		&rec.Description,
+		&rec.HiddenFromPublic,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return FileWithBlob{}, err
+	}
+
+	if len(tagsJSON) > 0 {
+		_ = json.Unmarshal(tagsJSON, &rec.Tags)
+	} else {
+		rec.Tags = []string{}
+	}
+
+	return FileWithBlob{File: rec, Blob: blob}, nil
+}
+
+// appendFileFilter adds the owner-independent FileFilter conditions (search, MIME,
+// size, download count, tags, upload dates) to a where list, numbering placeholders after args.
+func appendFileFilter(filter *FileFilter, args []any, where []string) ([]any, []string) {
+	if filter == nil {
+		return args, where
+	}
+	if filter.Search != nil && *filter.Search != "" {
+		args = append(args, "%"+strings.ToLower(*filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(f.filename_normalized LIKE $%d or lower(f.description) LIKE $%d)", len(args), len(args)))
+	}
+	if len(filter.MimeTypes) > 0 {
+		args = append(args, filter.MimeTypes)
+		where = append(where, fmt.Sprintf("(coalesce(f.mime_declared, b.mime_detected) = ANY($%d))", len(args)))
+	}
+	if filter.MinSize != nil {
+		args = append(args, *filter.MinSize)
+		where = append(where, fmt.Sprintf("f.size_bytes_original >= $%d", len(args)))
+	}
+	if filter.MaxSize != nil {
+		args = append(args, *filter.MaxSize)
+		where = append(where, fmt.Sprintf("f.size_bytes_original <= $%d", len(args)))
+	}
+	if filter.MinDownloads != nil {
+		args = append(args, *filter.MinDownloads)
+		where = append(where, fmt.Sprintf("f.download_count >= $%d", len(args)))
+	}
+	if filter.MaxDownloads != nil {
+		args = append(args, *filter.MaxDownloads)
+		where = append(where, fmt.Sprintf("f.download_count <= $%d", len(args)))
+	}
+	if len(filter.Tags) > 0 {
+		if tagsJSON, err := json.Marshal(filter.Tags); err == nil {
+			args = append(args, string(tagsJSON))
+			where = append(where, fmt.Sprintf("f.tags @> $%d", len(args)))
+		}
+	}
+	if filter.UploadedFrom != nil {
+		args = append(args, *filter.UploadedFrom)
+		where = append(where, fmt.Sprintf("f.uploaded_at >= $%d", len(args)))
+	}
+	if filter.UploadedTo != nil {
+		args = append(args, *filter.UploadedTo)
+		where = append(where, fmt.Sprintf("f.uploaded_at <= $%d", len(args)))
+	}
+	return args, where
 }
 
 func (p *Pool) GetBlobByHash(ctx context.Context, hash string) (*FileBlob, error) {
 	const query = `
-        select id, sha256, size_bytes, mime_detected, storage_key, ref_count, created_at
+        select id, sha256, size_bytes, mime_detected, storage_key, ref_count, created_at, chunked,
+               encoding, coalesce(stored_size_bytes, size_bytes), width, height
         from file_blobs
         where sha256 = $1
     `
@@ -76,6 +238,11 @@ func (p *Pool) GetBlobByHash(ctx context.Context, hash string) (*FileBlob, error
 		&blob.StorageKey,
 		&blob.RefCount,
 		&blob.CreatedAt,
+		&blob.Chunked,
+		&blob.Encoding,
+		&blob.StoredSizeBytes,
+		&blob.Width,
+		&blob.Height,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -86,23 +253,54 @@ func (p *Pool) GetBlobByHash(ctx context.Context, hash string) (*FileBlob, error
 	return &blob, nil
 }
 
-func (p *Pool) InsertBlob(ctx context.Context, hash string, size int64, mime, storageKey string) (*FileBlob, error) {
+// InsertBlob records a new blob with one reference, filling in ID, RefCount and
+// CreatedAt. An empty Encoding is stored as "identity". When a concurrent upload
+// of the same content inserted it first, the existing row gains the reference
+// instead and blob is overwritten with that row; the result then reports false.
+func (p *Pool) InsertBlob(ctx context.Context, blob *FileBlob) (bool, error) {
 	const stmt = `
-        insert into file_blobs (sha256, size_bytes, mime_detected, storage_key, ref_count)
-        values ($1, $2, $3, $4, 1)
-        returning id, created_at
+        insert into file_blobs (sha256, size_bytes, mime_detected, storage_key, ref_count, chunked, encoding, stored_size_bytes, width, height)
+        values ($1, $2, $3, $4, 1, $5, $6, $7, $8, $9)
+        on conflict (sha256)
+            do update set ref_count = file_blobs.ref_count + 1
+        returning id, sha256, size_bytes, mime_detected, storage_key, ref_count, created_at, chunked,
+                  encoding, coalesce(stored_size_bytes, size_bytes), width, height, (xmax = 0)
     `
-	var blob FileBlob
-	blob.Sha256 = hash
-	blob.SizeBytes = size
-	blob.MimeDetected = mime
-	blob.StorageKey = storageKey
-	blob.RefCount = 1
-	err := p.QueryRow(ctx, stmt, hash, size, mime, storageKey).Scan(&blob.ID, &blob.CreatedAt)
-	if err != nil {
-		return nil, err
+	if blob.Encoding == "" {
+		blob.Encoding = "identity"
 	}
-	return &blob, nil
+	if blob.StoredSizeBytes == 0 {
+		blob.StoredSizeBytes = blob.SizeBytes
+	}
+	var inserted bool
+	err := p.QueryRow(
+		ctx,
+		stmt,
+		blob.Sha256,
+		blob.SizeBytes,
+		blob.MimeDetected,
+		blob.StorageKey,
+		blob.Chunked,
+		blob.Encoding,
+		blob.StoredSizeBytes,
+		blob.Width,
+		blob.Height,
+	).Scan(
+		&blob.ID,
+		&blob.Sha256,
+		&blob.SizeBytes,
+		&blob.MimeDetected,
+		&blob.StorageKey,
+		&blob.RefCount,
+		&blob.CreatedAt,
+		&blob.Chunked,
+		&blob.Encoding,
+		&blob.StoredSizeBytes,
+		&blob.Width,
+		&blob.Height,
+		&inserted,
+	)
+	return inserted, err
 }
 
 func (p *Pool) IncrementBlobRef(ctx context.Context, blobID uuid.UUID) error {
@@ -111,34 +309,70 @@ func (p *Pool) IncrementBlobRef(ctx context.Context, blobID uuid.UUID) error {
 	return err
 }
 
-func (p *Pool) DecrementBlobRef(ctx context.Context, blobID uuid.UUID) (int, error) {
+// TouchBlob records that blobID was read, skipping the write when the previous
+// stamp is younger than resolution.
+func (p *Pool) TouchBlob(ctx context.Context, blobID uuid.UUID, resolution time.Duration) error {
 	const stmt = `
         update file_blobs
-        set ref_count = ref_count - 1
+        set last_accessed_at = now()
         where id = $1
-        returning ref_count
+          and (last_accessed_at is null or last_accessed_at < now() - $2 * interval '1 second')
     `
-	var refCount int
-	err := p.QueryRow(ctx, stmt, blobID).Scan(&refCount)
-	if err != nil {
-		return 0, err
-	}
-	return refCount, nil
+	_, err := p.Exec(ctx, stmt, blobID, resolution.Seconds())
+	return err
 }
 
-func (p *Pool) DeleteBlob(ctx context.Context, blobID uuid.UUID) error {
-	const stmt = `delete from file_blobs where id = $1`
-	_, err := p.Exec(ctx, stmt, blobID)
-	return err
+// ColdBlob is a blob with its last recorded read.
+type ColdBlob struct {
+	FileBlob
+	// LastAccessedAt is nil for blobs not downloaded since tracking began.
+	LastAccessedAt *time.Time
 }
 
-func (p *Pool) InsertFile(ctx context.Context, record *FileRecord) error {
-	tagsJSON, err := json.Marshal(record.Tags)
+// ListColdBlobs returns the blobs read least recently, treating never-read blobs
+// as last accessed when they were created.
+func (p *Pool) ListColdBlobs(ctx context.Context, limit int) ([]ColdBlob, error) {
+	const query = `
+        select id, sha256, size_bytes, mime_detected, storage_key, ref_count, created_at, chunked,
+               encoding, coalesce(stored_size_bytes, size_bytes), width, height, last_accessed_at
+        from file_blobs
+        order by coalesce(last_accessed_at, created_at)
+        limit $1
+    `
+	rows, err := p.reader().Query(ctx, query, limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	const stmt = `
+	var out []ColdBlob
+	for rows.Next() {
+		var b ColdBlob
+		if err := rows.Scan(&b.ID, &b.Sha256, &b.SizeBytes, &b.MimeDetected, &b.StorageKey, &b.RefCount, &b.CreatedAt,
+			&b.Chunked, &b.Encoding, &b.StoredSizeBytes, &b.Width, &b.Height, &b.LastAccessedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// BlobOwnerCount returns how many distinct users own a live file backed by
+// blobID. With cross-user dedup this can exceed one.
+func (p *Pool) BlobOwnerCount(ctx context.Context, blobID uuid.UUID) (int, error) {
+	const query = `
+        select count(distinct owner_id)
+        from files
+        where blob_id = $1 and is_deleted = false
+    `
+	var owners int
+	if err := p.reader().QueryRow(ctx, query, blobID).Scan(&owners); err != nil {
+		return 0, err
+	}
+	return owners, nil
+}
+
+const insertFileSQL = `
         insert into files (
             owner_id, blob_id, filename_original, filename_normalized, mime_declared,
             size_bytes_original, tags
@@ -146,9 +380,55 @@ func (p *Pool) InsertFile(ctx context.Context, record *FileRecord) error {
         values ($1, $2, $3, $4, $5, $6, $7)
         returning id, uploaded_at, download_count
     `
-	return p.QueryRow(
+
+func (p *Pool) InsertFile(ctx context.Context, record *FileRecord) error {
+	return insertFile(ctx, p, record)
+}
+
+// ErrBlobGone reports that a blob was purged before a new file could reference it.
+var ErrBlobGone = errors.New("blob no longer exists")
+
+// InsertFileForBlob inserts record as a new reference to its existing blob. The
+// blob's ref_count is raised in the same transaction, so a failure cannot leave
+// a reference without its file row. It returns ErrBlobGone when the blob has
+// been purged.
+func (p *Pool) InsertFileForBlob(ctx context.Context, record *FileRecord) error {
+	if p == nil {
+		return errors.New("nil db pool")
+	}
+
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `update file_blobs set ref_count = ref_count + 1 where id = $1`, record.BlobID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrBlobGone
+		}
+		return insertFile(ctx, tx, record)
+	})
+	if err != nil {
+		return fmt.Errorf("insert file for blob: %w", translateTimeout(err))
+	}
+	return nil
+}
+
+// rowQuerier is satisfied by both Pool and pgx.Tx.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertFile(ctx context.Context, q rowQuerier, record *FileRecord) error {
+	tagsJSON, err := json.Marshal(record.Tags)
+	if err != nil {
+		return err
+	}
+
+	return q.QueryRow(
 		ctx,
-		stmt,
+		insertFileSQL,
 		record.OwnerID,
 		record.BlobID,
 		record.FilenameOriginal,
@@ -159,162 +439,113 @@ func (p *Pool) InsertFile(ctx context.Context, record *FileRecord) error {
 	).Scan(&record.ID, &record.UploadedAt, &record.DownloadCount)
 }
 
-func (p *Pool) ListFiles(ctx context.Context, ownerID uuid.UUID, filter *FileFilter) ([]FileWithBlob, int, error) {
+// ListFiles returns up to limit of the owner's files, newest first, and the total
+// number matching filter, both from a single query. after continues a previous
+// page.
+func (p *Pool) ListFiles(ctx context.Context, ownerID uuid.UUID, filter *FileFilter, after *FileCursor, limit int) ([]FileWithBlob, int, error) {
 	args := []any{ownerID}
 	where := []string{"f.owner_id = $1", "f.is_deleted = false"}
 
+	args, where = appendFileFilter(filter, args, where)
 	if filter != nil {
-		if filter.Search != nil && *filter.Search != "" {
-			args = append(args, "%"+strings.ToLower(*filter.Search)+"%")
-			where = append(where, fmt.Sprintf("f.filename_normalized LIKE $%d", len(args)))
-		}
-		if len(filter.MimeTypes) > 0 {
-			args = append(args, filter.MimeTypes)
-			where = append(where, fmt.Sprintf("(coalesce(f.mime_declared, b.mime_detected) = ANY($%d))", len(args)))
-		}
-		if filter.MinSize != nil {
-			args = append(args, *filter.MinSize)
-			where = append(where, fmt.Sprintf("f.size_bytes_original >= $%d", len(args)))
-		}
-		if filter.MaxSize != nil {
-			args = append(args, *filter.MaxSize)
-			where = append(where, fmt.Sprintf("f.size_bytes_original <= $%d", len(args)))
-		}
-		if len(filter.Tags) > 0 {
-			tagsJSON, err := json.Marshal(filter.Tags)
-			if err == nil {
-				args = append(args, string(tagsJSON))
-				where = append(where, fmt.Sprintf("f.tags @> $%d", len(args)))
+		if filter.FolderID != nil {
+			args = append(args, *filter.FolderID)
+			folders := fmt.Sprintf("$%d", len(args))
+			if filter.Recursive {
+				folders = fmt.Sprintf(`
+            with recursive folder_tree as (
+                select id from folders where id = $%d and owner_id = $1
+                union all
+                select c.id from folders c join folder_tree ft on c.parent_id = ft.id
+            )
+            select id from folder_tree
+        `, len(args))
+			}
+			if filter.IncludeLinked {
+				where = append(where, fmt.Sprintf(`(f.folder_id in (%s) or exists (
+            select 1 from file_folders ff where ff.file_id = f.id and ff.folder_id in (%s)
+        ))`, folders, folders))
+			} else {
+				where = append(where, fmt.Sprintf("f.folder_id in (%s)", folders))
 			}
-		}
-		if filter.UploadedFrom != nil {
-			args = append(args, *filter.UploadedFrom)
-			where = append(where, fmt.Sprintf("f.uploaded_at >= $%d", len(args)))
-		}
-		if filter.UploadedTo != nil {
-			args = append(args, *filter.UploadedTo)
-			where = append(where, fmt.Sprintf("f.uploaded_at <= $%d", len(args)))
 		}
 	}
 
-	whereClause := strings.Join(where, " AND ")
+	return p.listFilesPage(ctx, `
+        from files f
+        join file_blobs b on f.blob_id = b.id`, where, args, after, limit)
+}
+
+// listFilesPage runs a paginated listing over from (which must alias files as f
+// and file_blobs as b) filtered by where. Matches are counted before the
+// cursor is applied, so the total covers every page, not just the rest.
+func (p *Pool) listFilesPage(ctx context.Context, from string, where []string, args []any, after *FileCursor, limit int) ([]FileWithBlob, int, error) {
+	cursorClause := "true"
+	if after != nil {
+		args = append(args, after.UploadedAt, after.ID)
+		cursorClause = fmt.Sprintf("(m.uploaded_at, m.id) < ($%d, $%d)", len(args)-1, len(args))
+	}
 
 	query := fmt.Sprintf(`
-        select f.id, f.owner_id, f.blob_id, f.filename_original, f.filename_normalized,
-               f.mime_declared, f.size_bytes_original, f.uploaded_at, f.is_deleted, f.tags, f.download_count,
-               b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at
-        from files f
+        with matched as (
+            select f.id, f.uploaded_at, count(*) over() as total
+            %s
+            where %s
+        )
+        select %s, m.total
+        from matched m
+        join files f on f.id = m.id
         join file_blobs b on f.blob_id = b.id
         where %s
-        order by f.uploaded_at desc
-        limit 200
-    `, whereClause)
+        order by m.uploaded_at desc, m.id desc
+        limit %d
+    `, from, strings.Join(where, " AND "), fileWithBlobColumns, cursorClause, limit)
 
-	rows, err := p.Query(ctx, query, args...)
+	rows, err := p.reader().Query(ctx, query, args...)
 	if err != nil {
 		return nil, 0, err
 	}
 	defer rows.Close()
 
+	return scanCountedFiles(rows)
+}
+
+// scanCountedFiles reads file rows whose last column is the total number of
+// matches. The page holds at most the limit; a page past the last match is
+// empty and reports a total of zero.
+func scanCountedFiles(rows pgx.Rows) ([]FileWithBlob, int, error) {
 	files := make([]FileWithBlob, 0)
+	total := 0
 	for rows.Next() {
-		var rec FileRecord
-		var blob FileBlob
-		var tagsJSON []byte
-
-		if err := rows.Scan(
-			&rec.ID,
-			&rec.OwnerID,
-			&rec.BlobID,
-			&rec.FilenameOriginal,
-			&rec.FilenameNormalized,
-			&rec.MimeDeclared,
-			&rec.SizeBytesOriginal,
-			&rec.UploadedAt,
-			&rec.IsDeleted,
-			&tagsJSON,
-			&rec.DownloadCount,
-			&blob.ID,
-			&blob.Sha256,
-			&blob.SizeBytes,
-			&blob.MimeDetected,
-			&blob.StorageKey,
-			&blob.RefCount,
-			&blob.CreatedAt,
-		); err != nil {
+		entry, err := scanFileWithBlob(rows, &total)
+		if err != nil {
 			return nil, 0, err
 		}
-
-		if len(tagsJSON) > 0 {
-			_ = json.Unmarshal(tagsJSON, &rec.Tags)
-		} else {
-			rec.Tags = []string{}
-		}
-
-		files = append(files, FileWithBlob{File: rec, Blob: blob})
+		files = append(files, entry)
 	}
-
-	countQuery := fmt.Sprintf(`
-        select count(*)
-        from files f
-        join file_blobs b on f.blob_id = b.id
-        where %s
-    `, whereClause)
-
-	argsCopy := make([]any, len(args))
-	copy(argsCopy, args)
-
-	var total int
-	if err := p.QueryRow(ctx, countQuery, argsCopy...).Scan(&total); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, 0, err
 	}
-
 	return files, total, nil
 }
 
 // ListPublicFiles returns publicly shared files (shares.visibility = 'PUBLIC' and not expired)
 // with optional filters including uploader name/id. Results exclude deleted files.
-func (p *Pool) ListPublicFiles(ctx context.Context, filter *FileFilter) ([]FileWithBlob, int, error) {
+// after continues a previous page.
+func (p *Pool) ListPublicFiles(ctx context.Context, filter *FileFilter, after *FileCursor, limit int) ([]FileWithBlob, int, error) {
 	args := []any{}
 	// Only include files with a PUBLIC share that is not expired and has a valid token
 	where := []string{
 		"f.is_deleted = false",
+		"f.hidden_from_public = false",
+		"(f.expires_at is null or f.expires_at > now())",
 		"s.visibility = 'PUBLIC'",
 		"(s.expires_at is null or s.expires_at > now())",
 		"(s.token is not null and s.token <> '')",
 	}
 
+	args, where = appendFileFilter(filter, args, where)
 	if filter != nil {
-		if filter.Search != nil && *filter.Search != "" {
-			args = append(args, "%"+strings.ToLower(*filter.Search)+"%")
-			where = append(where, fmt.Sprintf("f.filename_normalized LIKE $%d", len(args)))
-		}
-		if len(filter.MimeTypes) > 0 {
-			args = append(args, filter.MimeTypes)
-			where = append(where, fmt.Sprintf("(coalesce(f.mime_declared, b.mime_detected) = ANY($%d))", len(args)))
-		}
-		if filter.MinSize != nil {
-			args = append(args, *filter.MinSize)
-			where = append(where, fmt.Sprintf("f.size_bytes_original >= $%d", len(args)))
-		}
-		if filter.MaxSize != nil {
-			args = append(args, *filter.MaxSize)
-			where = append(where, fmt.Sprintf("f.size_bytes_original <= $%d", len(args)))
-		}
-		if len(filter.Tags) > 0 {
-			if tagsJSON, err := json.Marshal(filter.Tags); err == nil {
-				args = append(args, string(tagsJSON))
-				where = append(where, fmt.Sprintf("f.tags @> $%d", len(args)))
-			}
-		}
-		if filter.UploadedFrom != nil {
-			args = append(args, *filter.UploadedFrom)
-			where = append(where, fmt.Sprintf("f.uploaded_at >= $%d", len(args)))
-		}
-		if filter.UploadedTo != nil {
-			args = append(args, *filter.UploadedTo)
-			where = append(where, fmt.Sprintf("f.uploaded_at <= $%d", len(args)))
-		}
 		if filter.UploaderName != nil && *filter.UploaderName != "" {
 			args = append(args, "%"+strings.ToLower(*filter.UploaderName)+"%")
 			where = append(where, fmt.Sprintf("(lower(u.name) LIKE $%d or lower(u.email) LIKE $%d)", len(args), len(args)))
@@ -325,80 +556,11 @@ func (p *Pool) ListPublicFiles(ctx context.Context, filter *FileFilter) ([]FileW
 		}
 	}
 
-	whereClause := strings.Join(where, " AND ")
-
-	query := fmt.Sprintf(`
-		select f.id, f.owner_id, f.blob_id, f.filename_original, f.filename_normalized,
-			   f.mime_declared, f.size_bytes_original, f.uploaded_at, f.is_deleted, f.tags, f.download_count,
-			   b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at
-		from shares s
-		join files f on s.file_id = f.id
-		join file_blobs b on f.blob_id = b.id
-		join users u on u.id = f.owner_id
-		where %s
-		order by f.uploaded_at desc
-		limit 200
-	`, whereClause)
-
-	rows, err := p.Query(ctx, query, args...)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	files := make([]FileWithBlob, 0)
-	for rows.Next() {
-		var rec FileRecord
-		var blob FileBlob
-		var tagsJSON []byte
-		if err := rows.Scan(
-			&rec.ID,
-			&rec.OwnerID,
-			&rec.BlobID,
-			&rec.FilenameOriginal,
-			&rec.FilenameNormalized,
-			&rec.MimeDeclared,
-			&rec.SizeBytesOriginal,
-			&rec.UploadedAt,
-			&rec.IsDeleted,
-			&tagsJSON,
-			&rec.DownloadCount,
-			&blob.ID,
-			&blob.Sha256,
-			&blob.SizeBytes,
-			&blob.MimeDetected,
-			&blob.StorageKey,
-			&blob.RefCount,
-			&blob.CreatedAt,
-		); err != nil {
-			return nil, 0, err
-		}
-		if len(tagsJSON) > 0 {
-			_ = json.Unmarshal(tagsJSON, &rec.Tags)
-		} else {
-			rec.Tags = []string{}
-		}
-		files = append(files, FileWithBlob{File: rec, Blob: blob})
-	}
-
-	countQuery := fmt.Sprintf(`
-		select count(*)
-		from shares s
-		join files f on s.file_id = f.id
-		join file_blobs b on f.blob_id = b.id
-		join users u on u.id = f.owner_id
-		where %s
-	`, whereClause)
-
-	argsCopy := make([]any, len(args))
-	copy(argsCopy, args)
-
-	var total int
-	if err := p.QueryRow(ctx, countQuery, argsCopy...).Scan(&total); err != nil {
-		return nil, 0, err
-	}
-
-	return files, total, nil
+	return p.listFilesPage(ctx, `
+            from shares s
+            join files f on s.file_id = f.id
+            join file_blobs b on f.blob_id = b.id
+            join users u on u.id = f.owner_id`, where, args, after, limit)
 }
 
 func (p *Pool) MarkFileDeleted(ctx context.Context, fileID, ownerID uuid.UUID) (*FileRecord, error) {
@@ -437,20 +599,130 @@ func (p *Pool) MarkFileDeleted(ctx context.Context, fileID, ownerID uuid.UUID) (
 	return &rec, nil
 }
 
+// GetFileWithBlob returns an owned live file from the replica. Use it for
+// listings and downloads; ownership checks that precede a write must use
+// GetFileWithBlobPrimary so a file uploaded moments ago is found.
 func (p *Pool) GetFileWithBlob(ctx context.Context, fileID, ownerID uuid.UUID) (*FileWithBlob, error) {
+	return getFileWithBlob(ctx, p.reader(), fileID, ownerID)
+}
+
+// GetFileWithBlobPrimary is GetFileWithBlob read from the primary.
+func (p *Pool) GetFileWithBlobPrimary(ctx context.Context, fileID, ownerID uuid.UUID) (*FileWithBlob, error) {
+	return getFileWithBlob(ctx, p.primary(), fileID, ownerID)
+}
+
+func getFileWithBlob(ctx context.Context, q querier, fileID, ownerID uuid.UUID) (*FileWithBlob, error) {
 	const query = `
-        select f.id, f.owner_id, f.blob_id, f.filename_original, f.filename_normalized,
-               f.mime_declared, f.size_bytes_original, f.uploaded_at, f.is_deleted, f.tags, f.download_count,
-               b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at
+        select ` + fileWithBlobColumns + `
         from files f
         join file_blobs b on f.blob_id = b.id
         where f.id = $1 and f.owner_id = $2 and f.is_deleted = false
     `
 
+	entry, err := scanFileWithBlob(q.QueryRow(ctx, query, fileID, ownerID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetFilesByIDs returns the owner's live files among ids in a single query.
+// Results follow the order of ids; unknown, deleted, expired or foreign ids
+// are left out and repeated ids are returned once.
+func (p *Pool) GetFilesByIDs(ctx context.Context, ownerID uuid.UUID, ids []uuid.UUID) ([]FileWithBlob, error) {
+	if len(ids) == 0 {
+		return []FileWithBlob{}, nil
+	}
+
+	const query = `
+        select ` + fileWithBlobColumns + `
+        from files f
+        join file_blobs b on f.blob_id = b.id
+        where f.owner_id = $1 and f.id = any($2) and f.is_deleted = false
+          and (f.expires_at is null or f.expires_at > now())
+    `
+
+	rows, err := p.reader().Query(ctx, query, ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[uuid.UUID]FileWithBlob, len(ids))
+	for rows.Next() {
+		entry, err := scanFileWithBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		byID[entry.File.ID] = entry
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	files := make([]FileWithBlob, 0, len(byID))
+	for _, id := range ids {
+		if entry, ok := byID[id]; ok {
+			files = append(files, entry)
+			delete(byID, id)
+		}
+	}
+	return files, nil
+}
+
+// CountOwnedFiles counts the owner's live files.
+func (p *Pool) CountOwnedFiles(ctx context.Context, ownerID uuid.UUID) (int, error) {
+	const query = `select count(*) from files where owner_id = $1 and is_deleted = false`
+	var count int
+	if err := p.QueryRow(ctx, query, ownerID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FindOwnedFileByHash returns the owner's most recent live file whose content
+// has the given SHA-256, or nil when the owner has no such file.
+func (p *Pool) FindOwnedFileByHash(ctx context.Context, ownerID uuid.UUID, hash string) (*FileWithBlob, error) {
+	const query = `
+        select ` + fileWithBlobColumns + `
+        from files f
+        join file_blobs b on f.blob_id = b.id
+        where f.owner_id = $1 and b.sha256 = $2 and f.is_deleted = false
+          and (f.expires_at is null or f.expires_at > now())
+        order by f.uploaded_at desc
+        limit 1
+    `
+
+	entry, err := scanFileWithBlob(p.reader().QueryRow(ctx, query, ownerID, hash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindRecentDuplicate returns a non-deleted file owned by ownerID that points at
+// blobID with the same original filename and was uploaded at or after since.
+// It lets retried uploads resolve to the record created by the first attempt.
+func (p *Pool) FindRecentDuplicate(ctx context.Context, ownerID, blobID uuid.UUID, filename string, since time.Time) (*FileRecord, error) {
+	const query = `
+        select id, owner_id, blob_id, filename_original, filename_normalized,
+               mime_declared, size_bytes_original, uploaded_at, is_deleted, tags, download_count, description
+        from files
+        where owner_id = $1 and blob_id = $2 and filename_original = $3
+          and uploaded_at >= $4 and is_deleted = false
+        order by uploaded_at desc
+        limit 1
+    `
+
 	var rec FileRecord
-	var blob FileBlob
 	var tagsJSON []byte
-	err := p.QueryRow(ctx, query, fileID, ownerID).Scan(
+	err := p.QueryRow(ctx, query, ownerID, blobID, filename, since).Scan(
 		&rec.ID,
 		&rec.OwnerID,
 		&rec.BlobID,
@@ -462,13 +734,7 @@ func (p *Pool) GetFileWithBlob(ctx context.Context, fileID, ownerID uuid.UUID) (
 		&rec.IsDeleted,
 		&tagsJSON,
 		&rec.DownloadCount,
-		&blob.ID,
-		&blob.Sha256,
-		&blob.SizeBytes,
-		&blob.MimeDetected,
-		&blob.StorageKey,
-		&blob.RefCount,
-		&blob.CreatedAt,
+		&rec.Description,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -481,22 +747,23 @@ func (p *Pool) GetFileWithBlob(ctx context.Context, fileID, ownerID uuid.UUID) (
 	} else {
 		rec.Tags = []string{}
 	}
-
-	return &FileWithBlob{File: rec, Blob: blob}, nil
+	return &rec, nil
 }
 
 func (p *Pool) GetFileByShareToken(ctx context.Context, token string) (*FileRecord, *FileBlob, *ShareRecord, error) {
 	const query = `
         select f.id, f.owner_id, f.blob_id, f.filename_original, f.filename_normalized,
-               f.mime_declared, f.size_bytes_original, f.uploaded_at, f.tags, f.download_count,
-               b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at,
-               s.id, s.visibility, s.token, s.expires_at
+               f.mime_declared, f.size_bytes_original, f.uploaded_at, f.tags, f.download_count, f.expires_at,
+               f.description, b.id, b.sha256, b.size_bytes, b.mime_detected, b.storage_key, b.ref_count, b.created_at, b.chunked,
+               b.encoding, coalesce(b.stored_size_bytes, b.size_bytes), b.width, b.height,
+               s.id, s.visibility, s.token, s.expires_at, s.notify_on_download
         from shares s
         join files f on s.file_id = f.id
         join file_blobs b on f.blob_id = b.id
 				where s.token = $1
 					and (s.expires_at is null or s.expires_at > now())
           and f.is_deleted = false
+          and (f.expires_at is null or f.expires_at > now())
     `
 
 	var file FileRecord
@@ -515,6 +782,8 @@ func (p *Pool) GetFileByShareToken(ctx context.Context, token string) (*FileReco
 		&file.UploadedAt,
 		&tagsJSON,
 		&file.DownloadCount,
+		&file.ExpiresAt,
+		&file.Description,
 		&blob.ID,
 		&blob.Sha256,
 		&blob.SizeBytes,
@@ -522,10 +791,16 @@ func (p *Pool) GetFileByShareToken(ctx context.Context, token string) (*FileReco
 		&blob.StorageKey,
 		&blob.RefCount,
 		&blob.CreatedAt,
+		&blob.Chunked,
+		&blob.Encoding,
+		&blob.StoredSizeBytes,
+		&blob.Width,
+		&blob.Height,
 		&share.ID,
 		&share.Visibility,
 		&share.Token,
 		&share.ExpiresAt,
+		&share.NotifyOnDownload,
 	)
 	if err != nil {
 		return nil, nil, nil, err
@@ -540,54 +815,207 @@ func (p *Pool) GetFileByShareToken(ctx context.Context, token string) (*FileReco
 	return &file, &blob, &share, nil
 }
 
+// SetFileExpiry sets or, with a nil expires, clears the expiry of an owned file.
+func (p *Pool) SetFileExpiry(ctx context.Context, fileID, ownerID uuid.UUID, expires *time.Time) (bool, error) {
+	const stmt = `update files set expires_at = $3 where id = $1 and owner_id = $2 and is_deleted = false`
+	tag, err := p.Exec(ctx, stmt, fileID, ownerID, expires)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetFileDescription sets or, with nil, clears the description of a live file.
+func (p *Pool) SetFileDescription(ctx context.Context, fileID, ownerID uuid.UUID, description *string) (bool, error) {
+	const stmt = `update files set description = $3 where id = $1 and owner_id = $2 and is_deleted = false`
+	tag, err := p.Exec(ctx, stmt, fileID, ownerID, description)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// FileRef identifies a file together with its owner.
+type FileRef struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+}
+
+// ListExpiredFiles returns up to limit non-deleted files whose expiry has passed.
+func (p *Pool) ListExpiredFiles(ctx context.Context, limit int) ([]FileRef, error) {
+	const query = `
+        select id, owner_id
+        from files
+        where expires_at is not null and expires_at <= now() and is_deleted = false
+        order by expires_at
+        limit $1
+    `
+	rows, err := p.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []FileRef
+	for rows.Next() {
+		var ref FileRef
+		if err := rows.Scan(&ref.ID, &ref.OwnerID); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// GetFileOwner returns the owner of a non-deleted file, or nil when there is none.
+func (p *Pool) GetFileOwner(ctx context.Context, fileID uuid.UUID) (*uuid.UUID, error) {
+	var ownerID uuid.UUID
+	err := p.QueryRow(ctx, `select owner_id from files where id = $1 and is_deleted = false`, fileID).Scan(&ownerID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ownerID, nil
+}
+
+// SetFileHiddenFromPublic sets the file's public gallery flag. It returns false
+// when no live file has the id.
+func (p *Pool) SetFileHiddenFromPublic(ctx context.Context, fileID uuid.UUID, hidden bool) (bool, error) {
+	const stmt = `update files set hidden_from_public = $2 where id = $1 and is_deleted = false`
+	tag, err := p.Exec(ctx, stmt, fileID, hidden)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 func (p *Pool) IncrementDownload(ctx context.Context, fileID uuid.UUID) error {
 	const stmt = `update files set download_count = download_count + 1 where id = $1`
 	_, err := p.Exec(ctx, stmt, fileID)
 	return err
 }
 
-func (p *Pool) UpsertShare(ctx context.Context, fileID uuid.UUID, visibility string, token *string, expires *time.Time) (*ShareRecord, error) {
+// ErrShareTokenTaken reports that another share already uses the token.
+var ErrShareTokenTaken = errors.New("share token already in use")
+
+const uniqueViolationCode = "23505"
+
+// shareTokenConflict maps a unique violation on shares.token to
+// ErrShareTokenTaken and returns every other error unchanged.
+func shareTokenConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == "shares_token_key" {
+		return fmt.Errorf("%w: %v", ErrShareTokenTaken, err)
+	}
+	return err
+}
+
+func (p *Pool) UpsertShare(ctx context.Context, fileID uuid.UUID, visibility string, token *string, expires *time.Time, notify bool) (*ShareRecord, error) {
 	const stmt = `
-        insert into shares (file_id, visibility, token, expires_at)
-        values ($1, $2, $3, $4)
+        insert into shares (file_id, visibility, token, expires_at, notify_on_download)
+        values ($1, $2, $3, $4, $5)
         on conflict (file_id)
             do update set visibility = excluded.visibility,
                           token = excluded.token,
-                          expires_at = excluded.expires_at
-        returning id, file_id, visibility, token, expires_at
+                          expires_at = excluded.expires_at,
+                          notify_on_download = excluded.notify_on_download
+        returning id, file_id, visibility, token, expires_at, notify_on_download
     `
 	var share ShareRecord
-	err := p.QueryRow(ctx, stmt, fileID, visibility, token, expires).Scan(
+	err := p.QueryRow(ctx, stmt, fileID, visibility, token, expires, notify).Scan(
 		&share.ID,
 		&share.FileID,
 		&share.Visibility,
 		&share.Token,
 		&share.ExpiresAt,
+		&share.NotifyOnDownload,
 	)
 	if err != nil {
+		return nil, shareTokenConflict(err)
+	}
+	return &share, nil
+}
+
+// UpdateShareExpiry changes only the share's expires_at, keeping its token and
+// visibility so links already handed out stay valid. It returns nil when the
+// file has no share.
+func (p *Pool) UpdateShareExpiry(ctx context.Context, fileID uuid.UUID, expires *time.Time) (*ShareRecord, error) {
+	const stmt = `
+        update shares
+        set expires_at = $2
+        where file_id = $1
+        returning id, file_id, visibility, token, expires_at, notify_on_download
+    `
+	var share ShareRecord
+	err := p.QueryRow(ctx, stmt, fileID, expires).Scan(
+		&share.ID,
+		&share.FileID,
+		&share.Visibility,
+		&share.Token,
+		&share.ExpiresAt,
+		&share.NotifyOnDownload,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return &share, nil
 }
 
+// RotateShareToken replaces the share's token, keeping its visibility and
+// expiry. The old token stops resolving as soon as the update commits. It
+// returns nil when the file has no share.
+func (p *Pool) RotateShareToken(ctx context.Context, fileID uuid.UUID, newToken string) (*ShareRecord, error) {
+	const stmt = `
+        update shares
+        set token = $2
+        where file_id = $1
+        returning id, file_id, visibility, token, expires_at, notify_on_download
+    `
+	var share ShareRecord
+	err := p.QueryRow(ctx, stmt, fileID, newToken).Scan(
+		&share.ID,
+		&share.FileID,
+		&share.Visibility,
+		&share.Token,
+		&share.ExpiresAt,
+		&share.NotifyOnDownload,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, shareTokenConflict(err)
+	}
+	return &share, nil
+}
+
 func (p *Pool) DeleteShare(ctx context.Context, fileID uuid.UUID) error {
 	const stmt = `delete from shares where file_id = $1`
 	_, err := p.Exec(ctx, stmt, fileID)
 	return err
 }
 
+// GetShareByFileID returns the link share of a live file. Shares of
+// soft-deleted files are kept so a restore brings them back, but until then
+// they are reported as missing, like in GetFileByShareToken.
 func (p *Pool) GetShareByFileID(ctx context.Context, fileID uuid.UUID) (*ShareRecord, error) {
 	const query = `
-        select id, file_id, visibility, token, expires_at
-        from shares
-        where file_id = $1
+        select s.id, s.file_id, s.visibility, s.token, s.expires_at, s.notify_on_download
+        from shares s
+        join files f on f.id = s.file_id
+        where s.file_id = $1 and f.is_deleted = false
     `
 
 	var share ShareRecord
 	var token pgtype.Text
 	var expires pgtype.Timestamptz
 
-	err := p.QueryRow(ctx, query, fileID).Scan(&share.ID, &share.FileID, &share.Visibility, &token, &expires)
+	err := p.QueryRow(ctx, query, fileID).Scan(&share.ID, &share.FileID, &share.Visibility, &token, &expires, &share.NotifyOnDownload)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil
@@ -606,27 +1034,204 @@ func (p *Pool) GetShareByFileID(ctx context.Context, fileID uuid.UUID) (*ShareRe
 	return &share, nil
 }
 
+// StorageUsage returns the owner's original bytes (every live file counted) and
+// deduplicated bytes (each distinct blob counted once, by blob ID).
 func (p *Pool) StorageUsage(ctx context.Context, ownerID uuid.UUID) (int64, int64, error) {
+	return storageUsage(ctx, p.reader(), ownerID)
+}
+
+// StorageUsagePrimary is StorageUsage read from the primary, for quota checks
+// that must see the owner's latest uploads.
+func (p *Pool) StorageUsagePrimary(ctx context.Context, ownerID uuid.UUID) (int64, int64, error) {
+	return storageUsage(ctx, p.primary(), ownerID)
+}
+
+func storageUsage(ctx context.Context, q querier, ownerID uuid.UUID) (int64, int64, error) {
 	const originalQuery = `
         select coalesce(sum(size_bytes_original), 0)
         from files
         where owner_id = $1 and is_deleted = false
     `
 	var original int64
-	if err := p.QueryRow(ctx, originalQuery, ownerID).Scan(&original); err != nil {
+	if err := q.QueryRow(ctx, originalQuery, ownerID).Scan(&original); err != nil {
 		return 0, 0, err
 	}
 
 	const dedupQuery = `
-        select coalesce(sum(distinct b.size_bytes), 0)
-        from files f
-        join file_blobs b on f.blob_id = b.id
-        where f.owner_id = $1 and f.is_deleted = false
+        select coalesce(sum(b.size_bytes), 0)
+        from file_blobs b
+        where b.id in (
+            select blob_id from files where owner_id = $1 and is_deleted = false
+        )
     `
 	var dedup int64
-	if err := p.QueryRow(ctx, dedupQuery, ownerID).Scan(&dedup); err != nil {
+	if err := q.QueryRow(ctx, dedupQuery, ownerID).Scan(&dedup); err != nil {
 		return 0, 0, err
 	}
 
 	return original, dedup, nil
 }
+
+// CountFilesSharingBlobs counts the owner's live files whose content is also
+// stored by another user's live file.
+func (p *Pool) CountFilesSharingBlobs(ctx context.Context, ownerID uuid.UUID) (int, error) {
+	const query = `
+        select count(*)
+        from files f
+        where f.owner_id = $1
+          and f.is_deleted = false
+          and exists (
+              select 1 from files o
+              where o.blob_id = f.blob_id and o.owner_id <> f.owner_id and o.is_deleted = false
+          )
+    `
+	var count int
+	if err := p.reader().QueryRow(ctx, query, ownerID).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DuplicateGroup is a set of one owner's files with identical content.
+type DuplicateGroup struct {
+	Blob  FileBlob
+	Files []FileRecord
+}
+
+// FindDuplicateFiles groups the owner's live, unexpired files by blob and returns
+// the groups holding more than one file, largest content first. Files within a
+// group are oldest first.
+func (p *Pool) FindDuplicateFiles(ctx context.Context, ownerID uuid.UUID) ([]DuplicateGroup, error) {
+	query := fmt.Sprintf(`
+        with owned as (
+            select f.id, count(*) over (partition by f.blob_id) as copies
+            from files f
+            where f.owner_id = $1
+              and f.is_deleted = false
+              and (f.expires_at is null or f.expires_at > now())
+        )
+        select %s
+        from owned o
+        join files f on f.id = o.id
+        join file_blobs b on f.blob_id = b.id
+        where o.copies > 1
+        order by b.size_bytes desc, b.id, f.uploaded_at
+    `, fileWithBlobColumns)
+
+	rows, err := p.reader().Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		entry, err := scanFileWithBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(groups); n == 0 || groups[n-1].Blob.ID != entry.Blob.ID {
+			groups = append(groups, DuplicateGroup{Blob: entry.Blob})
+		}
+		last := &groups[len(groups)-1]
+		last.Files = append(last.Files, entry.File)
+	}
+	return groups, rows.Err()
+}
+
+// TrashUsage summarizes an owner's soft-deleted files.
+type TrashUsage struct {
+	Files         int
+	OriginalBytes int64
+	// ReclaimableBytes counts each blob once, and only blobs that no other file
+	// row references: purging the trash would remove their last reference.
+	ReclaimableBytes int64
+}
+
+// TrashUsage reports how much the owner's soft-deleted files occupy. A blob still
+// referenced by a live file, or by any other user's file, is not reclaimable.
+func (p *Pool) TrashUsage(ctx context.Context, ownerID uuid.UUID) (*TrashUsage, error) {
+	const query = `
+        with trashed as (
+            select blob_id, size_bytes_original
+            from files
+            where owner_id = $1 and is_deleted = true
+        )
+        select count(*),
+               coalesce(sum(size_bytes_original), 0),
+               (
+                   select coalesce(sum(b.size_bytes), 0)
+                   from file_blobs b
+                   where b.id in (select blob_id from trashed)
+                     and not exists (
+                         select 1 from files o
+                         where o.blob_id = b.id and (o.is_deleted = false or o.owner_id <> $1)
+                     )
+               )
+        from trashed
+    `
+	var usage TrashUsage
+	if err := p.reader().QueryRow(ctx, query, ownerID).Scan(&usage.Files, &usage.OriginalBytes, &usage.ReclaimableBytes); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// UserUsage is a user's storage usage together with their stored quota.
+type UserUsage struct {
+	OriginalBytes int64
+	DedupedBytes  int64
+	QuotaBytes    int64
+	Role          string
+}
+
+// UserUsage returns the same sums as StorageUsage plus the user's quota and role
+// in a single round trip. It returns nil when the user does not exist.
+func (p *Pool) UserUsage(ctx context.Context, ownerID uuid.UUID) (*UserUsage, error) {
+	const query = `
+        select
+            (select coalesce(sum(size_bytes_original), 0)
+             from files
+             where owner_id = u.id and is_deleted = false),
+            (select coalesce(sum(b.size_bytes), 0)
+             from file_blobs b
+             where b.id in (select blob_id from files where owner_id = u.id and is_deleted = false)),
+            u.quota_bytes,
+            u.role
+        from users u
+        where u.id = $1
+    `
+	var usage UserUsage
+	err := p.reader().QueryRow(ctx, query, ownerID).Scan(&usage.OriginalBytes, &usage.DedupedBytes, &usage.QuotaBytes, &usage.Role)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// TotalBlobBytes returns the decoded size of every distinct blob in the system.
+func (p *Pool) TotalBlobBytes(ctx context.Context) (int64, error) {
+	var total int64
+	err := p.QueryRow(ctx, `select coalesce(sum(size_bytes), 0) from file_blobs`).Scan(&total)
+	return total, err
+}
+
+// StoredUsage sums the stored (post-compression) size of each distinct blob
+// referenced by the owner's non-deleted files.
+func (p *Pool) StoredUsage(ctx context.Context, ownerID uuid.UUID) (int64, error) {
+	const query = `
+        select coalesce(sum(coalesce(b.stored_size_bytes, b.size_bytes)), 0)
+        from file_blobs b
+        where b.id in (
+            select blob_id from files where owner_id = $1 and is_deleted = false
+        )
+    `
+	var stored int64
+	if err := p.QueryRow(ctx, query, ownerID).Scan(&stored); err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db/dbtest"
+)
+
+func TestStorageUsageCountsEqualSizedBlobsSeparately(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+
+	// Same length, different content: two distinct blobs of 8 bytes each.
+	dbtest.InsertFile(t, pool, owner.ID, "a.txt", "aaaaaaaa")
+	dbtest.InsertFile(t, pool, owner.ID, "b.txt", "bbbbbbbb")
+
+	original, deduped, err := pool.StorageUsage(context.Background(), owner.ID)
+	if err != nil {
+		t.Fatalf("StorageUsage: %v", err)
+	}
+	if original != 16 {
+		t.Errorf("original usage = %d, want 16", original)
+	}
+	if deduped != 16 {
+		t.Errorf("deduped usage = %d, want 16 (both blobs counted)", deduped)
+	}
+}
+
+func TestGetFilesByIDsSkipsExpiredFiles(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+
+	live := dbtest.InsertFile(t, pool, owner.ID, "live.txt", "still here")
+	expired := dbtest.InsertFile(t, pool, owner.ID, "expired.txt", "already gone")
+	past := time.Now().Add(-time.Hour)
+	if ok, err := pool.SetFileExpiry(ctx, expired.File.ID, owner.ID, &past); err != nil || !ok {
+		t.Fatalf("SetFileExpiry: %v, %v", ok, err)
+	}
+
+	got, err := pool.GetFilesByIDs(ctx, owner.ID, []uuid.UUID{expired.File.ID, live.File.ID})
+	if err != nil {
+		t.Fatalf("GetFilesByIDs: %v", err)
+	}
+	if len(got) != 1 || got[0].File.ID != live.File.ID {
+		t.Fatalf("GetFilesByIDs returned %d files, want only %s", len(got), live.File.ID)
+	}
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type FolderShareRecord struct {
+	ID           uuid.UUID
+	FolderID     uuid.UUID
+	OwnerID      uuid.UUID
+	Token        string
+	ExpiresAt    *time.Time
+	PasswordHash *string
+	CreatedAt    time.Time
+}
+
+func (p *Pool) UpsertFolderShare(ctx context.Context, folderID, ownerID uuid.UUID, token string, expires *time.Time, passwordHash *string) (*FolderShareRecord, error) {
+	const stmt = `
+        insert into folder_shares (folder_id, owner_id, token, expires_at, password_hash)
+        values ($1, $2, $3, $4, $5)
+        on conflict (folder_id)
+            do update set expires_at = excluded.expires_at,
+                          password_hash = excluded.password_hash
+        returning id, folder_id, owner_id, token, expires_at, password_hash, created_at
+    `
+	var share FolderShareRecord
+	err := p.QueryRow(ctx, stmt, folderID, ownerID, token, expires, passwordHash).Scan(
+		&share.ID,
+		&share.FolderID,
+		&share.OwnerID,
+		&share.Token,
+		&share.ExpiresAt,
+		&share.PasswordHash,
+		&share.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (p *Pool) DeleteFolderShare(ctx context.Context, folderID, ownerID uuid.UUID) (bool, error) {
+	const stmt = `delete from folder_shares where folder_id = $1 and owner_id = $2`
+	tag, err := p.Exec(ctx, stmt, folderID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetFolderShareByToken returns the folder share for token when it has not expired.
+func (p *Pool) GetFolderShareByToken(ctx context.Context, token string) (*FolderShareRecord, error) {
+	const query = `
+        select id, folder_id, owner_id, token, expires_at, password_hash, created_at
+        from folder_shares
+        where token = $1
+          and (expires_at is null or expires_at > now())
+    `
+	var share FolderShareRecord
+	err := p.QueryRow(ctx, query, token).Scan(
+		&share.ID,
+		&share.FolderID,
+		&share.OwnerID,
+		&share.Token,
+		&share.ExpiresAt,
+		&share.PasswordHash,
+		&share.CreatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &share, nil
+}
+
+// GetFileInFolderTree returns a non-deleted file owned by ownerID when it lives
+// anywhere under the folder rootID.
+func (p *Pool) GetFileInFolderTree(ctx context.Context, ownerID, rootID, fileID uuid.UUID) (*FileWithBlob, error) {
+	const query = `
+        with recursive folder_tree as (
+            select id from folders where id = $2 and owner_id = $1
+            union all
+            select c.id from folders c join folder_tree ft on c.parent_id = ft.id
+        )
+        select ` + fileWithBlobColumns + `
+        from files f
+        join file_blobs b on f.blob_id = b.id
+        where f.id = $3 and f.owner_id = $1 and f.is_deleted = false
+          and f.folder_id in (select id from folder_tree)
+    `
+
+	entry, err := scanFileWithBlob(p.QueryRow(ctx, query, ownerID, rootID, fileID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -2,10 +2,12 @@ package db
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -18,6 +20,20 @@ type Folder struct {
 	UpdatedAt time.Time
 }
 
+// ErrFolderNameConflict is returned when the parent already holds a folder with
+// the same name, compared case-insensitively.
+var ErrFolderNameConflict = errors.New("a folder with this name already exists here")
+
+// folderNameConflict maps a violation of uq_folders_owner_parent_name to
+// ErrFolderNameConflict and returns every other error unchanged.
+func folderNameConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == "uq_folders_owner_parent_name" {
+		return ErrFolderNameConflict
+	}
+	return err
+}
+
 func (p *Pool) CreateFolder(ctx context.Context, ownerID uuid.UUID, name string, parentID *uuid.UUID) (*Folder, error) {
 	const stmt = `
         insert into folders (owner_id, parent_id, name)
@@ -37,7 +53,7 @@ func (p *Pool) CreateFolder(ctx context.Context, ownerID uuid.UUID, name string,
 		&folder.UpdatedAt,
 	)
 	if err != nil {
-		return nil, err
+		return nil, folderNameConflict(err)
 	}
 
 	parentPtr, err := uuidPtrFromPG(parent)
@@ -72,7 +88,7 @@ func (p *Pool) RenameFolder(ctx context.Context, folderID, ownerID uuid.UUID, na
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
-		return nil, err
+		return nil, folderNameConflict(err)
 	}
 
 	parentPtr, err := uuidPtrFromPG(parent)
@@ -84,15 +100,6 @@ func (p *Pool) RenameFolder(ctx context.Context, folderID, ownerID uuid.UUID, na
 	return &folder, nil
 }
 
-func (p *Pool) DeleteFolder(ctx context.Context, folderID, ownerID uuid.UUID) (bool, error) {
-	const stmt = `delete from folders where id = $1 and owner_id = $2`
-	tag, err := p.Exec(ctx, stmt, folderID, ownerID)
-	if err != nil {
-		return false, err
-	}
-	return tag.RowsAffected() > 0, nil
-}
-
 func (p *Pool) GetFolderByID(ctx context.Context, folderID uuid.UUID) (*Folder, error) {
 	const query = `
         select id, owner_id, parent_id, name, created_at, updated_at
@@ -163,6 +170,47 @@ func (p *Pool) ListFolders(ctx context.Context, ownerID uuid.UUID, parentID *uui
 
 	return folders, nil
 }
+
+// ListFolderFiles returns up to limit of the owner's live files directly inside
+// folderID, or at the root when it is nil, newest first. includeLinked also
+// returns files linked to folderID through file_folders. after continues a
+// previous page.
+func (p *Pool) ListFolderFiles(ctx context.Context, ownerID uuid.UUID, folderID *uuid.UUID, includeLinked bool, after *FileCursor, limit int) ([]FileWithBlob, error) {
+	query := `
+        select ` + fileWithBlobColumns + `
+        from files f
+        join file_blobs b on f.blob_id = b.id
+        where f.owner_id = $1
+          and f.is_deleted = false
+          and (f.folder_id is not distinct from $2 or ($5 and exists (
+              select 1 from file_folders ff where ff.file_id = f.id and ff.folder_id = $2
+          )))
+          and ($3::timestamptz is null or (f.uploaded_at, f.id) < ($3, $6))
+        order by f.uploaded_at desc, f.id desc
+        limit $4
+    `
+	var afterAt *time.Time
+	var afterID *uuid.UUID
+	if after != nil {
+		afterAt, afterID = &after.UploadedAt, &after.ID
+	}
+	rows, err := p.reader().Query(ctx, query, ownerID, folderID, afterAt, limit, includeLinked, afterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make([]FileWithBlob, 0)
+	for rows.Next() {
+		entry, err := scanFileWithBlob(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, entry)
+	}
+	return files, rows.Err()
+}
+
 func (p *Pool) ListFolderTree(ctx context.Context, ownerID, rootID uuid.UUID) ([]Folder, error) {
 	const query = `
         with recursive folder_tree as (
@@ -205,3 +253,176 @@ func (p *Pool) ListFolderTree(ctx context.Context, ownerID, rootID uuid.UUID) ([
 
 	return folders, nil
 }
+
+// FolderPath returns the ancestor chain of folderID ordered from the root down to
+// the folder itself. It returns nil when the folder does not exist or is not
+// owned by ownerID.
+func (p *Pool) FolderPath(ctx context.Context, folderID, ownerID uuid.UUID) ([]Folder, error) {
+	const query = `
+        with recursive ancestors as (
+            select id, owner_id, parent_id, name, created_at, updated_at, 0 as depth
+            from folders
+            where id = $1 and owner_id = $2
+            union all
+            select f.id, f.owner_id, f.parent_id, f.name, f.created_at, f.updated_at, a.depth + 1
+            from folders f
+            join ancestors a on f.id = a.parent_id
+            where f.owner_id = $2
+        )
+        select id, owner_id, parent_id, name, created_at, updated_at
+        from ancestors
+        order by depth desc
+    `
+
+	rows, err := p.Query(ctx, query, folderID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := make([]Folder, 0)
+	for rows.Next() {
+		var folder Folder
+		var parent pgtype.UUID
+		if err := rows.Scan(&folder.ID, &folder.OwnerID, &parent, &folder.Name, &folder.CreatedAt, &folder.UpdatedAt); err != nil {
+			return nil, err
+		}
+		parentPtr, err := uuidPtrFromPG(parent)
+		if err != nil {
+			return nil, err
+		}
+		folder.ParentID = parentPtr
+		folders = append(folders, folder)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, nil
+	}
+
+	return folders, nil
+}
+
+// FolderDeleteSummary reports what DeleteFolderTree removed.
+type FolderDeleteSummary struct {
+	FilesDeleted   int
+	FoldersDeleted int
+}
+
+// DeleteFolderTree soft-deletes every file contained in folderIDs and removes
+// the folders themselves in a single transaction. Like MarkFileDeleted it leaves
+// blob references and shares alone; PurgeDeletedFiles releases them. Callers
+// are expected to pass a full subtree (see ListFolderTree).
+func (p *Pool) DeleteFolderTree(ctx context.Context, ownerID uuid.UUID, folderIDs []uuid.UUID) (FolderDeleteSummary, error) {
+	const deleteFilesStmt = `
+        with deleted as (
+            update files
+            set is_deleted = true
+            where owner_id = $1 and folder_id = any($2) and is_deleted = false
+            returning id
+        )
+        select count(*) from deleted
+    `
+	const deleteFoldersStmt = `delete from folders where owner_id = $1 and id = any($2)`
+
+	var summary FolderDeleteSummary
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, deleteFilesStmt, ownerID, folderIDs).Scan(&summary.FilesDeleted); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, deleteFoldersStmt, ownerID, folderIDs)
+		if err != nil {
+			return err
+		}
+		summary.FoldersDeleted = int(tag.RowsAffected())
+		return nil
+	})
+	if err != nil {
+		return FolderDeleteSummary{}, translateTimeout(err)
+	}
+	return summary, nil
+}
+
+// ErrFolderNotFound is returned when a destination folder does not exist or
+// belongs to another user.
+var ErrFolderNotFound = errors.New("folder not found")
+
+// MoveFiles sets the folder of every live file in fileIDs owned by ownerID and
+// returns the IDs that were moved. A nil folderID moves the files to the root.
+// The destination check and the update run in one transaction, with the folder
+// row locked so it cannot be deleted in between.
+func (p *Pool) MoveFiles(ctx context.Context, ownerID uuid.UUID, fileIDs []uuid.UUID, folderID *uuid.UUID) ([]uuid.UUID, error) {
+	const lockFolderStmt = `select 1 from folders where id = $1 and owner_id = $2 for share`
+	const moveStmt = `
+        update files
+        set folder_id = $3
+        where owner_id = $1 and id = any($2) and is_deleted = false
+        returning id
+    `
+
+	var moved []uuid.UUID
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		if folderID != nil {
+			var exists int
+			if err := tx.QueryRow(ctx, lockFolderStmt, *folderID, ownerID).Scan(&exists); err != nil {
+				if err == pgx.ErrNoRows {
+					return ErrFolderNotFound
+				}
+				return err
+			}
+		}
+
+		rows, err := tx.Query(ctx, moveStmt, ownerID, fileIDs, folderID)
+		if err != nil {
+			return err
+		}
+		moved, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrFolderNotFound) {
+			return nil, err
+		}
+		return nil, translateTimeout(err)
+	}
+	return moved, nil
+}
+
+// FolderStorageUsage mirrors StorageUsage for the subtree rooted at folderID,
+// returning the original and deduplicated byte totals of its non-deleted files.
+func (p *Pool) FolderStorageUsage(ctx context.Context, folderID, ownerID uuid.UUID) (int64, int64, error) {
+	const query = `
+        with recursive folder_tree as (
+            select id
+            from folders
+            where id = $1 and owner_id = $2
+            union all
+            select f.id
+            from folders f
+            join folder_tree ft on f.parent_id = ft.id
+        ),
+        tree_files as (
+            select f.size_bytes_original, f.blob_id
+            from files f
+            where f.owner_id = $2 and f.is_deleted = false
+              and f.folder_id in (select id from folder_tree)
+        )
+        select
+            (select coalesce(sum(size_bytes_original), 0) from tree_files),
+            (select coalesce(sum(b.size_bytes), 0)
+             from file_blobs b
+             where b.id in (select blob_id from tree_files))
+    `
+
+	var original, dedup int64
+	if err := p.QueryRow(ctx, query, folderID, ownerID).Scan(&original, &dedup); err != nil {
+		return 0, 0, err
+	}
+	return original, dedup, nil
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	GrantPermissionView     = "VIEW"
+	GrantPermissionDownload = "DOWNLOAD"
+)
+
+// ShareGrant gives a specific user access to a file they do not own.
+type ShareGrant struct {
+	ID           uuid.UUID
+	FileID       uuid.UUID
+	GranteeID    *uuid.UUID
+	GranteeEmail string
+	Permission   string
+	GrantedBy    uuid.UUID
+	CreatedAt    time.Time
+}
+
+func (p *Pool) UpsertShareGrant(ctx context.Context, fileID uuid.UUID, granteeEmail string, granteeID *uuid.UUID, permission string, grantedBy uuid.UUID) (*ShareGrant, error) {
+	const stmt = `
+        insert into share_grants (file_id, grantee_email, grantee_id, permission, granted_by)
+        values ($1, $2, $3, $4, $5)
+        on conflict (file_id, lower(grantee_email))
+            do update set permission = excluded.permission,
+                          grantee_id = coalesce(excluded.grantee_id, share_grants.grantee_id)
+        returning id, file_id, grantee_id, grantee_email, permission, granted_by, created_at
+    `
+	var grant ShareGrant
+	var grantee pgtype.UUID
+	err := p.QueryRow(ctx, stmt, fileID, granteeEmail, granteeID, permission, grantedBy).Scan(
+		&grant.ID,
+		&grant.FileID,
+		&grantee,
+		&grant.GranteeEmail,
+		&grant.Permission,
+		&grant.GrantedBy,
+		&grant.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	granteePtr, err := uuidPtrFromPG(grantee)
+	if err != nil {
+		return nil, err
+	}
+	grant.GranteeID = granteePtr
+	return &grant, nil
+}
+
+func (p *Pool) DeleteShareGrant(ctx context.Context, fileID uuid.UUID, granteeEmail string) (bool, error) {
+	const stmt = `delete from share_grants where file_id = $1 and lower(grantee_email) = lower($2)`
+	tag, err := p.Exec(ctx, stmt, fileID, granteeEmail)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (p *Pool) ListShareGrants(ctx context.Context, fileID uuid.UUID) ([]ShareGrant, error) {
+	const query = `
+        select id, file_id, grantee_id, grantee_email, permission, granted_by, created_at
+        from share_grants
+        where file_id = $1
+        order by created_at
+    `
+	rows, err := p.Query(ctx, query, fileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := make([]ShareGrant, 0)
+	for rows.Next() {
+		var grant ShareGrant
+		var grantee pgtype.UUID
+		if err := rows.Scan(&grant.ID, &grant.FileID, &grantee, &grant.GranteeEmail, &grant.Permission, &grant.GrantedBy, &grant.CreatedAt); err != nil {
+			return nil, err
+		}
+		granteePtr, err := uuidPtrFromPG(grantee)
+		if err != nil {
+			return nil, err
+		}
+		grant.GranteeID = granteePtr
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// GetGrantedFileWithBlob returns a non-deleted file that userID can reach through
+// a share grant, matched either by user ID or by the user's email, together with
+// the granted permission.
+func (p *Pool) GetGrantedFileWithBlob(ctx context.Context, fileID, userID uuid.UUID) (*FileWithBlob, string, error) {
+	const query = `
+        select ` + fileWithBlobColumns + `, g.permission
+        from share_grants g
+        join users u on u.id = $2
+        join files f on f.id = g.file_id
+        join file_blobs b on f.blob_id = b.id
+        where g.file_id = $1
+          and (g.grantee_id = u.id or lower(g.grantee_email) = lower(u.email))
+          and f.is_deleted = false
+        limit 1
+    `
+
+	var permission string
+	entry, err := scanFileWithBlob(p.QueryRow(ctx, query, fileID, userID), &permission)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	return &entry, permission, nil
+}
+
+// ListSharedWithMe returns non-deleted files other users granted userID access to,
+// newest grant first, along with the total number of matches.
+func (p *Pool) ListSharedWithMe(ctx context.Context, userID uuid.UUID, filter *FileFilter) ([]FileWithBlob, int, error) {
+	args := []any{userID}
+	where := []string{
+		"(g.grantee_id = u.id or lower(g.grantee_email) = lower(u.email))",
+		"f.owner_id <> u.id",
+		"f.is_deleted = false",
+		"(f.expires_at is null or f.expires_at > now())",
+	}
+	args, where = appendFileFilter(filter, args, where)
+
+	whereClause := strings.Join(where, " AND ")
+
+	query := fmt.Sprintf(`
+        select %s
+        from share_grants g
+        join users u on u.id = $1
+        join files f on f.id = g.file_id
+        join file_blobs b on f.blob_id = b.id
+        where %s
+        order by g.created_at desc
+        limit %d
+    `, fileWithBlobColumns, whereClause, DefaultListLimit)
+
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files := make([]FileWithBlob, 0)
+	for rows.Next() {
+		entry, err := scanFileWithBlob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	countQuery := fmt.Sprintf(`
+        select count(*)
+        from share_grants g
+        join users u on u.id = $1
+        join files f on f.id = g.file_id
+        join file_blobs b on f.blob_id = b.id
+        where %s
+    `, whereClause)
+
+	var total int
+	if err := p.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	return files, total, nil
+}
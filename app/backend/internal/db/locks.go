@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// unlockTimeout bounds releasing an advisory lock after the job's own context
+// may already have been cancelled by shutdown.
+const unlockTimeout = 5 * time.Second
+
+// WithAdvisoryLock runs fn while holding the session-level Postgres advisory lock
+// for name. The lock lives on a dedicated pooled connection, so it is released
+// when fn returns or, if the process dies, when the connection closes. It returns
+// false without running fn when another session holds the lock.
+func (p *Pool) WithAdvisoryLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error) {
+	if p == nil {
+		return false, errors.New("nil db pool")
+	}
+
+	conn, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("acquire lock connection: %w", err)
+	}
+	defer conn.Release()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, "select pg_try_advisory_lock(hashtext($1))", name).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("try advisory lock %s: %w", name, err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	defer func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, "select pg_advisory_unlock(hashtext($1))", name); err != nil {
+			// Closing the connection is the only other way to drop the lock.
+			log.Printf("release advisory lock %s failed, closing connection: %v", name, err)
+			_ = conn.Hijack().Close(unlockCtx)
+		}
+	}()
+
+	return true, fn(ctx)
+}
+
+// ClaimJobRun records a run of the named job now and reports true, unless the
+// last recorded run on any instance is younger than minAge; then it records
+// nothing and reports false.
+func (p *Pool) ClaimJobRun(ctx context.Context, name string, minAge time.Duration) (bool, error) {
+	if p == nil {
+		return false, errors.New("nil db pool")
+	}
+
+	const stmt = `
+        insert into job_runs (name, last_run_at)
+        values ($1, now())
+        on conflict (name) do update set last_run_at = now()
+            where job_runs.last_run_at <= now() - $2 * interval '1 second'
+        returning true
+    `
+	var claimed bool
+	err := p.QueryRow(ctx, stmt, name, minAge.Seconds()).Scan(&claimed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claim job run %s: %w", name, err)
+	}
+	return claimed, nil
+}
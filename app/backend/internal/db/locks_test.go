@@ -0,0 +1,36 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vault/internal/db/dbtest"
+)
+
+func TestClaimJobRunOncePerInterval(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	ctx := context.Background()
+
+	claim := func(name string, minAge time.Duration) bool {
+		t.Helper()
+		claimed, err := pool.ClaimJobRun(ctx, name, minAge)
+		if err != nil {
+			t.Fatalf("ClaimJobRun: %v", err)
+		}
+		return claimed
+	}
+
+	if !claim("sweep", time.Hour) {
+		t.Fatal("first run was not claimed")
+	}
+	if claim("sweep", time.Hour) {
+		t.Fatal("second run within the interval was claimed")
+	}
+	if !claim("snapshot", time.Hour) {
+		t.Fatal("another job's run was not claimed")
+	}
+	if !claim("sweep", 0) {
+		t.Fatal("run after the interval was not claimed")
+	}
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const LoginProviderGoogle = "google"
+
+// LoginEvent records one successful sign-in.
+type LoginEvent struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Provider  string
+	IP        *string
+	UserAgent *string
+	CreatedAt time.Time
+}
+
+// recordLoginSQL inserts the event and stamps users.last_login_at with the same
+// timestamp in one statement.
+const recordLoginSQL = `
+with event as (
+    insert into login_events (user_id, provider, ip, user_agent)
+    values ($1, $2, $3, $4)
+    returning created_at
+)
+update users
+set last_login_at = (select created_at from event)
+where id = $1;
+`
+
+const listLoginEventsSQL = `
+select id, user_id, provider, ip, user_agent, created_at
+from login_events
+where user_id = $1
+order by created_at desc
+limit $2;
+`
+
+func (p *Pool) RecordLogin(ctx context.Context, event LoginEvent) error {
+	if p == nil {
+		return errors.New("nil db pool")
+	}
+
+	if _, err := p.Exec(ctx, recordLoginSQL, event.UserID, event.Provider, event.IP, event.UserAgent); err != nil {
+		return fmt.Errorf("record login: %w", err)
+	}
+	return nil
+}
+
+// ListLoginEvents returns the user's most recent sign-ins, newest first.
+func (p *Pool) ListLoginEvents(ctx context.Context, userID uuid.UUID, limit int) ([]LoginEvent, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	rows, err := p.Query(ctx, listLoginEventsSQL, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list login events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []LoginEvent
+	for rows.Next() {
+		var e LoginEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Provider, &e.IP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan login event: %w", err)
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
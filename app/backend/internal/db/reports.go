@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	ReportStatusOpen      = "OPEN"
+	ReportStatusDismissed = "DISMISSED"
+	ReportStatusTakenDown = "TAKEN_DOWN"
+)
+
+// FileReport is an abuse report filed against a publicly shared file.
+type FileReport struct {
+	ID         uuid.UUID
+	FileID     uuid.UUID
+	ReporterID *uuid.UUID
+	ReporterIP *string
+	Reason     string
+	Note       *string
+	Status     string
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+	ResolvedBy *uuid.UUID
+}
+
+const fileReportColumns = `id, file_id, reporter_id, reporter_ip, reason, note, status, created_at, resolved_at, resolved_by`
+
+func scanFileReport(row pgx.Row) (FileReport, error) {
+	var report FileReport
+	err := row.Scan(
+		&report.ID,
+		&report.FileID,
+		&report.ReporterID,
+		&report.ReporterIP,
+		&report.Reason,
+		&report.Note,
+		&report.Status,
+		&report.CreatedAt,
+		&report.ResolvedAt,
+		&report.ResolvedBy,
+	)
+	return report, err
+}
+
+func (p *Pool) InsertFileReport(ctx context.Context, report FileReport) (*FileReport, error) {
+	const stmt = `
+        insert into file_reports (file_id, reporter_id, reporter_ip, reason, note)
+        values ($1, $2, $3, $4, $5)
+        returning ` + fileReportColumns
+	inserted, err := scanFileReport(p.QueryRow(ctx, stmt, report.FileID, report.ReporterID, report.ReporterIP, report.Reason, report.Note))
+	if err != nil {
+		return nil, err
+	}
+	return &inserted, nil
+}
+
+// ListFileReports returns the newest reports first, optionally limited to one status.
+func (p *Pool) ListFileReports(ctx context.Context, status *string, limit int) ([]FileReport, error) {
+	const query = `
+        select ` + fileReportColumns + `
+        from file_reports
+        where ($1::text is null or status = $1)
+        order by created_at desc
+        limit $2
+    `
+	rows, err := p.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []FileReport
+	for rows.Next() {
+		report, err := scanFileReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// HideFileIfReported hides the file from the public gallery when it has at least
+// threshold open reports, and reports whether this call hid it.
+func (p *Pool) HideFileIfReported(ctx context.Context, fileID uuid.UUID, threshold int) (bool, error) {
+	const stmt = `
+        update files
+        set hidden_from_public = true
+        where id = $1
+          and hidden_from_public = false
+          and (select count(*) from file_reports r where r.file_id = $1 and r.status = 'OPEN') >= $2
+    `
+	tag, err := p.Exec(ctx, stmt, fileID, threshold)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResolveFileReport closes a single open report with the given status.
+func (p *Pool) ResolveFileReport(ctx context.Context, reportID uuid.UUID, status string, resolvedBy uuid.UUID) (*FileReport, error) {
+	const stmt = `
+        update file_reports
+        set status = $2, resolved_at = now(), resolved_by = $3
+        where id = $1 and status = 'OPEN'
+        returning ` + fileReportColumns
+	report, err := scanFileReport(p.QueryRow(ctx, stmt, reportID, status, resolvedBy))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ResolveFileReportsForFile closes every open report against a file.
+func (p *Pool) ResolveFileReportsForFile(ctx context.Context, fileID uuid.UUID, status string, resolvedBy uuid.UUID) (int64, error) {
+	const stmt = `
+        update file_reports
+        set status = $2, resolved_at = now(), resolved_by = $3
+        where file_id = $1 and status = 'OPEN'
+    `
+	tag, err := p.Exec(ctx, stmt, fileID, status, resolvedBy)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// SavedSearch is a named FileFilter a user can re-run against their own files.
+type SavedSearch struct {
+	ID        uuid.UUID
+	OwnerID   uuid.UUID
+	Name      string
+	Filter    FileFilter
+	CreatedAt time.Time
+}
+
+func scanSavedSearch(row pgx.Row) (SavedSearch, error) {
+	var search SavedSearch
+	var filterJSON []byte
+	if err := row.Scan(&search.ID, &search.OwnerID, &search.Name, &filterJSON, &search.CreatedAt); err != nil {
+		return SavedSearch{}, err
+	}
+	if len(filterJSON) > 0 {
+		if err := json.Unmarshal(filterJSON, &search.Filter); err != nil {
+			return SavedSearch{}, err
+		}
+	}
+	return search, nil
+}
+
+func (p *Pool) InsertSavedSearch(ctx context.Context, ownerID uuid.UUID, name string, filter FileFilter) (*SavedSearch, error) {
+	const stmt = `
+        insert into saved_searches (owner_id, name, filter)
+        values ($1, $2, $3)
+        returning id, owner_id, name, filter, created_at
+    `
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	search, err := scanSavedSearch(p.QueryRow(ctx, stmt, ownerID, name, string(filterJSON)))
+	if err != nil {
+		return nil, err
+	}
+	return &search, nil
+}
+
+func (p *Pool) ListSavedSearches(ctx context.Context, ownerID uuid.UUID) ([]SavedSearch, error) {
+	const query = `
+        select id, owner_id, name, filter, created_at
+        from saved_searches
+        where owner_id = $1
+        order by lower(name)
+    `
+	rows, err := p.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []SavedSearch
+	for rows.Next() {
+		search, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+func (p *Pool) GetSavedSearch(ctx context.Context, id, ownerID uuid.UUID) (*SavedSearch, error) {
+	const query = `
+        select id, owner_id, name, filter, created_at
+        from saved_searches
+        where id = $1 and owner_id = $2
+    `
+	search, err := scanSavedSearch(p.QueryRow(ctx, query, id, ownerID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &search, nil
+}
+
+func (p *Pool) DeleteSavedSearch(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	const stmt = `delete from saved_searches where id = $1 and owner_id = $2`
+	tag, err := p.Exec(ctx, stmt, id, ownerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
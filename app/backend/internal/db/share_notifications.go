@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// ShareNotification is what a download notification email needs to know.
+type ShareNotification struct {
+	OwnerEmail string
+	Filename   string
+}
+
+// claimShareNotificationSQL stamps last_notified_at only when the share opted in
+// and the previous notification is at least $2 seconds old, so concurrent
+// downloads on any instance yield at most one email per interval.
+const claimShareNotificationSQL = `
+update shares s
+set last_notified_at = now()
+from files f
+join users u on u.id = f.owner_id
+where s.file_id = $1
+  and f.id = s.file_id
+  and s.notify_on_download
+  and (s.last_notified_at is null or s.last_notified_at <= now() - $2 * interval '1 second')
+returning u.email, f.filename_original;
+`
+
+// ClaimShareDownloadNotification reserves the right to notify the owner about a
+// download of the file's share. It returns nil when the share has not opted in
+// or the owner was already notified within minInterval.
+func (p *Pool) ClaimShareDownloadNotification(ctx context.Context, fileID uuid.UUID, minInterval time.Duration) (*ShareNotification, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	var n ShareNotification
+	err := p.QueryRow(ctx, claimShareNotificationSQL, fileID, minInterval.Seconds()).Scan(&n.OwnerEmail, &n.Filename)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claim share notification: %w", err)
+	}
+	return &n, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// AddTagToFiles appends tag to every live file in fileIDs owned by ownerID that
+// does not already carry it, in one statement. It returns the IDs of the owned
+// files, including those that already had the tag.
+func (p *Pool) AddTagToFiles(ctx context.Context, ownerID uuid.UUID, fileIDs []uuid.UUID, tag string) ([]uuid.UUID, error) {
+	const stmt = `
+        update files
+        set tags = case when tags ? $3 then tags else tags || to_jsonb($3::text) end
+        where owner_id = $1 and id = any($2) and is_deleted = false
+        returning id
+    `
+	return p.updateFileTags(ctx, stmt, ownerID, fileIDs, tag)
+}
+
+// RemoveTagFromFiles drops tag from every live file in fileIDs owned by ownerID
+// and returns the IDs of the owned files.
+func (p *Pool) RemoveTagFromFiles(ctx context.Context, ownerID uuid.UUID, fileIDs []uuid.UUID, tag string) ([]uuid.UUID, error) {
+	const stmt = `
+        update files
+        set tags = tags - $3::text
+        where owner_id = $1 and id = any($2) and is_deleted = false
+        returning id
+    `
+	return p.updateFileTags(ctx, stmt, ownerID, fileIDs, tag)
+}
+
+// RenameTag replaces oldTag with newTag on every live file owned by ownerID in
+// one statement. A file that already carried newTag keeps a single copy at its
+// earlier position. It returns the number of files changed.
+func (p *Pool) RenameTag(ctx context.Context, ownerID uuid.UUID, oldTag, newTag string) (int, error) {
+	const stmt = `
+        update files f
+        set tags = (
+            select coalesce(jsonb_agg(d.tag order by d.pos), '[]'::jsonb)
+            from (
+                select case when t.tag = $2 then $3::text else t.tag end as tag, min(t.pos) as pos
+                from jsonb_array_elements_text(f.tags) with ordinality as t(tag, pos)
+                group by 1
+            ) d
+        )
+        where f.owner_id = $1 and f.is_deleted = false and f.tags ? $2
+    `
+	tag, err := p.Exec(ctx, stmt, ownerID, oldTag, newTag)
+	if err != nil {
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func (p *Pool) updateFileTags(ctx context.Context, stmt string, ownerID uuid.UUID, fileIDs []uuid.UUID, tag string) ([]uuid.UUID, error) {
+	rows, err := p.Query(ctx, stmt, ownerID, fileIDs, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var updated []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		updated = append(updated, id)
+	}
+	return updated, rows.Err()
+}
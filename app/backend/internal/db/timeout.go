@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrQueryTimeout is returned (wrapped) when a query exceeds the pool's query
+// timeout or is cancelled by the server's statement_timeout.
+var ErrQueryTimeout = errors.New("database query timed out")
+
+// queryCanceledCode is the SQLSTATE Postgres reports for statement_timeout.
+const queryCanceledCode = "57014"
+
+// querier runs statements against a pgx pool, bounding each one by timeout.
+type querier struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+func (q querier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx, cancel := withQueryTimeout(ctx, q.timeout)
+	defer cancel()
+	tag, err := q.pool.Exec(ctx, sql, args...)
+	return tag, translateTimeout(err)
+}
+
+func (q querier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx, cancel := withQueryTimeout(ctx, q.timeout)
+	rows, err := q.pool.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, translateTimeout(err)
+	}
+	return timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+func (q querier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	ctx, cancel := withQueryTimeout(ctx, q.timeout)
+	return timeoutRow{row: q.pool.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+// timeoutRows releases the query deadline once the result set is closed.
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+func (r timeoutRows) Err() error {
+	return translateTimeout(r.Rows.Err())
+}
+
+// timeoutRow releases the query deadline once the row is scanned.
+type timeoutRow struct {
+	row    pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r timeoutRow) Scan(dest ...any) error {
+	defer r.cancel()
+	return translateTimeout(r.row.Scan(dest...))
+}
+
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// translateTimeout wraps deadline and statement_timeout failures in
+// ErrQueryTimeout and returns every other error unchanged.
+func translateTimeout(err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode) {
+		return fmt.Errorf("%w: %v", ErrQueryTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// PurgeResult describes the rows removed by PurgeDeletedFiles, or that would be
+// removed on a dry run.
+type PurgeResult struct {
+	Files         int
+	OriginalBytes int64
+	// Blobs counts the blobs whose last file row was purged.
+	Blobs int
+	// ReclaimedBytes is the deduplicated size of the blobs whose last file row
+	// was purged.
+	ReclaimedBytes int64
+	// StorageKeys lists the objects of the removed blobs and chunks; the caller
+	// deletes them once the transaction has committed.
+	StorageKeys []string
+}
+
+// purgeDeletedFilesSQL removes the trashed rows and releases their blob
+// references; soft deletes keep them so a file stays intact until purged.
+const purgeDeletedFilesSQL = `
+with purged as (
+    delete from files
+    where owner_id = $1 and is_deleted = true
+    returning blob_id, size_bytes_original
+),
+released as (
+    update file_blobs b
+    set ref_count = b.ref_count - p.cnt
+    from (select blob_id, count(*) as cnt from purged group by blob_id) p
+    where b.id = p.blob_id
+)
+select blob_id, size_bytes_original from purged;
+`
+
+// lockOrphanBlobsSQL picks the purged blobs that lost their last file row. Their
+// ref_count is zero unless an upload has since reused the content.
+const lockOrphanBlobsSQL = `
+select id, storage_key, chunked, size_bytes
+from file_blobs b
+where b.id = any($1)
+  and b.ref_count <= 0
+  and not exists (select 1 from files f where f.blob_id = b.id)
+for update;
+`
+
+const releaseOrphanChunksSQL = `
+with removed as (
+    delete from blob_chunks
+    where blob_id = any($1)
+    returning chunk_id
+)
+update chunks c
+set ref_count = c.ref_count - r.cnt
+from (select chunk_id, count(*) as cnt from removed group by chunk_id) r
+where c.id = r.chunk_id;
+`
+
+const deleteUnusedChunksSQL = `
+delete from chunks
+where ref_count <= 0
+  and not exists (select 1 from blob_chunks bc where bc.chunk_id = chunks.id)
+returning storage_key;
+`
+
+// errDryRun rolls back a purge transaction once its result has been computed.
+var errDryRun = errors.New("dry run")
+
+// PurgeDeletedFiles permanently removes the owner's soft-deleted file rows in one
+// transaction, together with blobs and chunks no longer referenced by any file.
+// Live files are never touched. With dryRun the same statements run and the
+// transaction is rolled back, so the result previews a real purge exactly.
+func (p *Pool) PurgeDeletedFiles(ctx context.Context, ownerID uuid.UUID, dryRun bool) (*PurgeResult, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	result := &PurgeResult{StorageKeys: []string{}}
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		*result = PurgeResult{StorageKeys: []string{}}
+		if err := purgeDeletedFilesTx(ctx, tx, ownerID, result); err != nil {
+			return err
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("purge deleted files: %w", translateTimeout(err))
+	}
+	return result, nil
+}
+
+// purgeDeletedFilesTx runs the statements of PurgeDeletedFiles in tx and fills
+// result.
+func purgeDeletedFilesTx(ctx context.Context, tx pgx.Tx, ownerID uuid.UUID, result *PurgeResult) error {
+	rows, err := tx.Query(ctx, purgeDeletedFilesSQL, ownerID)
+	if err != nil {
+		return err
+	}
+	seen := make(map[uuid.UUID]bool)
+	var blobIDs []uuid.UUID
+	for rows.Next() {
+		var blobID uuid.UUID
+		var size int64
+		if err := rows.Scan(&blobID, &size); err != nil {
+			rows.Close()
+			return err
+		}
+		result.Files++
+		result.OriginalBytes += size
+		if !seen[blobID] {
+			seen[blobID] = true
+			blobIDs = append(blobIDs, blobID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(blobIDs) == 0 {
+		return nil
+	}
+
+	rows, err = tx.Query(ctx, lockOrphanBlobsSQL, blobIDs)
+	if err != nil {
+		return err
+	}
+	var orphans, chunked []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		var key string
+		var isChunked bool
+		var size int64
+		if err := rows.Scan(&id, &key, &isChunked, &size); err != nil {
+			rows.Close()
+			return err
+		}
+		orphans = append(orphans, id)
+		result.Blobs++
+		result.ReclaimedBytes += size
+		if isChunked {
+			chunked = append(chunked, id)
+		} else {
+			result.StorageKeys = append(result.StorageKeys, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(chunked) > 0 {
+		if _, err := tx.Exec(ctx, releaseOrphanChunksSQL, chunked); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, deleteUnusedChunksSQL)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return err
+			}
+			result.StorageKeys = append(result.StorageKeys, key)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(orphans) > 0 {
+		if _, err := tx.Exec(ctx, `delete from file_blobs where id = any($1)`, orphans); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StorageGCEntry is a storage object waiting to be deleted.
+type StorageGCEntry struct {
+	StorageKey string
+	Attempts   int
+}
+
+// EnqueueStorageDeletes records objects whose delete failed so the storage-gc
+// job can retry them.
+func (p *Pool) EnqueueStorageDeletes(ctx context.Context, keys []string, cause error) error {
+	if p == nil {
+		return errors.New("nil db pool")
+	}
+
+	const stmt = `
+        insert into storage_gc_queue (storage_key, last_error)
+        select unnest($1::text[]), $2
+        on conflict (storage_key) do update set last_error = excluded.last_error
+    `
+	if _, err := p.Exec(ctx, stmt, keys, cause.Error()); err != nil {
+		return fmt.Errorf("enqueue storage deletes: %w", err)
+	}
+	return nil
+}
+
+// ListStorageDeletes returns up to limit queued objects, oldest first.
+func (p *Pool) ListStorageDeletes(ctx context.Context, limit int) ([]StorageGCEntry, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	const query = `
+        select storage_key, attempts
+        from storage_gc_queue
+        order by enqueued_at
+        limit $1
+    `
+	rows, err := p.Query(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list storage deletes: %w", err)
+	}
+	defer rows.Close()
+
+	var out []StorageGCEntry
+	for rows.Next() {
+		var entry StorageGCEntry
+		if err := rows.Scan(&entry.StorageKey, &entry.Attempts); err != nil {
+			return nil, err
+		}
+		out = append(out, entry)
+	}
+	return out, rows.Err()
+}
+
+// StorageKeyInUse reports whether a blob or chunk row still points at key. It
+// reads the primary, so a row committed just before is seen.
+func (p *Pool) StorageKeyInUse(ctx context.Context, key string) (bool, error) {
+	if p == nil {
+		return false, errors.New("nil db pool")
+	}
+
+	const query = `
+        select exists (select 1 from file_blobs where storage_key = $1)
+            or exists (select 1 from chunks where storage_key = $1)
+    `
+	var inUse bool
+	if err := p.QueryRow(ctx, query, key).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("check storage key: %w", err)
+	}
+	return inUse, nil
+}
+
+// CompleteStorageDelete records the outcome of a retried delete: the entry is
+// removed on success and kept with the error otherwise.
+func (p *Pool) CompleteStorageDelete(ctx context.Context, key string, deleteErr error) error {
+	if p == nil {
+		return errors.New("nil db pool")
+	}
+
+	if deleteErr == nil {
+		_, err := p.Exec(ctx, `delete from storage_gc_queue where storage_key = $1`, key)
+		return err
+	}
+	const stmt = `
+        update storage_gc_queue
+        set attempts = attempts + 1, last_error = $2
+        where storage_key = $1
+    `
+	_, err := p.Exec(ctx, stmt, key, deleteErr.Error())
+	return err
+}
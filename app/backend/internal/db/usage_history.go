@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageSnapshot is one user's storage usage recorded for a UTC day.
+type UsageSnapshot struct {
+	Day           time.Time
+	OriginalBytes int64
+	DedupedBytes  int64
+}
+
+// snapshotUsageSQL records today's usage for every user. The (user_id, day)
+// primary key makes it idempotent, so reruns and concurrent instances keep the
+// first snapshot of the day.
+const snapshotUsageSQL = `
+insert into storage_usage_history (user_id, day, original_bytes, deduped_bytes)
+select
+    u.id,
+    (now() at time zone 'utc')::date,
+    (select coalesce(sum(size_bytes_original), 0)
+     from files
+     where owner_id = u.id and is_deleted = false),
+    (select coalesce(sum(b.size_bytes), 0)
+     from file_blobs b
+     where b.id in (select blob_id from files where owner_id = u.id and is_deleted = false))
+from users u
+on conflict (user_id, day) do nothing;
+`
+
+const listUsageHistorySQL = `
+select day, original_bytes, deduped_bytes
+from storage_usage_history
+where user_id = $1 and day between $2::date and $3::date
+order by day;
+`
+
+// SnapshotStorageUsage writes today's snapshot for users that do not have one
+// yet and returns how many rows were inserted.
+func (p *Pool) SnapshotStorageUsage(ctx context.Context) (int64, error) {
+	if p == nil {
+		return 0, errors.New("nil db pool")
+	}
+
+	tag, err := p.Exec(ctx, snapshotUsageSQL)
+	if err != nil {
+		return 0, fmt.Errorf("snapshot storage usage: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListUsageHistory returns the user's snapshots for the inclusive day range.
+func (p *Pool) ListUsageHistory(ctx context.Context, userID uuid.UUID, from, to time.Time) ([]UsageSnapshot, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	rows, err := p.reader().Query(ctx, listUsageHistorySQL, userID, from.UTC().Format(time.DateOnly), to.UTC().Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("list usage history: %w", err)
+	}
+	defer rows.Close()
+
+	var out []UsageSnapshot
+	for rows.Next() {
+		var snap UsageSnapshot
+		if err := rows.Scan(&snap.Day, &snap.OriginalBytes, &snap.DedupedBytes); err != nil {
+			return nil, fmt.Errorf("scan usage snapshot: %w", err)
+		}
+		out = append(out, snap)
+	}
+	return out, rows.Err()
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 )
 
 type User struct {
@@ -16,31 +17,78 @@ type User struct {
 	Role       string
 	QuotaBytes int64
 	CreatedAt  time.Time
+	// LastLoginAt is nil until the user first signs in through OAuth.
+	LastLoginAt *time.Time
 }
 
+const userColumns = `id, email, name, role, quota_bytes, created_at, last_login_at`
+
+func scanUser(row pgx.Row, user *User) error {
+	return row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.QuotaBytes, &user.CreatedAt, &user.LastLoginAt)
+}
+
+// upsertUserSQL only sets role and quota on insert; existing users keep theirs.
 const upsertUserSQL = `
-insert into users (email, name)
-values ($1, nullif($2, ''))
+insert into users (email, name, role, quota_bytes)
+values ($1, nullif($2, ''), $3, $4)
 on conflict (email)
     do update set name = excluded.name
-returning id, email, name, role, quota_bytes, created_at;
+returning ` + userColumns + `;
+`
+
+const adminExistsSQL = `
+select exists (select 1 from users where role = 'ADMIN');
+`
+
+const getUserByEmailSQL = `
+select ` + userColumns + `
+from users
+where lower(email) = lower($1);
 `
 
 const getUserByIDSQL = `
-select id, email, name, role, quota_bytes, created_at
+select ` + userColumns + `
 from users
 where id = $1;
 `
 
+// adminBootstrapLockKey serializes UpsertUser while no admin exists, so two
+// simultaneous first logins cannot both observe "no admin" and both become
+// ADMIN. Once an admin exists, logins skip the lock.
+const adminBootstrapLockKey = 0x7661756c74 // "vault"
+
 func (p *Pool) UpsertUser(ctx context.Context, email, name string) (User, error) {
 	var user User
 	if p == nil {
 		return user, errors.New("nil db pool")
 	}
 
-	row := p.QueryRow(ctx, upsertUserSQL, email, name)
-	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.QuotaBytes, &user.CreatedAt); err != nil {
-		return user, fmt.Errorf("upsert user: %w", err)
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		// A fresh deployment is bootstrapped by making its first user ADMIN.
+		var adminExists bool
+		if err := tx.QueryRow(ctx, adminExistsSQL).Scan(&adminExists); err != nil {
+			return err
+		}
+		if !adminExists {
+			if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock($1)", adminBootstrapLockKey); err != nil {
+				return err
+			}
+			// Re-check now that concurrent first logins are serialized.
+			if err := tx.QueryRow(ctx, adminExistsSQL).Scan(&adminExists); err != nil {
+				return err
+			}
+		}
+		role := RoleAdmin
+		if adminExists {
+			role = RoleUser
+		}
+		row := tx.QueryRow(ctx, upsertUserSQL, email, name, role, p.defaultQuotaFor(role))
+		return scanUser(row, &user)
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("upsert user: %w", translateTimeout(err))
 	}
 	return user, nil
 }
@@ -52,8 +100,95 @@ func (p *Pool) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 	}
 
 	row := p.QueryRow(ctx, getUserByIDSQL, id)
-	if err := row.Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.QuotaBytes, &user.CreatedAt); err != nil {
+	if err := scanUser(row, &user); err != nil {
 		return user, fmt.Errorf("get user: %w", err)
 	}
 	return user, nil
 }
+
+// GetUserByEmail looks a user up case-insensitively, returning nil when absent.
+func (p *Pool) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	var user User
+	row := p.QueryRow(ctx, getUserByEmailSQL, email)
+	if err := scanUser(row, &user); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get user by email: %w", err)
+	}
+	return &user, nil
+}
+
+// Roles accepted by SetUserRole.
+const (
+	RoleUser  = "USER"
+	RoleAdmin = "ADMIN"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrLastAdmin   = errors.New("cannot demote the last remaining admin")
+)
+
+const countOtherAdminsSQL = `
+select count(*) from users where role = 'ADMIN' and id <> $1;
+`
+
+const setUserRoleSQL = `
+update users
+set role = $2
+where id = $1
+returning ` + userColumns + `;
+`
+
+// SetUserRole changes a user's role, returning nil when the user does not exist.
+// Demoting the only remaining admin fails with ErrLastAdmin. The bootstrap lock is
+// shared with UpsertUser so role changes and first logins cannot interleave.
+func (p *Pool) SetUserRole(ctx context.Context, userID uuid.UUID, role string) (*User, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+	if role != RoleUser && role != RoleAdmin {
+		return nil, ErrInvalidRole
+	}
+
+	var user *User
+	ctx, cancel := withQueryTimeout(ctx, p.queryTimeout)
+	defer cancel()
+	err := pgx.BeginFunc(ctx, p.Pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "select pg_advisory_xact_lock($1)", adminBootstrapLockKey); err != nil {
+			return err
+		}
+		if role != RoleAdmin {
+			var otherAdmins int
+			if err := tx.QueryRow(ctx, countOtherAdminsSQL, userID).Scan(&otherAdmins); err != nil {
+				return err
+			}
+			if otherAdmins == 0 {
+				return ErrLastAdmin
+			}
+		}
+
+		var u User
+		err := scanUser(tx.QueryRow(ctx, setUserRoleSQL, userID, role), &u)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		user = &u
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrLastAdmin) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("set user role: %w", translateTimeout(err))
+	}
+	return user, nil
+}
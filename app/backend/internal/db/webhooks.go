@@ -0,0 +1,271 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Webhook delivery states.
+const (
+	WebhookDeliveryPending   = "PENDING"
+	WebhookDeliveryDelivered = "DELIVERED"
+	WebhookDeliveryFailed    = "FAILED"
+)
+
+// Webhook is an owner-registered endpoint that receives signed event payloads.
+// Secret is used to sign deliveries and is never returned to clients.
+type Webhook struct {
+	ID        uuid.UUID
+	OwnerID   uuid.UUID
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is one event queued for one webhook, together with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	Event          string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	ResponseStatus *int
+	LastError      *string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// DueWebhookDelivery is a pending delivery joined with its target endpoint.
+type DueWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// WebhookAttempt is the outcome of one delivery attempt. A nil NextAttemptAt
+// with a non-empty Error marks the delivery as permanently failed.
+type WebhookAttempt struct {
+	ResponseStatus *int
+	Error          *string
+	NextAttemptAt  *time.Time
+}
+
+const webhookColumns = `id, owner_id, url, secret, events, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_status, last_error, next_attempt_at, created_at, delivered_at`
+
+func scanWebhook(row pgx.Row, hook *Webhook) error {
+	return row.Scan(&hook.ID, &hook.OwnerID, &hook.URL, &hook.Secret, &hook.Events, &hook.CreatedAt)
+}
+
+func scanWebhookDelivery(row pgx.Row, d *WebhookDelivery, extra ...any) error {
+	dest := []any{&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+const insertWebhookSQL = `
+insert into webhooks (owner_id, url, secret, events)
+values ($1, $2, $3, $4)
+returning ` + webhookColumns + `;
+`
+
+const listWebhooksSQL = `
+select ` + webhookColumns + `
+from webhooks
+where owner_id = $1
+order by created_at;
+`
+
+const getWebhookSQL = `
+select ` + webhookColumns + `
+from webhooks
+where id = $1 and owner_id = $2;
+`
+
+// enqueueWebhookEventSQL fans one event out to every webhook of the owner
+// subscribed to it.
+const enqueueWebhookEventSQL = `
+insert into webhook_deliveries (webhook_id, event, payload)
+select id, $2, $3
+from webhooks
+where owner_id = $1 and $2 = any(events);
+`
+
+const dueWebhookDeliveriesSQL = `
+select d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.response_status, d.last_error,
+       d.next_attempt_at, d.created_at, d.delivered_at, w.url, w.secret
+from webhook_deliveries d
+join webhooks w on w.id = d.webhook_id
+where d.status = 'PENDING' and d.next_attempt_at <= now()
+order by d.next_attempt_at
+limit $1;
+`
+
+// recordWebhookAttemptSQL keeps the delivery pending while a retry is scheduled
+// ($4), marks it delivered when the attempt carried no error, and failed otherwise.
+const recordWebhookAttemptSQL = `
+update webhook_deliveries
+set attempts = attempts + 1,
+    response_status = $2,
+    last_error = $3,
+    status = case
+        when $3::text is null then 'DELIVERED'
+        when $4::timestamptz is not null then 'PENDING'
+        else 'FAILED'
+    end,
+    next_attempt_at = coalesce($4, next_attempt_at),
+    delivered_at = case when $3::text is null then now() end
+where id = $1;
+`
+
+const listWebhookDeliveriesSQL = `
+select ` + webhookDeliveryColumns + `
+from webhook_deliveries
+where webhook_id = $1
+order by created_at desc
+limit $2;
+`
+
+func (p *Pool) CreateWebhook(ctx context.Context, ownerID uuid.UUID, url, secret string, events []string) (*Webhook, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	var hook Webhook
+	if err := scanWebhook(p.QueryRow(ctx, insertWebhookSQL, ownerID, url, secret, events), &hook); err != nil {
+		return nil, fmt.Errorf("create webhook: %w", err)
+	}
+	return &hook, nil
+}
+
+func (p *Pool) ListWebhooks(ctx context.Context, ownerID uuid.UUID) ([]Webhook, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	rows, err := p.Query(ctx, listWebhooksSQL, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Webhook
+	for rows.Next() {
+		var hook Webhook
+		if err := scanWebhook(rows, &hook); err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+		out = append(out, hook)
+	}
+	return out, rows.Err()
+}
+
+// GetWebhook returns the owner's webhook, or nil when it does not exist.
+func (p *Pool) GetWebhook(ctx context.Context, id, ownerID uuid.UUID) (*Webhook, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	var hook Webhook
+	if err := scanWebhook(p.QueryRow(ctx, getWebhookSQL, id, ownerID), &hook); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get webhook: %w", err)
+	}
+	return &hook, nil
+}
+
+// DeleteWebhook removes the owner's webhook along with its delivery log.
+func (p *Pool) DeleteWebhook(ctx context.Context, id, ownerID uuid.UUID) (bool, error) {
+	if p == nil {
+		return false, errors.New("nil db pool")
+	}
+
+	tag, err := p.Exec(ctx, `delete from webhooks where id = $1 and owner_id = $2`, id, ownerID)
+	if err != nil {
+		return false, fmt.Errorf("delete webhook: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// EnqueueWebhookEvent queues payload for each of the owner's webhooks subscribed
+// to event and returns how many deliveries were queued.
+func (p *Pool) EnqueueWebhookEvent(ctx context.Context, ownerID uuid.UUID, event string, payload []byte) (int64, error) {
+	if p == nil {
+		return 0, errors.New("nil db pool")
+	}
+
+	tag, err := p.Exec(ctx, enqueueWebhookEventSQL, ownerID, event, string(payload))
+	if err != nil {
+		return 0, fmt.Errorf("enqueue webhook event: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries whose next attempt
+// is due, oldest first.
+func (p *Pool) DueWebhookDeliveries(ctx context.Context, limit int) ([]DueWebhookDelivery, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	rows, err := p.Query(ctx, dueWebhookDeliveriesSQL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []DueWebhookDelivery
+	for rows.Next() {
+		var d DueWebhookDelivery
+		if err := scanWebhookDelivery(rows, &d.WebhookDelivery, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (p *Pool) RecordWebhookAttempt(ctx context.Context, deliveryID uuid.UUID, attempt WebhookAttempt) error {
+	if p == nil {
+		return errors.New("nil db pool")
+	}
+
+	if _, err := p.Exec(ctx, recordWebhookAttemptSQL, deliveryID, attempt.ResponseStatus, attempt.Error, attempt.NextAttemptAt); err != nil {
+		return fmt.Errorf("record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the webhook's most recent deliveries, newest first.
+func (p *Pool) ListWebhookDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	if p == nil {
+		return nil, errors.New("nil db pool")
+	}
+
+	rows, err := p.reader().Query(ctx, listWebhookDeliveriesSQL, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var out []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := scanWebhookDelivery(rows, &d); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
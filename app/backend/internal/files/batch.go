@@ -0,0 +1,146 @@
+package files
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+// uploadBatch is the state shared by the concurrent Upload and CreateFromContent
+// calls of one owner, so that their reservations count against each other.
+type uploadBatch struct {
+	mu    sync.Mutex
+	usage int64
+	quota int64
+	// files counts the owner's live files; maxFiles caps it, zero meaning no cap.
+	files    int
+	maxFiles int
+	// hashLocks serializes inputs with identical content so that only the first
+	// stores the blob; the others find it and add a reference.
+	hashLocks map[string]*sync.Mutex
+
+	// holders counts the calls using the batch; it is guarded by
+	// Service.batchesMu. loaded is closed once usage and files are read, with
+	// loadErr set if that failed.
+	holders int
+	loaded  chan struct{}
+	loadErr error
+}
+
+// acquireBatch returns the owner's batch and a func to call when done with it.
+// The first caller reads the owner's usage and file count from the primary;
+// later callers reuse the batch until every holder is done, so reservations
+// already charged stay counted even before their rows are visible.
+func (s *Service) acquireBatch(ctx context.Context, owner db.User) (*uploadBatch, func(), error) {
+	s.batchesMu.Lock()
+	batch, ok := s.batches[owner.ID]
+	if !ok {
+		batch = &uploadBatch{
+			quota:     s.effectiveQuota(owner),
+			maxFiles:  s.maxFilesPerUser,
+			hashLocks: make(map[string]*sync.Mutex),
+			loaded:    make(chan struct{}),
+		}
+		s.batches[owner.ID] = batch
+	}
+	batch.holders++
+	s.batchesMu.Unlock()
+
+	release := func() {
+		s.batchesMu.Lock()
+		defer s.batchesMu.Unlock()
+		batch.holders--
+		if batch.holders == 0 && s.batches[owner.ID] == batch {
+			delete(s.batches, owner.ID)
+		}
+	}
+
+	if !ok {
+		batch.loadErr = s.loadBatch(ctx, owner.ID, batch)
+		if batch.loadErr != nil {
+			// Let the next call retry the load instead of inheriting the error.
+			s.batchesMu.Lock()
+			if s.batches[owner.ID] == batch {
+				delete(s.batches, owner.ID)
+			}
+			s.batchesMu.Unlock()
+		}
+		close(batch.loaded)
+	} else {
+		select {
+		case <-batch.loaded:
+		case <-ctx.Done():
+			release()
+			return nil, nil, ctx.Err()
+		}
+	}
+	if batch.loadErr != nil {
+		release()
+		return nil, nil, batch.loadErr
+	}
+	return batch, release, nil
+}
+
+func (s *Service) loadBatch(ctx context.Context, ownerID uuid.UUID, batch *uploadBatch) error {
+	usage, _, err := s.repo.StorageUsagePrimary(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	files := 0
+	if batch.maxFiles > 0 {
+		files, err = s.repo.CountOwnedFiles(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+	}
+	batch.usage, batch.files = usage, files
+	return nil
+}
+
+// reserve charges size and one file against the limits up front so concurrent
+// workers cannot overshoot them together.
+func (b *uploadBatch) reserve(size int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.quota > 0 && b.usage+size > b.quota {
+		return ErrQuotaExceeded
+	}
+	if b.maxFiles > 0 && b.files >= b.maxFiles {
+		return ErrFileLimitReached
+	}
+	b.usage += size
+	b.files++
+	return nil
+}
+
+// fits reports whether size would currently fit in the quota, without
+// reserving it.
+func (b *uploadBatch) fits(size int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.quota <= 0 || b.usage+size <= b.quota
+}
+
+// release returns a reservation whose upload failed.
+func (b *uploadBatch) release(size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.usage -= size
+	b.files--
+}
+
+func (b *uploadBatch) lockHash(hash string) func() {
+	b.mu.Lock()
+	lock, ok := b.hashLocks[hash]
+	if !ok {
+		lock = &sync.Mutex{}
+		b.hashLocks[hash] = lock
+	}
+	b.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
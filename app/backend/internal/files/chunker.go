@@ -0,0 +1,61 @@
+package files
+
+// Content-defined chunking parameters. Boundaries depend only on nearby bytes, so
+// an edit early in a file only changes the chunks around it.
+const (
+	minChunkSize = 16 << 10
+	maxChunkSize = 256 << 10
+	// chunkMaskBits gives an average chunk of roughly 64 KiB past the minimum.
+	chunkMaskBits = 16
+)
+
+var (
+	chunkMask = uint64(1<<chunkMaskBits-1) << (64 - chunkMaskBits)
+	gearTable = newGearTable()
+)
+
+// splitChunks cuts data at content-defined boundaries using a gear rolling hash.
+// The returned slices alias data.
+func splitChunks(data []byte) [][]byte {
+	chunks := make([][]byte, 0, len(data)/(64<<10)+1)
+	for len(data) > 0 {
+		n := nextBoundary(data)
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+func nextBoundary(data []byte) int {
+	if len(data) <= minChunkSize {
+		return len(data)
+	}
+	limit := len(data)
+	if limit > maxChunkSize {
+		limit = maxChunkSize
+	}
+
+	var hash uint64
+	for i := minChunkSize; i < limit; i++ {
+		hash = (hash << 1) + gearTable[data[i]]
+		if hash&chunkMask == 0 {
+			return i + 1
+		}
+	}
+	return limit
+}
+
+// newGearTable derives the gear values from a fixed splitmix64 sequence so that
+// boundaries are stable across processes and releases.
+func newGearTable() [256]uint64 {
+	var table [256]uint64
+	state := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}
@@ -0,0 +1,82 @@
+package files
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	encodingIdentity = "identity"
+	encodingGzip     = "gzip"
+)
+
+// minCompressionGain is the fraction of bytes compression must save for the
+// compressed form to be stored; below it the CPU cost on every read isn't worth it.
+const minCompressionGain = 0.1
+
+// isCompressible reports whether content of this MIME type is worth compressing.
+// Media and archive formats are already compressed and are skipped.
+func isCompressible(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-ndjson", "application/yaml", "application/x-yaml",
+		"application/sql", "application/x-sh", "application/rtf",
+		"image/svg+xml", "image/bmp", "application/x-tar":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// compressForStorage gzips data when that saves at least minCompressionGain,
+// returning the bytes to store and their encoding.
+func compressForStorage(data []byte) ([]byte, string) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return data, encodingIdentity
+	}
+	if err := zw.Close(); err != nil {
+		return data, encodingIdentity
+	}
+	if float64(buf.Len()) > float64(len(data))*(1-minCompressionGain) {
+		return data, encodingIdentity
+	}
+	return buf.Bytes(), encodingGzip
+}
+
+// decodeStored reverses compressForStorage as the stored object is read.
+// Closing the returned reader closes body; body is closed on error too.
+func decodeStored(body io.ReadCloser, encoding string) (io.ReadCloser, error) {
+	switch encoding {
+	case "", encodingIdentity:
+		return body, nil
+	case encodingGzip:
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			body.Close()
+			return nil, fmt.Errorf("decode gzip blob: %w", err)
+		}
+		return gzipBody{Reader: zr, body: body}, nil
+	default:
+		body.Close()
+		return nil, fmt.Errorf("unsupported blob encoding %q", encoding)
+	}
+}
+
+// gzipBody closes the stored object along with its decompressor.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g gzipBody) Close() error {
+	return errors.Join(g.Reader.Close(), g.body.Close())
+}
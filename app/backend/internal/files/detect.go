@@ -0,0 +1,26 @@
+package files
+
+import "bytes"
+
+var executableMagics = [][]byte{
+	{0x7f, 'E', 'L', 'F'},    // ELF
+	{'M', 'Z'},               // PE / DOS
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32-bit, little endian
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal binary
+	{'#', '!'},               // script with interpreter line
+}
+
+// isExecutable reports whether data starts with the magic bytes of a native
+// executable or an interpreter shebang. The filename and declared MIME are
+// deliberately ignored.
+func isExecutable(data []byte) bool {
+	for _, magic := range executableMagics {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"vault/internal/db"
+)
+
+var (
+	ErrSharePasswordRequired = errors.New("share password required")
+	ErrSharePasswordInvalid  = errors.New("invalid share password")
+)
+
+// SharedFolder is the read-only view of a folder share handed to visitors.
+type SharedFolder struct {
+	Folder db.Folder
+	Share  db.FolderShareRecord
+	Files  []db.FileWithBlob
+}
+
+// ShareFolder creates or updates the link share for an owned folder. The token is
+// kept stable across updates; a nil or empty password removes password protection.
+func (s *Service) ShareFolder(ctx context.Context, folderID, ownerID uuid.UUID, expires *time.Time, password *string) (*db.FolderShareRecord, error) {
+	folder, err := s.repo.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil || folder.OwnerID != ownerID {
+		return nil, ErrNotFound
+	}
+
+	var passwordHash *string
+	if password != nil && *password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		encoded := string(hashed)
+		passwordHash = &encoded
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.UpsertFolderShare(ctx, folderID, ownerID, token, expires, passwordHash)
+}
+
+func (s *Service) RevokeFolderShare(ctx context.Context, folderID, ownerID uuid.UUID) (bool, error) {
+	return s.repo.DeleteFolderShare(ctx, folderID, ownerID)
+}
+
+// OpenSharedFolder resolves a folder share token and lists every file in the
+// shared subtree.
+func (s *Service) OpenSharedFolder(ctx context.Context, token, password string) (*SharedFolder, error) {
+	share, err := s.resolveFolderShare(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := s.repo.GetFolderByID(ctx, share.FolderID)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		return nil, ErrNotFound
+	}
+
+	entries, _, err := s.repo.ListFiles(ctx, share.OwnerID, &db.FileFilter{FolderID: &share.FolderID, Recursive: true}, nil, db.DefaultListLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SharedFolder{Folder: *folder, Share: *share, Files: entries}, nil
+}
+
+// folderSharedFile looks up a file inside the folder tree behind a folder share.
+func (s *Service) folderSharedFile(ctx context.Context, token, password string, fileID uuid.UUID) (*db.FileWithBlob, error) {
+	share, err := s.resolveFolderShare(ctx, token, password)
+	if err != nil {
+		return nil, err
+	}
+
+	fileWithBlob, err := s.repo.GetFileInFolderTree(ctx, share.OwnerID, share.FolderID, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil || fileWithBlob.File.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return fileWithBlob, nil
+}
+
+func (s *Service) resolveFolderShare(ctx context.Context, token, password string) (*db.FolderShareRecord, error) {
+	share, err := s.repo.GetFolderShareByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, ErrNotFound
+	}
+
+	if share.PasswordHash != nil && *share.PasswordHash != "" {
+		if password == "" {
+			return nil, ErrSharePasswordRequired
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte(password)); err != nil {
+			return nil, ErrSharePasswordInvalid
+		}
+	}
+
+	return share, nil
+}
+
+// newShareToken returns a URL-safe random token with 256 bits of entropy.
+func newShareToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
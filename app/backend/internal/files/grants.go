@@ -0,0 +1,76 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+var ErrInvalidGrant = errors.New("invalid share grant")
+
+// GrantAccess shares an owned file with the user identified by email. The grant is
+// matched by email at access time, so it also applies to users who sign in later.
+func (s *Service) GrantAccess(ctx context.Context, fileID, ownerID uuid.UUID, email, permission string) (*db.ShareGrant, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, ErrInvalidGrant
+	}
+	permission = strings.ToUpper(strings.TrimSpace(permission))
+	if permission == "" {
+		permission = db.GrantPermissionDownload
+	}
+	if permission != db.GrantPermissionView && permission != db.GrantPermissionDownload {
+		return nil, ErrInvalidGrant
+	}
+
+	fileWithBlob, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil {
+		return nil, ErrNotFound
+	}
+
+	var granteeID *uuid.UUID
+	grantee, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if grantee != nil {
+		if grantee.ID == ownerID {
+			return nil, ErrInvalidGrant
+		}
+		granteeID = &grantee.ID
+	}
+
+	return s.repo.UpsertShareGrant(ctx, fileID, email, granteeID, permission, ownerID)
+}
+
+// RevokeAccess removes the grant for email on an owned file.
+func (s *Service) RevokeAccess(ctx context.Context, fileID, ownerID uuid.UUID, email string) (bool, error) {
+	fileWithBlob, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if fileWithBlob == nil {
+		return false, ErrNotFound
+	}
+	return s.repo.DeleteShareGrant(ctx, fileID, strings.TrimSpace(email))
+}
+
+// grantedFile looks up a file the user holds a DOWNLOAD grant for.
+func (s *Service) grantedFile(ctx context.Context, fileID, userID uuid.UUID) (*db.FileWithBlob, error) {
+	fileWithBlob, permission, err := s.repo.GetGrantedFileWithBlob(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil || permission != db.GrantPermissionDownload || fileWithBlob.File.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return fileWithBlob, nil
+}
@@ -0,0 +1,80 @@
+package files
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+// ErrFolderLabelsDisabled rejects linking files to extra folders while
+// FOLDER_LABELS is off and files live in exactly one folder.
+var ErrFolderLabelsDisabled = errors.New("linking files to several folders is disabled")
+
+// LinkResult reports the outcome for one requested file. Err is ErrNotFound
+// when the file does not exist, is deleted or belongs to another user.
+type LinkResult struct {
+	FileID uuid.UUID
+	Err    error
+}
+
+// LinkFilesToFolder makes the owner's files also appear in folderID, keeping
+// their home folder. An unknown or foreign folder fails the whole call with
+// ErrFolderNotFound; unknown files are reported per file.
+func (s *Service) LinkFilesToFolder(ctx context.Context, fileIDs []uuid.UUID, ownerID, folderID uuid.UUID) ([]LinkResult, error) {
+	if err := s.checkFolderLabels(len(fileIDs)); err != nil {
+		return nil, err
+	}
+	linked, err := s.repo.LinkFilesToFolder(ctx, ownerID, fileIDs, folderID)
+	if err != nil {
+		return nil, err
+	}
+	return linkResults(fileIDs, linked), nil
+}
+
+// UnlinkFilesFromFolder removes the owner's files from folderID where they were
+// linked. Files whose home folder is folderID stay there; use MoveFiles for that.
+func (s *Service) UnlinkFilesFromFolder(ctx context.Context, fileIDs []uuid.UUID, ownerID, folderID uuid.UUID) ([]LinkResult, error) {
+	if err := s.checkFolderLabels(len(fileIDs)); err != nil {
+		return nil, err
+	}
+	unlinked, err := s.repo.UnlinkFilesFromFolder(ctx, ownerID, fileIDs, folderID)
+	if err != nil {
+		return nil, err
+	}
+	return linkResults(fileIDs, unlinked), nil
+}
+
+// ListFolderFiles pages through the files of folderID, or of the root when it
+// is nil. With FOLDER_LABELS on, files linked to the folder are included.
+func (s *Service) ListFolderFiles(ctx context.Context, ownerID uuid.UUID, folderID *uuid.UUID, after *db.FileCursor, limit int) ([]db.FileWithBlob, error) {
+	return s.repo.ListFolderFiles(ctx, ownerID, folderID, s.folderLabels && folderID != nil, after, limit)
+}
+
+func (s *Service) checkFolderLabels(files int) error {
+	if !s.folderLabels {
+		return ErrFolderLabelsDisabled
+	}
+	if files > MaxMoveFiles {
+		return ErrTooManyFiles
+	}
+	return nil
+}
+
+func linkResults(fileIDs, updated []uuid.UUID) []LinkResult {
+	updatedSet := make(map[uuid.UUID]bool, len(updated))
+	for _, id := range updated {
+		updatedSet[id] = true
+	}
+	results := make([]LinkResult, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		result := LinkResult{FileID: id}
+		if !updatedSet[id] {
+			result.Err = ErrNotFound
+		}
+		results = append(results, result)
+	}
+	return results
+}
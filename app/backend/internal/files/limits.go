@@ -0,0 +1,44 @@
+package files
+
+import "strings"
+
+// normalizeMimeLimits lowercases the keys of Options.MimeUploadLimits and drops
+// non-positive limits.
+func normalizeMimeLimits(limits map[string]int64) map[string]int64 {
+	out := make(map[string]int64, len(limits))
+	for key, limit := range limits {
+		if limit > 0 {
+			out[strings.ToLower(strings.TrimSpace(key))] = limit
+		}
+	}
+	return out
+}
+
+// uploadLimit returns the size limit for content of mimeType: a limit for the
+// exact type, else one for its category (the part before "/"), else
+// MaxUploadBytes. Zero means unlimited.
+func (s *Service) uploadLimit(mimeType string) int64 {
+	mediaType, _, _ := strings.Cut(mimeType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if limit, ok := s.mimeUploadLimits[mediaType]; ok {
+		return limit
+	}
+	category, _, _ := strings.Cut(mediaType, "/")
+	if limit, ok := s.mimeUploadLimits[category]; ok {
+		return limit
+	}
+	return s.maxUploadBytes
+}
+
+// maxUploadLimit is the largest size any upload may have. It bounds reads
+// before the content type has been detected.
+func (s *Service) maxUploadLimit() int64 {
+	if s.maxUploadBytes <= 0 {
+		return 0
+	}
+	largest := s.maxUploadBytes
+	for _, limit := range s.mimeUploadLimits {
+		largest = max(largest, limit)
+	}
+	return largest
+}
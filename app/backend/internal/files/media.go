@@ -0,0 +1,24 @@
+package files
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// imageDimensions decodes the header of an image and returns its pixel size.
+// It is best-effort: non-image or unsupported content yields nil values.
+func imageDimensions(data []byte, mimeType string) (*int, *int) {
+	if !strings.HasPrefix(strings.ToLower(mimeType), "image/") {
+		return nil, nil
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, nil
+	}
+	width, height := cfg.Width, cfg.Height
+	return &width, &height
+}
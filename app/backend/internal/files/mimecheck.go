@@ -0,0 +1,96 @@
+package files
+
+import (
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+var ErrMimeMismatch = errors.New("file extension, declared type and content do not match")
+
+// executableExtensions map to a pseudo type so that, say, "setup.exe" declared as
+// image/png is reported even though mime has no entry for the extension.
+var executableExtensions = map[string]bool{
+	".exe": true, ".dll": true, ".msi": true, ".com": true, ".scr": true,
+	".bat": true, ".cmd": true, ".ps1": true, ".sh": true, ".elf": true,
+}
+
+const executableMIME = "application/x-executable"
+
+// containerTypes are formats that content sniffing reports as their container.
+var containerTypes = map[string]string{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   "application/zip",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         "application/zip",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": "application/zip",
+	"application/java-archive":     "application/zip",
+	"application/epub+zip":         "application/zip",
+	"application/x-zip-compressed": "application/zip",
+}
+
+// MimeMismatch reports whether the filename extension, the declared MIME type and
+// the detected MIME type contradict each other. Unknown or generic values
+// (no extension, application/octet-stream) never cause a mismatch, and types are
+// compared loosely: same media family for images, audio and video, and any
+// textual type against sniffed text/plain.
+func MimeMismatch(filename, declared, detected string) bool {
+	types := make([]string, 0, 3)
+	if ext := strings.ToLower(filepath.Ext(filename)); ext != "" {
+		if executableExtensions[ext] {
+			types = append(types, executableMIME)
+		} else if byExt := mime.TypeByExtension(ext); byExt != "" {
+			types = append(types, byExt)
+		}
+	}
+	types = append(types, declared, detected)
+
+	for i := range types {
+		for j := i + 1; j < len(types); j++ {
+			if !compatibleMIME(types[i], types[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compatibleMIME(a, b string) bool {
+	a, b = baseMIME(a), baseMIME(b)
+	if a == "" || b == "" || a == "application/octet-stream" || b == "application/octet-stream" || a == b {
+		return true
+	}
+	if containerTypes[a] == b || containerTypes[b] == a {
+		return true
+	}
+	if isTextual(a) && isTextual(b) {
+		return true
+	}
+	famA, _, _ := strings.Cut(a, "/")
+	famB, _, _ := strings.Cut(b, "/")
+	switch famA {
+	case "image", "audio", "video":
+		return famA == famB
+	}
+	return false
+}
+
+func baseMIME(value string) string {
+	if value == "" {
+		return ""
+	}
+	if parsed, _, err := mime.ParseMediaType(value); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func isTextual(value string) bool {
+	if strings.HasPrefix(value, "text/") {
+		return true
+	}
+	switch value {
+	case "application/json", "application/javascript", "application/xml", "application/x-sh", "image/svg+xml":
+		return true
+	}
+	return false
+}
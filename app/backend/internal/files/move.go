@@ -0,0 +1,53 @@
+package files
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+// MaxMoveFiles bounds how many files one MoveFiles call accepts.
+const MaxMoveFiles = 500
+
+var (
+	ErrFolderNotFound = db.ErrFolderNotFound
+	ErrTooManyFiles   = errors.New("too many files in one request")
+)
+
+// MoveResult reports the outcome for one requested file. Err is ErrNotFound
+// when the file does not exist, is deleted or belongs to another user.
+type MoveResult struct {
+	FileID uuid.UUID
+	Err    error
+}
+
+// MoveFiles moves the owner's files into destFolderID, or to the root when it
+// is nil, in a single transaction. An unknown or foreign destination fails the
+// whole call with ErrFolderNotFound; unknown files are reported per file.
+func (s *Service) MoveFiles(ctx context.Context, fileIDs []uuid.UUID, ownerID uuid.UUID, destFolderID *uuid.UUID) ([]MoveResult, error) {
+	if len(fileIDs) > MaxMoveFiles {
+		return nil, ErrTooManyFiles
+	}
+
+	moved, err := s.repo.MoveFiles(ctx, ownerID, fileIDs, destFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	movedSet := make(map[uuid.UUID]bool, len(moved))
+	for _, id := range moved {
+		movedSet[id] = true
+	}
+	results := make([]MoveResult, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		result := MoveResult{FileID: id}
+		if !movedSet[id] {
+			result.Err = ErrNotFound
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
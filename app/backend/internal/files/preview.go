@@ -0,0 +1,94 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+var ErrNotPreviewable = errors.New("file type cannot be previewed")
+
+// Preview is the leading text of a file rendered inline.
+type Preview struct {
+	File      db.FileRecord
+	Text      []byte
+	Truncated bool
+}
+
+// isPreviewable reports whether content of this MIME type is plain text that can
+// safely be shown inline once served as text/plain.
+func isPreviewable(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-ndjson", "application/yaml", "application/x-yaml",
+		"application/sql", "application/x-sh", "application/csv":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// PreviewOwnedFile previews a file the user owns or holds a download grant for,
+// mirroring the authorization of the download endpoint.
+func (s *Service) PreviewOwnedFile(ctx context.Context, fileID, userID uuid.UUID, maxBytes int) (*Preview, error) {
+	fileWithBlob, err := s.ownedFile(ctx, fileID, userID)
+	if errors.Is(err, ErrNotFound) {
+		fileWithBlob, err = s.grantedFile(ctx, fileID, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.preview(ctx, *fileWithBlob, maxBytes)
+}
+
+// PreviewSharedFile previews the file behind an active share token.
+func (s *Service) PreviewSharedFile(ctx context.Context, token string, maxBytes int) (*Preview, error) {
+	fileWithBlob, err := s.sharedFile(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.preview(ctx, *fileWithBlob, maxBytes)
+}
+
+// preview returns up to maxBytes of a text file, cut on a UTF-8 boundary.
+// Previews do not count as downloads.
+func (s *Service) preview(ctx context.Context, fileWithBlob db.FileWithBlob, maxBytes int) (*Preview, error) {
+	mimeType := fileWithBlob.Blob.MimeDetected
+	if declared := fileWithBlob.File.MimeDeclared; declared != nil && *declared != "" {
+		mimeType = *declared
+	}
+	if !isPreviewable(mimeType) && !isPreviewable(fileWithBlob.Blob.MimeDetected) {
+		return nil, ErrNotPreviewable
+	}
+
+	data, _, err := s.readBlob(ctx, fileWithBlob.Blob)
+	if err != nil {
+		return nil, err
+	}
+
+	truncated := false
+	if maxBytes > 0 && len(data) > maxBytes {
+		data = data[:maxBytes]
+		// Drop a multi-byte character split by the cut.
+		for i := 0; i < utf8.UTFMax-1 && len(data) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size > 1 {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+		truncated = true
+	}
+	if !utf8.Valid(data) {
+		return nil, ErrNotPreviewable
+	}
+
+	return &Preview{File: fileWithBlob.File, Text: data, Truncated: truncated}, nil
+}
@@ -0,0 +1,90 @@
+package files
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+	"vault/internal/metrics"
+)
+
+var ErrInvalidHash = errors.New("content hash must be a hex-encoded SHA-256")
+
+// NormalizeHash lower-cases hash and checks that it is a SHA-256 hex digest.
+func NormalizeHash(hash string) (string, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if len(hash) != 64 {
+		return "", ErrInvalidHash
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", ErrInvalidHash
+	}
+	return hash, nil
+}
+
+// FindOwnedContent looks up one of the owner's files with the given content hash.
+// Only the owner's own files are considered: answering for content stored by
+// other users would reveal that they hold it, and creating a reference from a
+// bare hash would hand the content to anyone who knows the hash.
+func (s *Service) FindOwnedContent(ctx context.Context, ownerID uuid.UUID, hash string) (*db.FileWithBlob, error) {
+	hash, err := NormalizeHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FindOwnedFileByHash(ctx, ownerID, hash)
+}
+
+// CreateFromContent creates a new file named filename that references content
+// the owner already stores, so clients can skip re-uploading it. The new file is
+// reserved against the quota and file limit together with the owner's uploads.
+func (s *Service) CreateFromContent(ctx context.Context, owner db.User, hash, filename string) (*UploadResult, error) {
+	filename = strings.TrimSpace(filename)
+	if filename == "" {
+		return nil, errors.New("filename is required")
+	}
+
+	existing, err := s.FindOwnedContent(ctx, owner.ID, hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrNotFound
+	}
+
+	batch, done, err := s.acquireBatch(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	size := existing.File.SizeBytesOriginal
+	if err := batch.reserve(size); err != nil {
+		return nil, err
+	}
+
+	record := &db.FileRecord{
+		OwnerID:            owner.ID,
+		BlobID:             existing.Blob.ID,
+		FilenameOriginal:   filename,
+		FilenameNormalized: strings.ToLower(filename),
+		MimeDeclared:       existing.File.MimeDeclared,
+		SizeBytesOriginal:  size,
+		Tags:               []string{},
+	}
+	if err := s.repo.InsertFileForBlob(ctx, record); err != nil {
+		batch.release(size)
+		if errors.Is(err, db.ErrBlobGone) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	blob := existing.Blob
+	blob.RefCount++
+
+	metrics.FilesUploaded.WithLabelValues(metrics.OutcomeSuccess).Inc()
+	metrics.DedupHits.Inc()
+	return &UploadResult{Filename: filename, File: *record, Blob: blob, IsNew: false}, nil
+}
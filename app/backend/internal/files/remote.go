@@ -0,0 +1,180 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"vault/internal/db"
+)
+
+const defaultRemoteFetchTimeout = 30 * time.Second
+
+var (
+	ErrInvalidRemoteURL = errors.New("remote url must be an absolute http(s) url")
+	ErrBlockedAddress   = errors.New("remote address is not allowed")
+)
+
+// cgnatRange is the carrier-grade NAT block, which net.IP.IsPrivate does not cover.
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// nat64Range is the well-known NAT64 prefix. A NAT64 gateway translates these
+// addresses to the IPv4 address in their last 32 bits, internal ones included.
+var nat64Range = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+
+// UploadFromURL fetches rawURL server-side and stores the body through the same
+// hash, dedup and quota pipeline as Upload. Connections to loopback, private,
+// link-local and other non-public addresses are refused at dial time, so DNS
+// tricks and redirects cannot reach internal services.
+func (s *Service) UploadFromURL(ctx context.Context, owner db.User, rawURL, filename string) (*UploadResult, error) {
+	target, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || !target.IsAbs() || target.Host == "" {
+		return nil, ErrInvalidRemoteURL
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, ErrInvalidRemoteURL
+	}
+
+	timeout := s.remoteFetchTimeout
+	if timeout <= 0 {
+		timeout = defaultRemoteFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := RemoteClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch remote file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("fetch remote file: %s", resp.Status)
+	}
+	limit := s.maxUploadLimit()
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, fmt.Errorf("file exceeds max upload size of %d bytes", limit)
+	}
+
+	if strings.TrimSpace(filename) == "" {
+		filename = path.Base(resp.Request.URL.Path)
+		if filename == "" || filename == "/" || filename == "." {
+			filename = "download"
+		}
+	}
+
+	declared := ""
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		declared = mediaType
+	}
+
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = &limitedReader{r: resp.Body, remaining: limit, limit: limit}
+	}
+
+	results, err := s.Upload(ctx, owner, []UploadInput{{
+		Filename:     filename,
+		DeclaredMIME: declared,
+		Reader:       body,
+		Size:         resp.ContentLength,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.New("remote upload produced no file")
+	}
+	if results[0].Err != nil {
+		return nil, results[0].Err
+	}
+	return &results[0], nil
+}
+
+// RemoteClient returns an HTTP client that refuses to connect to non-public
+// addresses, for requests to user-supplied URLs.
+func RemoteClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidRemoteURL
+			}
+			return nil
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil && (cgnatRange.Contains(ip4) || ip4[0] == 0) {
+		return false
+	}
+	if nat64Range.Contains(ip) {
+		return false
+	}
+	return true
+}
+
+// limitedReader fails once more than limit bytes have been read, so oversized
+// remote bodies are aborted mid-stream instead of being buffered in full.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, fmt.Errorf("file exceeds max upload size of %d bytes", l.limit)
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, fmt.Errorf("file exceeds max upload size of %d bytes", l.limit)
+	}
+	return n, err
+}
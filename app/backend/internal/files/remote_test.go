@@ -0,0 +1,88 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"127.255.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"::", false},
+		{"::1", false},
+		{"fc00::1", false},
+		{"fd12:3456::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+		{"64:ff9b::a00:1", false},
+		{"64:ff9b::808:808", false},
+	}
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("bad test address %q", tt.ip)
+		}
+		if got := isPublicIP(ip); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %t, want %t", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	const limit = 1024
+	read := func(size int) ([]byte, error) {
+		body := bytes.Repeat([]byte("x"), size)
+		return io.ReadAll(&limitedReader{r: bytes.NewReader(body), remaining: limit, limit: limit})
+	}
+
+	data, err := read(limit)
+	if err != nil {
+		t.Fatalf("body of exactly limit bytes: %v", err)
+	}
+	if len(data) != limit {
+		t.Fatalf("read %d bytes, want %d", len(data), limit)
+	}
+
+	if _, err := read(limit + 1); err == nil {
+		t.Fatal("body one byte over the limit was accepted")
+	}
+}
+
+func TestRemoteClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer server.Close()
+
+	resp, err := RemoteClient().Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to a loopback address succeeded")
+	}
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("err = %v, want ErrBlockedAddress", err)
+	}
+}
@@ -0,0 +1,163 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+var ErrInvalidReport = errors.New("invalid report")
+
+// maxReportNoteLength bounds the free-text note attached to a report.
+const maxReportNoteLength = 1000
+
+var reportReasons = map[string]bool{
+	"SPAM":       true,
+	"MALWARE":    true,
+	"ILLEGAL":    true,
+	"COPYRIGHT":  true,
+	"HARASSMENT": true,
+	"OTHER":      true,
+}
+
+// ReportPublicFile records an abuse report against a file with an active public
+// share. reporterID is nil for anonymous reports.
+func (s *Service) ReportPublicFile(ctx context.Context, fileID uuid.UUID, reporterID *uuid.UUID, reporterIP, reason string, note *string) (*db.FileReport, error) {
+	reason = strings.ToUpper(strings.TrimSpace(reason))
+	if !reportReasons[reason] {
+		return nil, fmt.Errorf("%w: unknown reason %q", ErrInvalidReport, reason)
+	}
+	if note != nil {
+		trimmed := strings.TrimSpace(*note)
+		if len(trimmed) > maxReportNoteLength {
+			return nil, fmt.Errorf("%w: note exceeds %d characters", ErrInvalidReport, maxReportNoteLength)
+		}
+		note = &trimmed
+		if trimmed == "" {
+			note = nil
+		}
+	}
+
+	share, err := s.repo.GetShareByFileID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil || !strings.EqualFold(share.Visibility, "PUBLIC") {
+		return nil, ErrNotFound
+	}
+	owner, err := s.repo.GetFileOwner(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, ErrNotFound
+	}
+
+	report := db.FileReport{
+		FileID:     fileID,
+		ReporterID: reporterID,
+		Reason:     reason,
+		Note:       note,
+	}
+	if reporterIP != "" {
+		report.ReporterIP = &reporterIP
+	}
+	inserted, err := s.repo.InsertFileReport(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	if s.reportHideThreshold > 0 {
+		hidden, err := s.repo.HideFileIfReported(ctx, fileID, s.reportHideThreshold)
+		if err != nil {
+			log.Printf("hide reported file %s failed: %v", fileID, err)
+		} else if hidden {
+			log.Printf("file %s hidden from public gallery after %d open reports", fileID, s.reportHideThreshold)
+		}
+	}
+	return inserted, nil
+}
+
+// SetHiddenFromPublic lets an admin hide a file from the public gallery or
+// restore it. The file itself and its share links are left untouched.
+func (s *Service) SetHiddenFromPublic(ctx context.Context, fileID uuid.UUID, hidden bool) error {
+	ok, err := s.repo.SetFileHiddenFromPublic(ctx, fileID, hidden)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DownloadFileAsAdmin fetches any live file regardless of owner, for admins
+// reviewing reported content. Callers must check the admin role. The fetch is
+// written to audit_logs before any content is read; if that write fails the
+// download fails with it.
+func (s *Service) DownloadFileAsAdmin(ctx context.Context, fileID, adminID uuid.UUID) (*DownloadedFile, error) {
+	owner, err := s.repo.GetFileOwner(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, ErrNotFound
+	}
+	fileWithBlob, err := s.repo.GetFileWithBlob(ctx, fileID, *owner)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil {
+		return nil, ErrNotFound
+	}
+
+	err = s.repo.InsertAuditLog(ctx, db.AuditEntry{
+		ActorID:    adminID,
+		Action:     db.AuditAdminFileDownload,
+		EntityType: "FILE",
+		EntityID:   fileID,
+		Metadata:   map[string]any{"ownerId": owner.String()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("record admin download: %w", err)
+	}
+	return s.download(ctx, *fileWithBlob)
+}
+
+// TakeDownFile removes a reported file on behalf of an admin: its shares are
+// revoked, the file is soft-deleted and its open reports are closed.
+func (s *Service) TakeDownFile(ctx context.Context, fileID, adminID uuid.UUID) error {
+	owner, err := s.repo.GetFileOwner(ctx, fileID)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return ErrNotFound
+	}
+	if _, err := s.DeleteFile(ctx, fileID, *owner); err != nil {
+		return err
+	}
+	// Unlike an owner's delete, a takedown revokes the link for good.
+	if err := s.repo.DeleteShare(ctx, fileID); err != nil {
+		return err
+	}
+	_, err = s.repo.ResolveFileReportsForFile(ctx, fileID, db.ReportStatusTakenDown, adminID)
+	return err
+}
+
+// DismissReport closes an open report without acting on the file.
+func (s *Service) DismissReport(ctx context.Context, reportID, adminID uuid.UUID) (*db.FileReport, error) {
+	report, err := s.repo.ResolveFileReport(ctx, reportID, db.ReportStatusDismissed, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, ErrNotFound
+	}
+	return report, nil
+}
@@ -7,14 +7,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
 	"vault/internal/db"
+	"vault/internal/metrics"
 	"vault/internal/storage"
 )
 
@@ -23,106 +26,366 @@ type UploadInput struct {
 	Filename     string
 	DeclaredMIME string
 	Reader       io.Reader
-	Size         int64
+	// Size is the declared content length; zero or negative when unknown.
+	// Multipart parts and Content-Length report exact sizes, so any difference
+	// from the bytes read rejects the file.
+	Size int64
 }
 
 type Service struct {
-	repo           *db.Pool
-	storage        *storage.SupabaseClient
-	maxUploadBytes int64
+	repo                *db.Pool
+	storage             *storage.SupabaseClient
+	maxUploadBytes      int64
+	dedupWindow         time.Duration
+	remoteFetchTimeout  time.Duration
+	chunkedDedup        bool
+	compression         bool
+	blockExecutables    bool
+	publicListLimit     int
+	roleQuotaBytes      map[string]int64
+	strictMIME          bool
+	uploadConcurrency   int
+	keyPrefix           string
+	maxFilesPerUser     int
+	maxShareExpiry      time.Duration
+	reportHideThreshold int
+	verifyUploads       bool
+	folderLabels        bool
+	maxUploadFiles      int
+	mimeUploadLimits    map[string]int64
+	mimeSampleBytes     int
+
+	// batchesMu guards batches, the in-flight upload batch of each owner.
+	batchesMu sync.Mutex
+	batches   map[uuid.UUID]*uploadBatch
 }
 
-var ErrNotFound = errors.New("file not found")
+// Options tunes upload behaviour of the file service.
+type Options struct {
+	MaxUploadBytes int64
+	// DedupWindow is how far back Upload looks for an identical file (same owner,
+	// content and filename) to return instead of creating a duplicate record.
+	// Zero disables the check.
+	DedupWindow time.Duration
+	// RemoteFetchTimeout bounds UploadFromURL, including the body transfer.
+	RemoteFetchTimeout time.Duration
+	// ChunkedDedup stores new blobs as content-defined chunks so that files
+	// sharing large regions also share storage. Whole-file dedup always applies.
+	ChunkedDedup bool
+	// Compression gzips compressible blobs before storing them. Dedup still keys
+	// on the hash of the uncompressed content.
+	Compression bool
+	// BlockExecutables rejects content that is detected as an executable.
+	BlockExecutables bool
+	// PublicListLimit caps public listings; it defaults to db.DefaultListLimit
+	// and is clamped to MaxPublicListLimit.
+	PublicListLimit int
+	// RoleQuotaBytes holds per-role quotas; see effectiveQuota.
+	RoleQuotaBytes map[string]int64
+	// StrictMIME rejects uploads whose extension, declared type and detected
+	// type are inconsistent (see MimeMismatch).
+	StrictMIME bool
+	// UploadConcurrency bounds how many files of one batch are hashed and
+	// stored in parallel; values below one mean sequential.
+	UploadConcurrency int
+	// StorageKeyPrefix namespaces the objects of new blobs and chunks in the
+	// bucket, e.g. "tenant-a". Existing blobs keep the key they were stored
+	// under, so changing it never orphans content.
+	StorageKeyPrefix string
+	// MaxFilesPerUser caps each user's live files; zero means unlimited.
+	MaxFilesPerUser int
+	// MaxShareExpiry bounds how far ahead a share may expire; zero means no
+	// limit.
+	MaxShareExpiry time.Duration
+	// ReportHideThreshold hides a file from the public gallery once it has this
+	// many open reports; zero disables automatic hiding.
+	ReportHideThreshold int
+	// VerifyUploads reads every new storage object back after uploading it.
+	VerifyUploads bool
+	// FolderLabels lets files be linked into folders besides their home folder.
+	FolderLabels bool
+	// MaxUploadFiles bounds how many inputs one Upload call accepts; zero means
+	// no limit.
+	MaxUploadFiles int
+	// MimeUploadLimits overrides MaxUploadBytes for a detected MIME type
+	// ("application/pdf") or category ("video").
+	MimeUploadLimits map[string]int64
+	// MimeSampleBytes is how many leading bytes MIME detection inspects; zero
+	// uses the 512 bytes http.DetectContentType reads.
+	MimeSampleBytes int
+}
+
+// MaxPublicListLimit is the hard ceiling for Options.PublicListLimit.
+const MaxPublicListLimit = 1000
+
+var (
+	ErrNotFound          = errors.New("file not found")
+	ErrExecutableBlocked = errors.New("executable files are not allowed")
+	ErrFileTooLarge      = errors.New("file too large")
+	ErrQuotaExceeded     = errors.New("storage quota exceeded")
+	ErrFileLimitReached  = errors.New("maximum number of files reached")
+	// ErrSizeMismatch rejects uploads whose content length differs from the
+	// declared size, which usually means the body was truncated in transit.
+	ErrSizeMismatch = errors.New("uploaded size does not match declared size")
+	// ErrInvalidShareExpiry rejects share expiries in the past or beyond the
+	// configured horizon.
+	ErrInvalidShareExpiry = errors.New("invalid share expiry")
+	// ErrInvalidUpload rejects an upload part that lacks a required field.
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrTooManyUploads rejects requests carrying more than MaxUploadFiles files.
+	ErrTooManyUploads = errors.New("too many files in one upload")
+)
+
+// shareExpiryLeeway tolerates clock skew between clients and the server when an
+// expiry is checked against the current time.
+const shareExpiryLeeway = time.Minute
+
+// shareTokenAttempts bounds retries when a generated share token collides.
+const shareTokenAttempts = 3
 
 type DownloadedFile struct {
-	File        db.FileRecord
-	Blob        db.FileBlob
-	Data        []byte
+	File db.FileRecord
+	Blob db.FileBlob
+	// Body streams the decoded content, Blob.SizeBytes long. The receiver must
+	// close it. Stat results carry no body.
+	Body        io.ReadCloser
 	ContentType string
 }
 
-func NewService(repo *db.Pool, storage *storage.SupabaseClient, maxUploadBytes int64) *Service {
-	return &Service{repo: repo, storage: storage, maxUploadBytes: maxUploadBytes}
+func NewService(repo *db.Pool, storage *storage.SupabaseClient, opts Options) *Service {
+	publicListLimit := opts.PublicListLimit
+	if publicListLimit <= 0 {
+		publicListLimit = db.DefaultListLimit
+	}
+	if publicListLimit > MaxPublicListLimit {
+		publicListLimit = MaxPublicListLimit
+	}
+	return &Service{
+		repo:                repo,
+		storage:             storage,
+		maxUploadBytes:      opts.MaxUploadBytes,
+		dedupWindow:         opts.DedupWindow,
+		remoteFetchTimeout:  opts.RemoteFetchTimeout,
+		chunkedDedup:        opts.ChunkedDedup,
+		compression:         opts.Compression,
+		blockExecutables:    opts.BlockExecutables,
+		publicListLimit:     publicListLimit,
+		roleQuotaBytes:      opts.RoleQuotaBytes,
+		strictMIME:          opts.StrictMIME,
+		uploadConcurrency:   max(opts.UploadConcurrency, 1),
+		keyPrefix:           normalizeKeyPrefix(opts.StorageKeyPrefix),
+		maxFilesPerUser:     opts.MaxFilesPerUser,
+		maxShareExpiry:      opts.MaxShareExpiry,
+		reportHideThreshold: opts.ReportHideThreshold,
+		verifyUploads:       opts.VerifyUploads,
+		folderLabels:        opts.FolderLabels,
+		maxUploadFiles:      opts.MaxUploadFiles,
+		mimeUploadLimits:    normalizeMimeLimits(opts.MimeUploadLimits),
+		mimeSampleBytes:     opts.MimeSampleBytes,
+		batches:             make(map[uuid.UUID]*uploadBatch),
+	}
 }
 
-// UploadResult contains metadata for the created file records.
+// UploadResult describes the outcome for one uploaded file. Err is set when that
+// file was rejected or failed; File and Blob are only meaningful when it is nil.
 type UploadResult struct {
-	File  db.FileRecord
-	Blob  db.FileBlob
-	IsNew bool
+	Filename string
+	File     db.FileRecord
+	Blob     db.FileBlob
+	IsNew    bool
+	Err      error
 }
 
+// Upload stores each input independently so one rejected file does not abort the
+// rest of the batch. Up to UploadConcurrency inputs are processed at once;
+// results keep the order of inputs. Per-file failures are reported on the
+// results; the returned error is reserved for failures that affect the whole
+// batch.
 func (s *Service) Upload(ctx context.Context, owner db.User, inputs []UploadInput) ([]UploadResult, error) {
-	results := make([]UploadResult, 0, len(inputs))
+	if s.maxUploadFiles > 0 && len(inputs) > s.maxUploadFiles {
+		metrics.FilesUploaded.WithLabelValues(metrics.OutcomeError).Add(float64(len(inputs)))
+		return nil, fmt.Errorf("%d files sent, at most %d allowed: %w", len(inputs), s.maxUploadFiles, ErrTooManyUploads)
+	}
 
-	originalUsage, _, err := s.repo.StorageUsage(ctx, owner.ID)
+	batch, done, err := s.acquireBatch(ctx, owner)
 	if err != nil {
+		metrics.FilesUploaded.WithLabelValues(metrics.OutcomeError).Add(float64(len(inputs)))
 		return nil, err
 	}
+	defer done()
+
+	results := make([]UploadResult, len(inputs))
+	slots := make(chan struct{}, s.uploadConcurrency)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result, err := s.uploadOne(ctx, owner, input, batch)
+			if err != nil {
+				metrics.FilesUploaded.WithLabelValues(metrics.OutcomeError).Inc()
+				results[i] = UploadResult{Filename: input.Filename, Err: err}
+				return
+			}
+			results[i] = *result
+		}()
+	}
+	wg.Wait()
 
-	for _, input := range inputs {
-		data, hash, detectedMIME, err := readAndHash(input.Reader, input.DeclaredMIME)
-		if err != nil {
-			return nil, err
-		}
-		size := int64(len(data))
+	return results, nil
+}
 
-		if s.maxUploadBytes > 0 && size > s.maxUploadBytes {
-			return nil, fmt.Errorf("file %s exceeds max upload size of %d bytes", input.Filename, s.maxUploadBytes)
-		}
+// uploadOne stores a single input, charging its size to the batch when a new file
+// record is created.
+func (s *Service) uploadOne(ctx context.Context, owner db.User, input UploadInput, batch *uploadBatch) (_ *UploadResult, err error) {
+	if strings.TrimSpace(input.Filename) == "" {
+		return nil, fmt.Errorf("%w: missing filename", ErrInvalidUpload)
+	}
 
-		if owner.QuotaBytes > 0 && originalUsage+size > owner.QuotaBytes {
-			return nil, fmt.Errorf("storage quota exceeded")
+	// A declared size lets oversized and over-quota files fail before the body
+	// is read. It is only trusted once the bytes read confirm it. The MIME type
+	// is not known yet, so only the largest limit applies here.
+	readLimit := s.maxUploadLimit()
+	if input.Size > 0 {
+		if readLimit > 0 && input.Size > readLimit {
+			return nil, fmt.Errorf("file %s exceeds max upload size of %d bytes: %w", input.Filename, readLimit, ErrFileTooLarge)
+		}
+		if !batch.fits(input.Size) {
+			return nil, ErrQuotaExceeded
 		}
+	}
+
+	// Without a declared size, stop reading one byte past the limit so an
+	// oversized body is rejected without being buffered in full.
+	reader := input.Reader
+	if readLimit > 0 {
+		reader = io.LimitReader(reader, readLimit+1)
+	}
+	data, hash, detectedMIME, err := readAndHash(reader, input.DeclaredMIME, s.mimeSampleBytes)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(len(data))
+	if limit := s.uploadLimit(detectedMIME); limit > 0 && size > limit {
+		return nil, fmt.Errorf("file %s (%s) exceeds max upload size of %d bytes: %w", input.Filename, detectedMIME, limit, ErrFileTooLarge)
+	}
+	if input.Size > 0 && size != input.Size {
+		return nil, fmt.Errorf("file %s: read %d of %d declared bytes: %w", input.Filename, size, input.Size, ErrSizeMismatch)
+	}
+
+	if s.blockExecutables && isExecutable(data) {
+		return nil, fmt.Errorf("file %s: %w", input.Filename, ErrExecutableBlocked)
+	}
+
+	if s.strictMIME && MimeMismatch(input.Filename, input.DeclaredMIME, detectedMIME) {
+		return nil, fmt.Errorf("file %s: %w", input.Filename, ErrMimeMismatch)
+	}
 
-		blob, err := s.repo.GetBlobByHash(ctx, hash)
+	unlock := batch.lockHash(hash)
+	defer unlock()
+
+	blob, err := s.repo.GetBlobByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	// A retried upload of the same content under the same name resolves to
+	// the record created by the earlier attempt instead of a duplicate.
+	if blob != nil && s.dedupWindow > 0 {
+		existing, err := s.repo.FindRecentDuplicate(ctx, owner.ID, blob.ID, input.Filename, time.Now().Add(-s.dedupWindow))
 		if err != nil {
 			return nil, err
 		}
+		if existing != nil {
+			metrics.DedupHits.Inc()
+			return &UploadResult{Filename: input.Filename, File: *existing, Blob: *blob, IsNew: false}, nil
+		}
+	}
 
-		storageKey := buildStorageKey(hash)
-		isNew := false
-		if blob == nil {
-			if err := s.storage.Upload(ctx, storageKey, data, detectedMIME); err != nil {
-				return nil, err
-			}
-			blob, err = s.repo.InsertBlob(ctx, hash, size, detectedMIME, storageKey)
+	if err := batch.reserve(size); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			batch.release(size)
+		}
+	}()
+
+	storageKey := s.keyPrefix + buildStorageKey(hash)
+	isNew := false
+	if blob == nil {
+		if s.chunkedDedup {
+			blob, isNew, err = s.storeChunked(ctx, data, hash, detectedMIME, storageKey)
 			if err != nil {
 				return nil, err
 			}
-			isNew = true
 		} else {
-			if err := s.repo.IncrementBlobRef(ctx, blob.ID); err != nil {
+			stored, encoding := data, encodingIdentity
+			if s.compression && isCompressible(detectedMIME) {
+				stored, encoding = compressForStorage(data)
+			}
+			if err := s.storage.Upload(ctx, storageKey, stored, detectedMIME); err != nil {
 				return nil, err
 			}
-			blob.RefCount++
-		}
-
-		record := &db.FileRecord{
-			OwnerID:            owner.ID,
-			BlobID:             blob.ID,
-			FilenameOriginal:   input.Filename,
-			FilenameNormalized: strings.ToLower(input.Filename),
-			SizeBytesOriginal:  size,
-			Tags:               []string{},
-		}
-		if input.DeclaredMIME != "" {
-			declared := input.DeclaredMIME
-			record.MimeDeclared = &declared
+			if err := s.verifyStored(ctx, storageKey, stored); err != nil {
+				return nil, err
+			}
+			blob = &db.FileBlob{
+				Sha256:          hash,
+				SizeBytes:       size,
+				MimeDetected:    detectedMIME,
+				StorageKey:      storageKey,
+				Encoding:        encoding,
+				StoredSizeBytes: int64(len(stored)),
+			}
+			blob.Width, blob.Height = imageDimensions(data, detectedMIME)
+			// A concurrent upload of the same content may have inserted the blob
+			// since GetBlobByHash; InsertBlob then adds a reference to it instead
+			// and the object stored above is not needed.
+			isNew, err = s.repo.InsertBlob(ctx, blob)
+			if err != nil {
+				return nil, err
+			}
+			if !isNew {
+				s.deleteObjects(ctx, []string{storageKey})
+			}
 		}
-
-		if err := s.repo.InsertFile(ctx, record); err != nil {
+	} else {
+		if err := s.repo.IncrementBlobRef(ctx, blob.ID); err != nil {
 			return nil, err
 		}
+		blob.RefCount++
+	}
 
-		results = append(results, UploadResult{File: *record, Blob: *blob, IsNew: isNew})
-		originalUsage += size
+	record := &db.FileRecord{
+		OwnerID:            owner.ID,
+		BlobID:             blob.ID,
+		FilenameOriginal:   input.Filename,
+		FilenameNormalized: strings.ToLower(input.Filename),
+		SizeBytesOriginal:  size,
+		Tags:               []string{},
+	}
+	if input.DeclaredMIME != "" {
+		declared := input.DeclaredMIME
+		record.MimeDeclared = &declared
 	}
 
-	return results, nil
+	if err := s.repo.InsertFile(ctx, record); err != nil {
+		return nil, err
+	}
+
+	metrics.FilesUploaded.WithLabelValues(metrics.OutcomeSuccess).Inc()
+	metrics.BytesUploaded.Add(float64(size))
+	if !isNew {
+		metrics.DedupHits.Inc()
+	}
+
+	return &UploadResult{Filename: input.Filename, File: *record, Blob: *blob, IsNew: isNew}, nil
 }
 
-func readAndHash(r io.Reader, declaredMIME string) ([]byte, string, string, error) {
+func readAndHash(r io.Reader, declaredMIME string, sampleSize int) ([]byte, string, string, error) {
 	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, "", "", err
@@ -131,7 +394,7 @@ func readAndHash(r io.Reader, declaredMIME string) ([]byte, string, string, erro
 	hash := sha256.Sum256(data)
 	hashHex := hex.EncodeToString(hash[:])
 
-	detected := http.DetectContentType(sampleBytes(data))
+	detected := detectMIME(data, sampleSize)
 	if declaredMIME != "" && !strings.EqualFold(declaredMIME, detected) {
 		if detected == "application/octet-stream" {
 			detected = declaredMIME
@@ -141,47 +404,134 @@ func readAndHash(r io.Reader, declaredMIME string) ([]byte, string, string, erro
 	return data, hashHex, detected, nil
 }
 
-func sampleBytes(data []byte) []byte {
-	if len(data) < 512 {
-		return data
+// normalizeKeyPrefix trims surrounding slashes and terminates a non-empty
+// prefix with one, so it can be prepended to any key.
+func normalizeKeyPrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
 	}
-	return data[:512]
+	return prefix + "/"
 }
 
+// buildStorageKey returns a new object key for a blob with the given hash. The
+// random suffix gives every blob row its own object, so deleting the object of a
+// purged blob can never remove one that a later upload of the same content
+// stored.
 func buildStorageKey(hash string) string {
+	return objectKey("sha256", hash)
+}
+
+// buildChunkKey is buildStorageKey for chunks.
+func buildChunkKey(hash string) string {
+	return objectKey("chunks", hash)
+}
+
+func objectKey(kind, hash string) string {
 	if len(hash) < 4 {
-		return fmt.Sprintf("sha256/%s", hash)
+		return fmt.Sprintf("%s/%s/%s", kind, hash, uuid.NewString())
 	}
-	return fmt.Sprintf("sha256/%s/%s/%s", hash[:2], hash[2:4], hash)
+	return fmt.Sprintf("%s/%s/%s/%s/%s", kind, hash[:2], hash[2:4], hash, uuid.NewString())
 }
 
-func (s *Service) DownloadOwnedFile(ctx context.Context, fileID, ownerID uuid.UUID) (*DownloadedFile, error) {
-	fileWithBlob, err := s.repo.GetFileWithBlob(ctx, fileID, ownerID)
+// storeChunked splits data into content-defined chunks, uploads the ones storage
+// does not have yet and records the blob as their ordered concatenation. It
+// reports false when a concurrent upload created the blob first.
+func (s *Service) storeChunked(ctx context.Context, data []byte, hash, mime, storageKey string) (*db.FileBlob, bool, error) {
+	pieces := splitChunks(data)
+	chunkIDs := make([]uuid.UUID, 0, len(pieces))
+	for _, piece := range pieces {
+		sum := sha256.Sum256(piece)
+		chunkHash := hex.EncodeToString(sum[:])
+
+		chunk, err := s.repo.ReferenceChunk(ctx, chunkHash)
+		if err != nil {
+			return nil, false, err
+		}
+		if chunk == nil {
+			chunkKey := s.keyPrefix + buildChunkKey(chunkHash)
+			if err := s.storage.Upload(ctx, chunkKey, piece, "application/octet-stream"); err != nil {
+				return nil, false, err
+			}
+			if err := s.verifyStored(ctx, chunkKey, piece); err != nil {
+				return nil, false, err
+			}
+			chunk, err = s.repo.UpsertChunk(ctx, chunkHash, int64(len(piece)), chunkKey)
+			if err != nil {
+				return nil, false, err
+			}
+			if chunk.StorageKey != chunkKey {
+				// A concurrent upload recorded the chunk first.
+				s.deleteObjects(ctx, []string{chunkKey})
+			}
+		}
+		chunkIDs = append(chunkIDs, chunk.ID)
+	}
+
+	blob := &db.FileBlob{
+		Sha256:       hash,
+		SizeBytes:    int64(len(data)),
+		MimeDetected: mime,
+		StorageKey:   storageKey,
+		Chunked:      true,
+	}
+	blob.Width, blob.Height = imageDimensions(data, mime)
+	inserted, err := s.repo.InsertBlob(ctx, blob)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	if fileWithBlob == nil {
-		return nil, ErrNotFound
+	if !inserted {
+		// Another upload stored the same content first; its blob already
+		// references these chunks, so give back the references taken above.
+		if err := s.repo.ReleaseChunks(ctx, chunkIDs); err != nil {
+			return nil, false, err
+		}
+		return blob, false, nil
+	}
+	if err := s.repo.InsertBlobChunks(ctx, blob.ID, chunkIDs); err != nil {
+		return nil, false, err
 	}
+	return blob, true, nil
+}
 
-	data, contentType, err := s.storage.Download(ctx, fileWithBlob.Blob.StorageKey)
+// readBlob returns a blob's whole decoded content and the content type reported
+// by storage. Downloads stream the content with openBlob instead.
+func (s *Service) readBlob(ctx context.Context, blob db.FileBlob) ([]byte, string, error) {
+	body, contentType, err := s.openBlob(ctx, blob)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	defer body.Close()
 
-	if err := s.repo.IncrementDownload(ctx, fileWithBlob.File.ID); err != nil {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+func (s *Service) DownloadOwnedFile(ctx context.Context, fileID, ownerID uuid.UUID) (*DownloadedFile, error) {
+	fileWithBlob, err := s.ownedFile(ctx, fileID, ownerID)
+	if err != nil {
 		return nil, err
 	}
+	return s.download(ctx, *fileWithBlob)
+}
 
-	return &DownloadedFile{
-		File:        fileWithBlob.File,
-		Blob:        fileWithBlob.Blob,
-		Data:        data,
-		ContentType: resolveContentType(contentType, fileWithBlob.File, fileWithBlob.Blob),
-	}, nil
+// ownedFile looks up a downloadable file owned by ownerID.
+func (s *Service) ownedFile(ctx context.Context, fileID, ownerID uuid.UUID) (*db.FileWithBlob, error) {
+	fileWithBlob, err := s.repo.GetFileWithBlob(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if fileWithBlob == nil || fileWithBlob.File.Expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	return fileWithBlob, nil
 }
 
-func (s *Service) DownloadSharedFile(ctx context.Context, token string) (*DownloadedFile, error) {
+// sharedFile looks up the file behind an active share token.
+func (s *Service) sharedFile(ctx context.Context, token string) (*db.FileWithBlob, error) {
 	fileRec, blobRec, _, err := s.repo.GetFileByShareToken(ctx, token)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -192,24 +542,62 @@ func (s *Service) DownloadSharedFile(ctx context.Context, token string) (*Downlo
 	if fileRec == nil || blobRec == nil {
 		return nil, ErrNotFound
 	}
+	return &db.FileWithBlob{File: *fileRec, Blob: *blobRec}, nil
+}
 
-	data, contentType, err := s.storage.Download(ctx, blobRec.StorageKey)
+// download opens an authorized file's content for streaming. Callers close the
+// body and count the download with RecordDownload once it has been delivered.
+func (s *Service) download(ctx context.Context, fileWithBlob db.FileWithBlob) (*DownloadedFile, error) {
+	body, contentType, err := s.openBlob(ctx, fileWithBlob.Blob)
 	if err != nil {
 		return nil, err
 	}
-
-	if err := s.repo.IncrementDownload(ctx, fileRec.ID); err != nil {
+	// A client that disconnected while storage answered gets nothing.
+	if err := ctx.Err(); err != nil {
+		body.Close()
 		return nil, err
 	}
 
 	return &DownloadedFile{
-		File:        *fileRec,
-		Blob:        *blobRec,
-		Data:        data,
-		ContentType: resolveContentType(contentType, *fileRec, *blobRec),
+		File:        fileWithBlob.File,
+		Blob:        fileWithBlob.Blob,
+		Body:        body,
+		ContentType: resolveContentType(contentType, fileWithBlob.File, fileWithBlob.Blob),
 	}, nil
 }
 
+// recordDownloadTimeout bounds the background writes of RecordDownload.
+const recordDownloadTimeout = 5 * time.Second
+
+// RecordDownload counts a download once its body has been delivered in full and
+// stamps the blob's last access. The writes run in the background so they add no
+// latency to the response; failures are logged.
+func (s *Service) RecordDownload(downloaded *DownloadedFile) {
+	fileID, blobID := downloaded.File.ID, downloaded.Blob.ID
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), recordDownloadTimeout)
+		defer cancel()
+		if err := s.repo.IncrementDownload(ctx, fileID); err != nil {
+			log.Printf("increment download count of file %s failed: %v", fileID, err)
+		}
+		s.touchBlob(ctx, blobID)
+	}()
+}
+
+// blobAccessResolution is how precisely last_accessed_at tracks blob reads.
+// Coarse stamps are enough for tiering and keep downloads from writing on
+// every hit.
+const blobAccessResolution = time.Hour
+
+// touchBlob stamps the blob's last access. The update is conditional, so
+// within blobAccessResolution repeated downloads do not rewrite the row.
+// Failures are logged since they must not fail the download.
+func (s *Service) touchBlob(ctx context.Context, blobID uuid.UUID) {
+	if err := s.repo.TouchBlob(ctx, blobID, blobAccessResolution); err != nil {
+		log.Printf("touch blob %s failed: %v", blobID, err)
+	}
+}
+
 func resolveContentType(contentType string, file db.FileRecord, blob db.FileBlob) string {
 	if contentType != "" {
 		return contentType
@@ -222,8 +610,12 @@ func resolveContentType(contentType string, file db.FileRecord, blob db.FileBlob
 	}
 	return "application/octet-stream"
 }
+
+// DeleteFile soft-deletes an owned file. The blob reference and the share row
+// stay with the deleted file: share lookups skip it, and EmptyTrash releases
+// both when it purges the row, removing blobs no file references any more.
 func (s *Service) DeleteFile(ctx context.Context, fileID, ownerID uuid.UUID) (*db.FileRecord, error) {
-	fileWithBlob, err := s.repo.GetFileWithBlob(ctx, fileID, ownerID)
+	fileWithBlob, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
 	if err != nil || fileWithBlob == nil {
 		return nil, err
 	}
@@ -231,42 +623,349 @@ func (s *Service) DeleteFile(ctx context.Context, fileID, ownerID uuid.UUID) (*d
 	if _, err := s.repo.MarkFileDeleted(ctx, fileID, ownerID); err != nil {
 		return nil, err
 	}
+	return &fileWithBlob.File, nil
+}
+
+// ShareFile creates or updates the link share of a file owned by ownerID. Tokens
+// are always chosen by the server.
+func (s *Service) ShareFile(ctx context.Context, fileID, ownerID uuid.UUID, visibility string, expires *time.Time, notify bool) (*db.ShareRecord, error) {
+	share, err := s.shareFile(ctx, fileID, ownerID, visibility, expires, notify)
+	metrics.SharesCreated.WithLabelValues(metrics.Outcome(err)).Inc()
+	return share, err
+}
+
+// shareFile keeps the token of an existing share so links survive visibility and
+// expiry changes; new shares get a server-generated token.
+func (s *Service) shareFile(ctx context.Context, fileID, ownerID uuid.UUID, visibility string, expires *time.Time, notify bool) (*db.ShareRecord, error) {
+	if err := s.validateShareExpiry(expires); err != nil {
+		return nil, err
+	}
+
+	owned, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owned == nil {
+		return nil, ErrNotFound
+	}
 
-	refCount, err := s.repo.DecrementBlobRef(ctx, fileWithBlob.Blob.ID)
+	existing, err := s.repo.GetShareByFileID(ctx, fileID)
 	if err != nil {
 		return nil, err
 	}
+	if existing != nil && existing.Token != nil && *existing.Token != "" {
+		return s.repo.UpsertShare(ctx, fileID, visibility, existing.Token, expires, notify)
+	}
+	return withNewShareToken(func(token string) (*db.ShareRecord, error) {
+		return s.repo.UpsertShare(ctx, fileID, visibility, &token, expires, notify)
+	})
+}
 
-	if refCount <= 0 {
-		if err := s.repo.DeleteBlob(ctx, fileWithBlob.Blob.ID); err != nil {
+// withNewShareToken calls store with fresh random tokens until one is not
+// already taken, giving up after shareTokenAttempts collisions.
+func withNewShareToken(store func(token string) (*db.ShareRecord, error)) (*db.ShareRecord, error) {
+	for attempt := 1; ; attempt++ {
+		token, err := newShareToken()
+		if err != nil {
 			return nil, err
 		}
-		if err := s.storage.Delete(ctx, fileWithBlob.Blob.StorageKey); err != nil {
-			return nil, err
+		share, err := store(token)
+		if errors.Is(err, db.ErrShareTokenTaken) && attempt < shareTokenAttempts {
+			continue
 		}
+		return share, err
 	}
+}
 
-	_ = s.repo.DeleteShare(ctx, fileID)
+// UpdateShareExpiry sets a new expiry on the existing share of a file owned by
+// ownerID without touching its token. A nil expiry makes the share permanent.
+func (s *Service) UpdateShareExpiry(ctx context.Context, fileID, ownerID uuid.UUID, expires *time.Time) (*db.ShareRecord, error) {
+	if err := s.validateShareExpiry(expires); err != nil {
+		return nil, err
+	}
 
-	return &fileWithBlob.File, nil
+	owned, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owned == nil {
+		return nil, ErrNotFound
+	}
+
+	share, err := s.repo.UpdateShareExpiry(ctx, fileID, expires)
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, ErrNotFound
+	}
+	return share, nil
 }
 
-func (s *Service) ShareFile(ctx context.Context, fileID uuid.UUID, visibility string, token *string, expires *time.Time) (*db.ShareRecord, error) {
-	return s.repo.UpsertShare(ctx, fileID, visibility, token, expires)
+// RotateShareToken gives the existing share of a file owned by ownerID a new
+// random token, invalidating the previous link.
+func (s *Service) RotateShareToken(ctx context.Context, fileID, ownerID uuid.UUID) (*db.ShareRecord, error) {
+	owned, err := s.repo.GetFileWithBlobPrimary(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if owned == nil {
+		return nil, ErrNotFound
+	}
+
+	share, err := withNewShareToken(func(token string) (*db.ShareRecord, error) {
+		return s.repo.RotateShareToken(ctx, fileID, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if share == nil {
+		return nil, ErrNotFound
+	}
+	return share, nil
+}
+
+// validateShareExpiry checks that expires lies in the future and within the
+// configured horizon. A nil expiry means the share never expires.
+func (s *Service) validateShareExpiry(expires *time.Time) error {
+	if expires == nil {
+		return nil
+	}
+	now := time.Now()
+	if expires.Before(now.Add(-shareExpiryLeeway)) {
+		return fmt.Errorf("%w: expiry %s is in the past", ErrInvalidShareExpiry, expires.UTC().Format(time.RFC3339))
+	}
+	if s.maxShareExpiry > 0 && expires.After(now.Add(s.maxShareExpiry+shareExpiryLeeway)) {
+		return fmt.Errorf("%w: expiry must be within %s", ErrInvalidShareExpiry, s.maxShareExpiry)
+	}
+	return nil
+}
+
+// RefreshStoredBytesGauge updates the stored deduplicated bytes gauge.
+func (s *Service) RefreshStoredBytesGauge(ctx context.Context) error {
+	total, err := s.repo.TotalBlobBytes(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.StoredBytes.Set(float64(total))
+	return nil
+}
+
+// SnapshotStorageUsage records today's usage for every user; see
+// db.SnapshotStorageUsage for why repeated runs are safe. It returns the number
+// of users snapshotted.
+func (s *Service) SnapshotStorageUsage(ctx context.Context) (int64, error) {
+	return s.repo.SnapshotStorageUsage(ctx)
+}
+
+// StorageUsageHistory returns the owner's daily usage snapshots between from and
+// to, inclusive.
+func (s *Service) StorageUsageHistory(ctx context.Context, ownerID uuid.UUID, from, to time.Time) ([]db.UsageSnapshot, error) {
+	return s.repo.ListUsageHistory(ctx, ownerID, from, to)
 }
 
 func (s *Service) RevokeShare(ctx context.Context, fileID uuid.UUID) error {
 	return s.repo.DeleteShare(ctx, fileID)
 }
 
+// PingStorage reports whether the blob store is reachable.
+func (s *Service) PingStorage(ctx context.Context) error {
+	return s.storage.Ping(ctx)
+}
+
 func (s *Service) StorageStats(ctx context.Context, ownerID uuid.UUID) (int64, int64, error) {
 	return s.repo.StorageUsage(ctx, ownerID)
 }
 
-func (s *Service) ListFiles(ctx context.Context, ownerID uuid.UUID, filter *db.FileFilter) ([]db.FileWithBlob, int, error) {
-	return s.repo.ListFiles(ctx, ownerID, filter)
+// DedupSummary describes how much storage deduplication saves the owner.
+type DedupSummary struct {
+	OriginalBytes int64
+	DedupedBytes  int64
+	// SharedFiles counts the owner's files whose content another user also stores.
+	SharedFiles int
+}
+
+func (s *Service) DedupSummary(ctx context.Context, ownerID uuid.UUID) (*DedupSummary, error) {
+	original, deduped, err := s.repo.StorageUsage(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := s.repo.CountFilesSharingBlobs(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return &DedupSummary{OriginalBytes: original, DedupedBytes: deduped, SharedFiles: shared}, nil
+}
+
+// nearQuotaRatio is the share of the quota above which NearingQuota is set.
+const nearQuotaRatio = 0.9
+
+// QuotaStatus reports usage against the quota enforced by Upload. QuotaBytes is
+// zero for unlimited users, in which case RemainingBytes is nil.
+type QuotaStatus struct {
+	OriginalBytes  int64
+	DedupedBytes   int64
+	QuotaBytes     int64
+	RemainingBytes *int64
+	NearingQuota   bool
+}
+
+// QuotaStatus returns the owner's usage and how close it is to their quota.
+// Remaining bytes are measured against original usage, as Upload does.
+func (s *Service) QuotaStatus(ctx context.Context, ownerID uuid.UUID) (*QuotaStatus, error) {
+	usage, err := s.repo.UserUsage(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if usage == nil {
+		return nil, ErrNotFound
+	}
+
+	status := &QuotaStatus{
+		OriginalBytes: usage.OriginalBytes,
+		DedupedBytes:  usage.DedupedBytes,
+		QuotaBytes:    s.effectiveQuota(db.User{Role: usage.Role, QuotaBytes: usage.QuotaBytes}),
+	}
+	if status.QuotaBytes > 0 {
+		remaining := max(status.QuotaBytes-usage.OriginalBytes, 0)
+		status.RemainingBytes = &remaining
+		status.NearingQuota = float64(usage.OriginalBytes) >= nearQuotaRatio*float64(status.QuotaBytes)
+	}
+	return status, nil
+}
+
+// StoredBytes returns the bytes the owner's distinct blobs occupy in storage
+// after compression.
+func (s *Service) StoredBytes(ctx context.Context, ownerID uuid.UUID) (int64, error) {
+	return s.repo.StoredUsage(ctx, ownerID)
+}
+
+// ListFiles returns up to limit of the owner's files after the cursor, if any.
+func (s *Service) ListFiles(ctx context.Context, ownerID uuid.UUID, filter *db.FileFilter, after *db.FileCursor, limit int) ([]db.FileWithBlob, int, error) {
+	if filter != nil {
+		filter.IncludeLinked = s.folderLabels
+	}
+	return s.repo.ListFiles(ctx, ownerID, filter, after, limit)
+}
+
+// ListPublicFiles returns up to limit publicly shared files after the cursor, if
+// any. Callers cap limit at PublicListLimit.
+func (s *Service) ListPublicFiles(ctx context.Context, filter *db.FileFilter, after *db.FileCursor, limit int) ([]db.FileWithBlob, int, error) {
+	return s.repo.ListPublicFiles(ctx, filter, after, limit)
+}
+
+// PublicListLimit is the maximum number of files a public listing returns.
+func (s *Service) PublicListLimit() int {
+	return s.publicListLimit
+}
+
+// effectiveQuota returns the quota enforced for owner, zero meaning unlimited.
+// The stored quota_bytes applies unless the owner's role has a configured quota
+// that is unlimited or larger, so role overrides never shrink an existing quota.
+func (s *Service) effectiveQuota(owner db.User) int64 {
+	roleQuota, ok := s.roleQuotaBytes[owner.Role]
+	if !ok || owner.QuotaBytes <= 0 {
+		return owner.QuotaBytes
+	}
+	if roleQuota <= 0 || roleQuota > owner.QuotaBytes {
+		return roleQuota
+	}
+	return owner.QuotaBytes
+}
+
+// DeleteFolderRecursive removes a folder together with all of its subfolders and
+// soft-deletes every file they contain.
+func (s *Service) DeleteFolderRecursive(ctx context.Context, folderID, ownerID uuid.UUID) (*db.FolderDeleteSummary, error) {
+	tree, err := s.repo.ListFolderTree(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tree) == 0 {
+		return nil, ErrNotFound
+	}
+
+	ids := make([]uuid.UUID, 0, len(tree))
+	for _, folder := range tree {
+		ids = append(ids, folder.ID)
+	}
+
+	summary, err := s.repo.DeleteFolderTree(ctx, ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ErrInvalidFileExpiry rejects file expiries that are not in the future.
+var ErrInvalidFileExpiry = errors.New("expiry must be in the future")
+
+// SetFileExpiry schedules an owned file for automatic deletion at expires, or
+// clears the schedule when expires is nil.
+func (s *Service) SetFileExpiry(ctx context.Context, fileID, ownerID uuid.UUID, expires *time.Time) error {
+	if expires != nil && !expires.After(time.Now()) {
+		return ErrInvalidFileExpiry
+	}
+	updated, err := s.repo.SetFileExpiry(ctx, fileID, ownerID, expires)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MaxDescriptionLength bounds file descriptions, in characters.
+const MaxDescriptionLength = 2000
+
+var ErrDescriptionTooLong = fmt.Errorf("description exceeds %d characters", MaxDescriptionLength)
+
+// SetFileDescription stores the trimmed description; nil or blank clears it.
+func (s *Service) SetFileDescription(ctx context.Context, fileID, ownerID uuid.UUID, description *string) error {
+	if description != nil {
+		trimmed := strings.TrimSpace(*description)
+		if utf8.RuneCountInString(trimmed) > MaxDescriptionLength {
+			return ErrDescriptionTooLong
+		}
+		description = &trimmed
+		if trimmed == "" {
+			description = nil
+		}
+	}
+	updated, err := s.repo.SetFileDescription(ctx, fileID, ownerID, description)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// expirySweepBatch bounds how many files SweepExpiredFiles loads per query.
+const expirySweepBatch = 100
+
+// SweepExpiredFiles deletes every file whose expiry has passed exactly as an
+// owner-initiated delete would. It returns the number of files deleted.
+func (s *Service) SweepExpiredFiles(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		refs, err := s.repo.ListExpiredFiles(ctx, expirySweepBatch)
+		if err != nil {
+			return deleted, err
+		}
+		for _, ref := range refs {
+			if _, err := s.DeleteFile(ctx, ref.ID, ref.OwnerID); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(refs) < expirySweepBatch {
+			return deleted, nil
+		}
+	}
 }
 
-func (s *Service) ListPublicFiles(ctx context.Context, filter *db.FileFilter) ([]db.FileWithBlob, int, error) {
-	return s.repo.ListPublicFiles(ctx, filter)
+func (s *Service) ListSharedWithMe(ctx context.Context, userID uuid.UUID, filter *db.FileFilter) ([]db.FileWithBlob, int, error) {
+	return s.repo.ListSharedWithMe(ctx, userID, filter)
 }
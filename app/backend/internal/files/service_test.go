@@ -0,0 +1,217 @@
+package files_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"vault/internal/db"
+	"vault/internal/db/dbtest"
+	"vault/internal/files"
+	"vault/internal/storage/storagetest"
+)
+
+// newTestService returns a service backed by a migrated test database and an
+// in-memory storage server.
+func newTestService(t *testing.T) (*files.Service, *db.Pool, *storagetest.Server) {
+	t.Helper()
+	pool := dbtest.NewPool(t)
+	server := storagetest.NewServer(t)
+	return files.NewService(pool, server.Storage(), files.Options{}), pool, server
+}
+
+func upload(t *testing.T, svc *files.Service, owner db.User, name, content string) files.UploadResult {
+	t.Helper()
+	results, err := svc.Upload(context.Background(), owner, []files.UploadInput{{
+		Filename: name,
+		Reader:   strings.NewReader(content),
+		Size:     int64(len(content)),
+	}})
+	if err != nil {
+		t.Fatalf("upload %s: %v", name, err)
+	}
+	if results[0].Err != nil {
+		t.Fatalf("upload %s: %v", name, results[0].Err)
+	}
+	return results[0]
+}
+
+func refCount(t *testing.T, pool *db.Pool, hash string) int {
+	t.Helper()
+	blob, err := pool.GetBlobByHash(context.Background(), hash)
+	if err != nil || blob == nil {
+		t.Fatalf("GetBlobByHash: %v, %v", blob, err)
+	}
+	return blob.RefCount
+}
+
+func TestShareFileRejectsNonOwner(t *testing.T) {
+	svc, pool, _ := newTestService(t)
+	ctx := context.Background()
+
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+	other := dbtest.CreateUser(t, pool, "other@example.com")
+	file := dbtest.InsertFile(t, pool, owner.ID, "report.txt", "quarterly numbers")
+
+	_, err := svc.ShareFile(ctx, file.File.ID, other.ID, "PUBLIC", nil, false)
+	if !errors.Is(err, files.ErrNotFound) {
+		t.Fatalf("ShareFile by non-owner: err = %v, want ErrNotFound", err)
+	}
+	share, err := pool.GetShareByFileID(ctx, file.File.ID)
+	if err != nil {
+		t.Fatalf("GetShareByFileID: %v", err)
+	}
+	if share != nil {
+		t.Fatalf("non-owner created share %s", share.ID)
+	}
+
+	if _, err := svc.ShareFile(ctx, file.File.ID, owner.ID, "PUBLIC", nil, false); err != nil {
+		t.Fatalf("ShareFile by owner: %v", err)
+	}
+}
+
+func TestDeleteKeepsContentSharedWithAnotherUser(t *testing.T) {
+	svc, pool, server := newTestService(t)
+	ctx := context.Background()
+	const content = "identical content stored once"
+
+	alice := dbtest.CreateUser(t, pool, "alice@example.com")
+	bob := dbtest.CreateUser(t, pool, "bob@example.com")
+	aliceFile := upload(t, svc, alice, "notes.txt", content)
+	bobFile := upload(t, svc, bob, "copy.txt", content)
+
+	if aliceFile.Blob.ID != bobFile.Blob.ID {
+		t.Fatalf("identical uploads got blobs %s and %s", aliceFile.Blob.ID, bobFile.Blob.ID)
+	}
+	hash := aliceFile.Blob.Sha256
+	if got := refCount(t, pool, hash); got != 2 {
+		t.Fatalf("ref_count after both uploads = %d, want 2", got)
+	}
+
+	if _, err := svc.DeleteFile(ctx, aliceFile.File.ID, alice.ID); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	// The trashed row keeps its reference until the trash is emptied.
+	if got := refCount(t, pool, hash); got != 2 {
+		t.Fatalf("ref_count after delete = %d, want 2", got)
+	}
+	assertDownload(t, svc, bobFile, bob, content)
+
+	if _, err := svc.EmptyTrash(ctx, alice.ID, false); err != nil {
+		t.Fatalf("EmptyTrash: %v", err)
+	}
+	if got := refCount(t, pool, hash); got != 1 {
+		t.Fatalf("ref_count after emptying the trash = %d, want 1", got)
+	}
+	if _, ok := server.Get(aliceFile.Blob.StorageKey); !ok {
+		t.Fatal("shared blob was removed from storage")
+	}
+	assertDownload(t, svc, bobFile, bob, content)
+}
+
+func assertDownload(t *testing.T, svc *files.Service, file files.UploadResult, owner db.User, want string) {
+	t.Helper()
+	downloaded, err := svc.DownloadOwnedFile(context.Background(), file.File.ID, owner.ID)
+	if err != nil {
+		t.Fatalf("DownloadOwnedFile: %v", err)
+	}
+	defer downloaded.Body.Close()
+	data, err := io.ReadAll(downloaded.Body)
+	if err != nil {
+		t.Fatalf("read download: %v", err)
+	}
+	if string(data) != want {
+		t.Fatalf("downloaded %q, want %q", data, want)
+	}
+}
+
+func TestReuploadAfterPurgeSurvivesDelayedDelete(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("chunked=%t", chunked), func(t *testing.T) {
+			pool := dbtest.NewPool(t)
+			server := storagetest.NewServer(t)
+			svc := files.NewService(pool, server.Storage(), files.Options{ChunkedDedup: chunked})
+			ctx := context.Background()
+			const content = "content uploaded, purged and uploaded again"
+
+			owner := dbtest.CreateUser(t, pool, "owner@example.com")
+			first := upload(t, svc, owner, "first.txt", content)
+			keys := []string{first.Blob.StorageKey}
+			chunks, err := pool.ListBlobChunks(ctx, first.Blob.ID)
+			if err != nil {
+				t.Fatalf("ListBlobChunks: %v", err)
+			}
+			for _, chunk := range chunks {
+				keys = append(keys, chunk.StorageKey)
+			}
+
+			if _, err := svc.DeleteFile(ctx, first.File.ID, owner.ID); err != nil {
+				t.Fatalf("DeleteFile: %v", err)
+			}
+			if _, err := svc.EmptyTrash(ctx, owner.ID, false); err != nil {
+				t.Fatalf("EmptyTrash: %v", err)
+			}
+			// As if the purge's storage deletes had failed and were retried
+			// only after the same content came back.
+			if err := pool.EnqueueStorageDeletes(ctx, keys, errors.New("storage unavailable")); err != nil {
+				t.Fatalf("EnqueueStorageDeletes: %v", err)
+			}
+
+			second := upload(t, svc, owner, "second.txt", content)
+			if _, err := svc.SweepStorageGC(ctx); err != nil {
+				t.Fatalf("SweepStorageGC: %v", err)
+			}
+			assertDownload(t, svc, second, owner, content)
+		})
+	}
+}
+
+func TestCreateFromContentStaysWithinQuota(t *testing.T) {
+	svc, pool, _ := newTestService(t)
+	ctx := context.Background()
+	const content = "ten bytes!"
+
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+	// Room for the upload and two references, not a third.
+	owner.QuotaBytes = 35
+	original := upload(t, svc, owner, "original.txt", content)
+
+	const attempts = 20
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.CreateFromContent(ctx, owner, original.Blob.Sha256, fmt.Sprintf("copy-%d.txt", i))
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, files.ErrQuotaExceeded):
+			t.Fatalf("CreateFromContent: %v", err)
+		}
+	}
+	if created != 2 {
+		t.Fatalf("created %d references, want 2", created)
+	}
+	if got := refCount(t, pool, original.Blob.Sha256); got != 3 {
+		t.Fatalf("ref_count = %d, want 3", got)
+	}
+	usage, _, err := pool.StorageUsage(ctx, owner.ID)
+	if err != nil {
+		t.Fatalf("StorageUsage: %v", err)
+	}
+	if usage != 30 {
+		t.Fatalf("usage = %d, want 30", usage)
+	}
+}
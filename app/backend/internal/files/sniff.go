@@ -0,0 +1,73 @@
+package files
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// defaultMimeSampleBytes is what http.DetectContentType itself considers. Zip
+// containers need more to reach the entry names that identify them.
+const defaultMimeSampleBytes = 512
+
+var zipLocalHeader = []byte("PK\x03\x04")
+
+// detectMIME classifies content from at most sampleSize leading bytes of data.
+// Zip archives are refined to the office or package format they contain when
+// the sample reaches the identifying entries.
+func detectMIME(data []byte, sampleSize int) string {
+	if sampleSize <= 0 {
+		sampleSize = defaultMimeSampleBytes
+	}
+	sample := data[:min(len(data), sampleSize)]
+	detected := http.DetectContentType(sample)
+	if detected == "application/zip" {
+		if container := zipContainerType(sample); container != "" {
+			return container
+		}
+	}
+	return detected
+}
+
+// zipContainerType inspects the local file headers present in sample. ODF and
+// EPUB store their type uncompressed in a leading "mimetype" entry; OOXML and
+// JAR files are recognised by their entry paths.
+func zipContainerType(sample []byte) string {
+	for offset := 0; ; {
+		i := bytes.Index(sample[offset:], zipLocalHeader)
+		if i < 0 {
+			return ""
+		}
+		header := sample[offset+i:]
+		offset += i + len(zipLocalHeader)
+		if len(header) < 30 {
+			return ""
+		}
+		nameLen := int(binary.LittleEndian.Uint16(header[26:28]))
+		extraLen := int(binary.LittleEndian.Uint16(header[28:30]))
+		if len(header) < 30+nameLen {
+			return ""
+		}
+		name := string(header[30 : 30+nameLen])
+
+		switch {
+		case name == "mimetype":
+			size := int(binary.LittleEndian.Uint32(header[18:22]))
+			start := 30 + nameLen + extraLen
+			if size > 0 && len(header) >= start+size {
+				if content := string(header[start : start+size]); strings.HasPrefix(content, "application/") {
+					return content
+				}
+			}
+		case strings.HasPrefix(name, "word/"):
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		case strings.HasPrefix(name, "xl/"):
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		case strings.HasPrefix(name, "ppt/"):
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		case name == "META-INF/MANIFEST.MF":
+			return "application/java-archive"
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package files
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+// The Stat* functions authorize exactly like their Download* counterparts but
+// only load metadata: Data is nil, nothing is read from storage and the download
+// is not counted. They back HEAD requests.
+
+func (s *Service) StatOwnedFile(ctx context.Context, fileID, ownerID uuid.UUID) (*DownloadedFile, error) {
+	fileWithBlob, err := s.ownedFile(ctx, fileID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return stat(*fileWithBlob), nil
+}
+
+func (s *Service) StatGrantedFile(ctx context.Context, fileID, userID uuid.UUID) (*DownloadedFile, error) {
+	fileWithBlob, err := s.grantedFile(ctx, fileID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return stat(*fileWithBlob), nil
+}
+
+func (s *Service) StatSharedFile(ctx context.Context, token string) (*DownloadedFile, error) {
+	fileWithBlob, err := s.sharedFile(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return stat(*fileWithBlob), nil
+}
+
+func (s *Service) StatFolderSharedFile(ctx context.Context, token, password string, fileID uuid.UUID) (*DownloadedFile, error) {
+	fileWithBlob, err := s.folderSharedFile(ctx, token, password, fileID)
+	if err != nil {
+		return nil, err
+	}
+	return stat(*fileWithBlob), nil
+}
+
+// OpenFile opens the content of a file resolved by one of the Stat methods.
+// Stating first lets handlers answer a conditional request without a storage
+// request. Callers close the body and count the download with RecordDownload
+// once it has been delivered.
+func (s *Service) OpenFile(ctx context.Context, stat *DownloadedFile) (*DownloadedFile, error) {
+	return s.download(ctx, db.FileWithBlob{File: stat.File, Blob: stat.Blob})
+}
+
+// stat resolves the content type the way download does: whole blobs are stored
+// with their detected type, which storage echoes back on GET.
+func stat(fileWithBlob db.FileWithBlob) *DownloadedFile {
+	storedType := ""
+	if !fileWithBlob.Blob.Chunked {
+		storedType = fileWithBlob.Blob.MimeDetected
+	}
+	return &DownloadedFile{
+		File:        fileWithBlob.File,
+		Blob:        fileWithBlob.Blob,
+		ContentType: resolveContentType(storedType, fileWithBlob.File, fileWithBlob.Blob),
+	}
+}
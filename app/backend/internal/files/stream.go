@@ -0,0 +1,90 @@
+package files
+
+import (
+	"context"
+	"io"
+
+	"vault/internal/db"
+	"vault/internal/storage"
+)
+
+// openBlob starts reading a blob's decoded content and returns the content type
+// reported by storage. Whole blobs stream straight from storage; chunked blobs
+// are reassembled part by part as the body is read.
+func (s *Service) openBlob(ctx context.Context, blob db.FileBlob) (io.ReadCloser, string, error) {
+	if !blob.Chunked {
+		body, contentType, err := s.storage.OpenDownload(ctx, blob.StorageKey)
+		if err != nil {
+			return nil, "", err
+		}
+		body, err = decodeStored(body, blob.Encoding)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, contentType, nil
+	}
+
+	chunks, err := s.repo.ListBlobChunks(ctx, blob.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	keys := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		keys = append(keys, chunk.StorageKey)
+	}
+	return newChunkReader(ctx, s.storage, keys), "", nil
+}
+
+// chunkReader concatenates the objects at keys, opening each only once the
+// previous one has been read to the end.
+type chunkReader struct {
+	ctx     context.Context
+	storage *storage.SupabaseClient
+	keys    []string
+	current io.ReadCloser
+}
+
+func newChunkReader(ctx context.Context, client *storage.SupabaseClient, keys []string) *chunkReader {
+	return &chunkReader{ctx: ctx, storage: client, keys: keys}
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.current == nil {
+			if len(c.keys) == 0 {
+				return 0, io.EOF
+			}
+			// Stop fetching parts as soon as the requester has gone away.
+			if err := c.ctx.Err(); err != nil {
+				return 0, err
+			}
+			body, _, err := c.storage.OpenDownload(c.ctx, c.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			c.current = body
+			c.keys = c.keys[1:]
+		}
+
+		n, err := c.current.Read(p)
+		if err == io.EOF {
+			c.current.Close()
+			c.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	c.keys = nil
+	if c.current == nil {
+		return nil
+	}
+	err := c.current.Close()
+	c.current = nil
+	return err
+}
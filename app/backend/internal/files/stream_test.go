@@ -0,0 +1,78 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"vault/internal/storage/storagetest"
+)
+
+func putChunks(server *storagetest.Server, parts ...string) []string {
+	keys := make([]string, 0, len(parts))
+	for i, part := range parts {
+		key := "chunks/" + string(rune('a'+i))
+		server.Put(key, []byte(part), "application/octet-stream")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestChunkReaderConcatenatesParts(t *testing.T) {
+	server := storagetest.NewServer(t)
+	keys := putChunks(server, "alpha-", "beta-", "gamma")
+
+	body := newChunkReader(context.Background(), server.Storage(), keys)
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read chunks: %v", err)
+	}
+	if string(data) != "alpha-beta-gamma" {
+		t.Fatalf("content = %q", data)
+	}
+	if got := server.Downloads(); got != 3 {
+		t.Fatalf("downloads = %d, want 3", got)
+	}
+}
+
+func TestChunkReaderFetchesNothingOnceCanceled(t *testing.T) {
+	server := storagetest.NewServer(t)
+	keys := putChunks(server, "alpha-", "beta-", "gamma")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := newChunkReader(ctx, server.Storage(), keys)
+	defer body.Close()
+
+	if _, err := body.Read(make([]byte, 64)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if got := server.Downloads(); got != 0 {
+		t.Fatalf("downloads = %d after cancellation, want 0", got)
+	}
+}
+
+func TestChunkReaderStopsBetweenParts(t *testing.T) {
+	server := storagetest.NewServer(t)
+	keys := putChunks(server, "alpha-", "beta-", "gamma")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body := newChunkReader(ctx, server.Storage(), keys)
+	defer body.Close()
+
+	buf := make([]byte, 64)
+	n, err := body.Read(buf)
+	if err != nil || string(buf[:n]) != "alpha-" {
+		t.Fatalf("first read = %q, %v", buf[:n], err)
+	}
+	cancel()
+	if _, err := io.ReadAll(body); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if got := server.Downloads(); got != 1 {
+		t.Fatalf("downloads = %d, want only the first part", got)
+	}
+}
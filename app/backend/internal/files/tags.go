@@ -0,0 +1,101 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// MaxTagFiles bounds how many files one bulk tag call accepts.
+const MaxTagFiles = 500
+
+// maxTagLength bounds a single tag, in characters.
+const maxTagLength = 64
+
+var ErrInvalidTag = errors.New("invalid tag")
+
+// TagResult reports the outcome for one requested file. Err is ErrNotFound
+// when the file does not exist, is deleted or belongs to another user.
+type TagResult struct {
+	FileID uuid.UUID
+	Err    error
+}
+
+// AddTagToFiles tags the owner's files in a single statement. Files that already
+// carry the tag keep one copy; files the owner does not have are reported per
+// file and left untouched.
+func (s *Service) AddTagToFiles(ctx context.Context, fileIDs []uuid.UUID, ownerID uuid.UUID, tag string) ([]TagResult, error) {
+	tag, err := normalizeTag(tag, len(fileIDs))
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.AddTagToFiles(ctx, ownerID, fileIDs, tag)
+	if err != nil {
+		return nil, err
+	}
+	return tagResults(fileIDs, updated), nil
+}
+
+// RemoveTagFromFiles removes tag from the owner's files in a single statement.
+func (s *Service) RemoveTagFromFiles(ctx context.Context, fileIDs []uuid.UUID, ownerID uuid.UUID, tag string) ([]TagResult, error) {
+	tag, err := normalizeTag(tag, len(fileIDs))
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.RemoveTagFromFiles(ctx, ownerID, fileIDs, tag)
+	if err != nil {
+		return nil, err
+	}
+	return tagResults(fileIDs, updated), nil
+}
+
+// RenameTag replaces oldTag with newTag across all of the owner's files and
+// returns how many files changed. Renaming a tag to itself changes nothing.
+func (s *Service) RenameTag(ctx context.Context, ownerID uuid.UUID, oldTag, newTag string) (int, error) {
+	oldTag, err := normalizeTag(oldTag, 0)
+	if err != nil {
+		return 0, err
+	}
+	newTag, err = normalizeTag(newTag, 0)
+	if err != nil {
+		return 0, err
+	}
+	if oldTag == newTag {
+		return 0, nil
+	}
+	return s.repo.RenameTag(ctx, ownerID, oldTag, newTag)
+}
+
+func normalizeTag(tag string, files int) (string, error) {
+	if files > MaxTagFiles {
+		return "", ErrTooManyFiles
+	}
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if utf8.RuneCountInString(tag) > maxTagLength {
+		return "", fmt.Errorf("%w: tag exceeds %d characters", ErrInvalidTag, maxTagLength)
+	}
+	return tag, nil
+}
+
+func tagResults(fileIDs, updated []uuid.UUID) []TagResult {
+	updatedSet := make(map[uuid.UUID]bool, len(updated))
+	for _, id := range updated {
+		updatedSet[id] = true
+	}
+	results := make([]TagResult, 0, len(fileIDs))
+	for _, id := range fileIDs {
+		result := TagResult{FileID: id}
+		if !updatedSet[id] {
+			result.Err = ErrNotFound
+		}
+		results = append(results, result)
+	}
+	return results
+}
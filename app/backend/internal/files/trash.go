@@ -0,0 +1,102 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// EmptyTrashResult reports what EmptyTrash removed, or would remove on a dry run.
+type EmptyTrashResult struct {
+	Files          int
+	OriginalBytes  int64
+	ReclaimedBytes int64
+	// Blobs counts the blobs garbage-collected with the files.
+	Blobs  int
+	DryRun bool
+}
+
+// storageGCBatch bounds how many queued objects SweepStorageGC retries per run.
+const storageGCBatch = 100
+
+// EmptyTrash permanently deletes every soft-deleted file of the owner, along
+// with the blobs and chunks only those files referenced. Row removal is
+// transactional; storage objects are deleted afterwards, and objects whose
+// delete fails are queued for SweepStorageGC instead of failing the call.
+// dryRun computes the same result without deleting anything.
+func (s *Service) EmptyTrash(ctx context.Context, ownerID uuid.UUID, dryRun bool) (*EmptyTrashResult, error) {
+	purged, err := s.repo.PurgeDeletedFiles(ctx, ownerID, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		s.deleteObjects(ctx, purged.StorageKeys)
+	}
+	return &EmptyTrashResult{
+		Files:          purged.Files,
+		OriginalBytes:  purged.OriginalBytes,
+		ReclaimedBytes: purged.ReclaimedBytes,
+		Blobs:          purged.Blobs,
+		DryRun:         dryRun,
+	}, nil
+}
+
+// deleteObjects removes storage objects whose rows are already gone, queueing
+// the ones that fail.
+func (s *Service) deleteObjects(ctx context.Context, keys []string) {
+	var failed []string
+	var lastErr error
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, key); err != nil {
+			failed = append(failed, key)
+			lastErr = err
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+	log.Printf("queueing %d storage deletes after failure: %v", len(failed), lastErr)
+	// The request context may be what failed the deletes; queue regardless.
+	if err := s.repo.EnqueueStorageDeletes(context.WithoutCancel(ctx), failed, lastErr); err != nil {
+		log.Printf("queue storage deletes failed, objects orphaned: %v", err)
+	}
+}
+
+// SweepStorageGC retries queued storage deletes and returns how many succeeded.
+// Objects a blob or chunk row points at again are dropped from the queue
+// without being deleted; keys written before they became unique per row can be
+// reused by a later upload of the same content.
+func (s *Service) SweepStorageGC(ctx context.Context) (int, error) {
+	entries, err := s.repo.ListStorageDeletes(ctx, storageGCBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	var errs []error
+	for _, entry := range entries {
+		inUse, err := s.repo.StorageKeyInUse(ctx, entry.StorageKey)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if inUse {
+			if err := s.repo.CompleteStorageDelete(ctx, entry.StorageKey, nil); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		deleteErr := s.storage.Delete(ctx, entry.StorageKey)
+		if err := s.repo.CompleteStorageDelete(ctx, entry.StorageKey, deleteErr); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if deleteErr == nil {
+			deleted++
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,35 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// ErrIntegrityCheckFailed reports that a freshly uploaded object does not read
+// back as the bytes that were sent.
+var ErrIntegrityCheckFailed = errors.New("stored object failed integrity check")
+
+// verifyStored re-downloads the object at key and compares it with the bytes
+// just uploaded. It is a no-op unless VerifyUploads is enabled, because it
+// doubles the transfer for every new object. A mismatching object is left in
+// place: keys are content-addressed and uploads overwrite, so the next upload
+// of the same content replaces it.
+func (s *Service) verifyStored(ctx context.Context, key string, sent []byte) error {
+	if !s.verifyUploads {
+		return nil
+	}
+	stored, _, err := s.storage.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("verify %s: %w", key, err)
+	}
+	if len(stored) != len(sent) {
+		return fmt.Errorf("%w: %s has %d bytes, uploaded %d", ErrIntegrityCheckFailed, key, len(stored), len(sent))
+	}
+	if storedSum, sentSum := sha256.Sum256(stored), sha256.Sum256(sent); !bytes.Equal(storedSum[:], sentSum[:]) {
+		return fmt.Errorf("%w: %s content differs from upload", ErrIntegrityCheckFailed, key)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+	"vault/internal/webhooks"
+)
+
+const accessLogTimeout = 5 * time.Second
+
+// recordAccess writes a download to the file access log in the background so a
+// slow or failing insert never delays or breaks the download itself.
+func (s *Server) recordAccess(r *http.Request, fileID uuid.UUID, kind string, userID *uuid.UUID, shareToken *string) {
+	if s.db == nil {
+		return
+	}
+
+	ip := clientIPAddress(r.RemoteAddr)
+	userAgent := r.UserAgent()
+	entry := db.FileAccess{
+		FileID:     fileID,
+		Kind:       kind,
+		UserID:     userID,
+		ShareToken: shareToken,
+		IP:         &ip,
+		UserAgent:  &userAgent,
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), accessLogTimeout)
+		defer cancel()
+		if err := s.db.InsertFileAccess(ctx, entry); err != nil {
+			log.Printf("record file access failed: %v", err)
+		}
+	}()
+}
+
+// publishDownload notifies the file owner's webhooks of a download through a
+// share, public or folder share link.
+func (s *Server) publishDownload(file db.FileRecord, kind string) {
+	data := webhooks.FileDataFor(file)
+	data.Via = strings.ToLower(kind)
+	s.webhooks.Publish(file.OwnerID, webhooks.EventFileDownloaded, data)
+}
+
+// recordLogin stores a login event. Failures are logged rather than failing the
+// sign-in.
+func (s *Server) recordLogin(r *http.Request, userID uuid.UUID) {
+	ip := clientIPAddress(r.RemoteAddr)
+	userAgent := r.UserAgent()
+	event := db.LoginEvent{
+		UserID:    userID,
+		Provider:  db.LoginProviderGoogle,
+		IP:        &ip,
+		UserAgent: &userAgent,
+	}
+	if err := s.db.RecordLogin(r.Context(), event); err != nil {
+		log.Printf("record login failed: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"vault/internal/files"
+)
+
+type blobLookupFile struct {
+	ID         string    `json:"id"`
+	Filename   string    `json:"filename"`
+	SizeBytes  int64     `json:"sizeBytes"`
+	UploadedAt time.Time `json:"uploadedAt"`
+}
+
+type blobLookupResponse struct {
+	Exists bool            `json:"exists"`
+	File   *blobLookupFile `json:"file,omitempty"`
+}
+
+// handleBlobLookup tells a sync client whether the caller already stores content
+// with the given SHA-256, so it can call createFileFromContent instead of
+// uploading. Content stored only by other users is reported as absent.
+func (s *Server) handleBlobLookup(w http.ResponseWriter, r *http.Request) {
+	session, err := s.sessionFromRequest(r)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if session == nil {
+		s.writeError(w, http.StatusUnauthorized, errors.New("unauthenticated"))
+		return
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid session user"))
+		return
+	}
+
+	existing, err := s.fileSvc.FindOwnedContent(r.Context(), ownerID, chi.URLParam(r, "sha256"))
+	if err != nil {
+		if errors.Is(err, files.ErrInvalidHash) {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if existing == nil {
+		s.writeJSON(w, http.StatusOK, blobLookupResponse{})
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, blobLookupResponse{
+		Exists: true,
+		File: &blobLookupFile{
+			ID:         existing.File.ID.String(),
+			Filename:   existing.File.FilenameOriginal,
+			SizeBytes:  existing.File.SizeBytesOriginal,
+			UploadedAt: existing.File.UploadedAt,
+		},
+	})
+}
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// concurrencyLimiter caps the number of in-flight operations per key.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	inFlight map[string]int
+	max      int
+}
+
+func newConcurrencyLimiter(max int) *concurrencyLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{inFlight: make(map[string]int), max: max}
+}
+
+// Acquire reserves a slot for key, reporting false when key is already at the cap.
+func (l *concurrencyLimiter) Acquire(key string) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[key] >= l.max {
+		return false
+	}
+	l.inFlight[key]++
+	return true
+}
+
+// Release frees a slot previously reserved with Acquire.
+func (l *concurrencyLimiter) Release(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[key] <= 1 {
+		delete(l.inFlight, key)
+		return
+	}
+	l.inFlight[key]--
+}
+
+// downloadConcurrencyMiddleware limits simultaneous download streams per user
+// (or per IP for anonymous callers). The slot is held until the handler returns,
+// which covers both completed transfers and client disconnects.
+func (s *Server) downloadConcurrencyMiddleware(next http.Handler) http.Handler {
+	if s.downloadSlots == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := s.clientKey(r)
+		if !s.downloadSlots.Acquire(key) {
+			s.writeError(w, http.StatusTooManyRequests, errors.New("too many concurrent downloads"))
+			return
+		}
+		defer s.downloadSlots.Release(key)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// uploadConcurrencyMiddleware limits simultaneous multipart GraphQL requests,
+// i.e. file uploads, per user (or per IP for anonymous callers). Uploads are
+// buffered in memory, so the slot is taken before the body is read and held
+// until the request completes or fails.
+func (s *Server) uploadConcurrencyMiddleware(next http.Handler) http.Handler {
+	if s.uploadSlots == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if r.Method != http.MethodPost || mediaType != "multipart/form-data" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := s.clientKey(r)
+		if !s.uploadSlots.Acquire(key) {
+			s.writeError(w, http.StatusTooManyRequests, errors.New("too many concurrent uploads"))
+			return
+		}
+		defer s.uploadSlots.Release(key)
+
+		next.ServeHTTP(w, r)
+	})
+}
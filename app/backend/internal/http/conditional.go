@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"vault/internal/db"
+)
+
+// setValidators sets the cache validators of a blob. Blob content never changes
+// once hashed, so its creation time serves as Last-Modified.
+func setValidators(w http.ResponseWriter, blob db.FileBlob) {
+	w.Header().Set("ETag", blobETag(blob))
+	w.Header().Set("Last-Modified", blob.CreatedAt.UTC().Format(http.TimeFormat))
+}
+
+// notModified reports whether the client's cached copy of blob is current.
+// If-None-Match takes precedence; If-Modified-Since is only consulted without
+// it, as RFC 9110 requires.
+func notModified(r *http.Request, blob db.FileBlob) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		etag := blobETag(blob)
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have one-second resolution.
+	return !blob.CreatedAt.Truncate(time.Second).After(since)
+}
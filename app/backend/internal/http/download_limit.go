@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"vault/internal/apperr"
+)
+
+// publicDownloadLimitMiddleware applies the download-specific limits to public
+// and share downloads: one bucket per client IP and, when configured, one per
+// share token so a leaked link cannot be scraped from many addresses.
+func (s *Server) publicDownloadLimitMiddleware(next http.Handler) http.Handler {
+	if s.downloadIPLimiter == nil && s.downloadTokenLimiter == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		if s.downloadIPLimiter != nil && !s.downloadIPLimiter.Allow("ip:"+clientIPAddress(r.RemoteAddr), now) {
+			s.writeError(w, http.StatusTooManyRequests, apperr.ErrRateLimited)
+			return
+		}
+		if token := chi.URLParam(r, "token"); token != "" && s.downloadTokenLimiter != nil && !s.downloadTokenLimiter.Allow("token:"+token, now) {
+			s.writeError(w, http.StatusTooManyRequests, apperr.ErrRateLimited)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isPublicDownloadPath reports whether path is a public or share download route.
+// These are limited by publicDownloadLimitMiddleware instead of the API limiter.
+func isPublicDownloadPath(path string) bool {
+	if !strings.HasSuffix(path, "/download") {
+		return false
+	}
+	return strings.HasPrefix(path, "/shares/") ||
+		strings.HasPrefix(path, "/folder-shares/") ||
+		strings.HasPrefix(path, "/public/files/")
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"vault/internal/apperr"
+)
+
+// storageRetryAfter is the Retry-After value, in seconds, sent with 502s caused
+// by storage outages.
+const storageRetryAfter = "5"
+
+// requestIDHeader echoes chi's request ID so clients can quote it to support.
+const requestIDHeader = "X-Request-Id"
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// exposeRequestID copies the request ID into the response headers, where
+// writeError picks it up without needing the request.
+func exposeRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(requestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errorCode prefers the code of a known sentinel and otherwise derives one
+// from the HTTP status, so ad-hoc errors still get a stable code.
+func errorCode(status int, err error) string {
+	if code := apperr.Code(err); code != apperr.CodeInternal {
+		return code
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return apperr.CodeBadRequest
+	case http.StatusUnauthorized:
+		return apperr.CodeUnauthorized
+	case http.StatusForbidden:
+		return apperr.CodeForbidden
+	case http.StatusNotFound:
+		return apperr.CodeNotFound
+	case http.StatusConflict:
+		return apperr.CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return apperr.CodeFileTooLarge
+	case http.StatusUnsupportedMediaType:
+		return apperr.CodeUnsupportedMediaType
+	case http.StatusTooManyRequests:
+		return apperr.CodeRateLimited
+	case http.StatusGatewayTimeout:
+		return apperr.CodeTimeout
+	case http.StatusBadGateway:
+		return apperr.CodeStorageUnavailable
+	}
+	if status < http.StatusInternalServerError {
+		return apperr.CodeBadRequest
+	}
+	return apperr.CodeInternal
+}
@@ -0,0 +1,167 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+	"vault/internal/files"
+)
+
+// sharePasswordHeader carries the password for protected folder shares. It is
+// never read from the query string, which ends up in logs and Referer headers.
+const sharePasswordHeader = "X-Share-Password"
+
+type sharedFolderFile struct {
+	ID          string    `json:"id"`
+	Filename    string    `json:"filename"`
+	SizeBytes   int64     `json:"sizeBytes"`
+	MimeType    string    `json:"mimeType"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+	DownloadURL string    `json:"downloadUrl"`
+}
+
+type sharedFolderListing struct {
+	Name      string             `json:"name"`
+	ExpiresAt *time.Time         `json:"expiresAt"`
+	Files     []sharedFolderFile `json:"files"`
+}
+
+// handleFolderShareListing returns a read-only listing of a shared folder's files.
+// Owner identity and private metadata (tags, hashes, download counts) are omitted.
+func (s *Server) handleFolderShareListing(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing share token"))
+		return
+	}
+
+	if s.sharePasswordBlocked(w, r, token) {
+		return
+	}
+	shared, err := s.fileSvc.OpenSharedFolder(r.Context(), token, sharePassword(r))
+	s.noteSharePassword(r, token, err)
+	if err != nil {
+		s.writeFolderShareError(w, err)
+		return
+	}
+
+	listing := sharedFolderListing{
+		Name:      shared.Folder.Name,
+		ExpiresAt: shared.Share.ExpiresAt,
+		Files:     make([]sharedFolderFile, 0, len(shared.Files)),
+	}
+	for _, entry := range shared.Files {
+		mimeType := entry.Blob.MimeDetected
+		if entry.File.MimeDeclared != nil && *entry.File.MimeDeclared != "" {
+			mimeType = *entry.File.MimeDeclared
+		}
+		listing.Files = append(listing.Files, sharedFolderFile{
+			ID:          entry.File.ID.String(),
+			Filename:    entry.File.FilenameOriginal,
+			SizeBytes:   entry.File.SizeBytesOriginal,
+			MimeType:    mimeType,
+			UploadedAt:  entry.File.UploadedAt,
+			DownloadURL: fmt.Sprintf("/folder-shares/%s/files/%s/download", token, entry.File.ID),
+		})
+	}
+
+	s.writeJSON(w, http.StatusOK, listing)
+}
+
+func (s *Server) handleFolderShareDownload(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing share token"))
+		return
+	}
+
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileID"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file id"))
+		return
+	}
+
+	if s.sharePasswordBlocked(w, r, token) {
+		return
+	}
+	if r.Method == http.MethodHead {
+		stat, err := s.fileSvc.StatFolderSharedFile(r.Context(), token, sharePassword(r), fileID)
+		s.noteSharePassword(r, token, err)
+		s.writeFileHead(w, r, stat, err, s.filenameOverride(r, false))
+		return
+	}
+
+	stat, err := s.fileSvc.StatFolderSharedFile(r.Context(), token, sharePassword(r), fileID)
+	s.noteSharePassword(r, token, err)
+	var downloaded *files.DownloadedFile
+	if err == nil {
+		if s.writeNotModified(w, r, stat) {
+			return
+		}
+		downloaded, err = s.fileSvc.OpenFile(r.Context(), stat)
+	}
+	if err != nil {
+		countDownload(db.AccessKindFolderShare, err)
+		s.writeFolderShareError(w, err)
+		return
+	}
+
+	s.recordAccess(r, downloaded.File.ID, db.AccessKindFolderShare, nil, &token)
+	s.publishDownload(downloaded.File, db.AccessKindFolderShare)
+	countDownload(db.AccessKindFolderShare, nil)
+	if s.writeFileResponse(throttleResponse(w, r, s.cfg.DownloadBytesPerSec), r, downloaded, s.filenameOverride(r, false)) {
+		s.fileSvc.RecordDownload(downloaded)
+	}
+}
+
+func (s *Server) writeFolderShareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, files.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, errors.New("share not found"))
+	case errors.Is(err, files.ErrSharePasswordRequired):
+		s.writeError(w, http.StatusUnauthorized, err)
+	case errors.Is(err, files.ErrSharePasswordInvalid):
+		s.writeError(w, http.StatusForbidden, err)
+	default:
+		s.writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func sharePassword(r *http.Request) string {
+	return r.Header.Get(sharePasswordHeader)
+}
+
+// sharePasswordKey scopes password failures to one share and client IP.
+func sharePasswordKey(r *http.Request, token string) string {
+	return token + "|" + clientIPAddress(r.RemoteAddr)
+}
+
+// sharePasswordBlocked answers 429 when the client guessed the share's
+// password wrong too often.
+func (s *Server) sharePasswordBlocked(w http.ResponseWriter, r *http.Request, token string) bool {
+	wait := s.sharePasswordThrottle.blockedFor(sharePasswordKey(r, token), time.Now())
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())+1))
+	s.writeError(w, http.StatusTooManyRequests, errors.New("too many wrong share passwords, try again later"))
+	return true
+}
+
+// noteSharePassword counts a wrong password against the client and clears its
+// record once the share opens.
+func (s *Server) noteSharePassword(r *http.Request, token string, err error) {
+	switch {
+	case errors.Is(err, files.ErrSharePasswordInvalid):
+		s.sharePasswordThrottle.fail(sharePasswordKey(r, token), time.Now())
+	case err == nil:
+		s.sharePasswordThrottle.reset(sharePasswordKey(r, token))
+	}
+}
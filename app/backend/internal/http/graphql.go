@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"vault/graph"
+)
+
+// LoadPersistedQueries reads a persisted query manifest: a JSON object mapping
+// each operation's hex SHA-256 to its query text. Hashes are returned in lower
+// case, as hashQuery produces them. An empty path yields nil.
+func LoadPersistedQueries(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest map[string]string
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("parse persisted query manifest: %w", err)
+	}
+	queries := make(map[string]string, len(manifest))
+	for hash, query := range manifest {
+		key := strings.ToLower(hash)
+		if hashQuery(query) != key {
+			return nil, fmt.Errorf("persisted query %s does not match its hash", hash)
+		}
+		queries[key] = query
+	}
+	return queries, nil
+}
+
+// newGraphQLServer mirrors handler.NewDefaultServer but sizes the automatic
+// persisted query cache from config, serves manifest queries ahead of it and, in
+// strict mode, only executes operations listed in the manifest.
+func (s *Server) newGraphQLServer() *handler.Server {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: graph.NewResolver(s.db, s.fileSvc, s.webhooks)}))
+
+	srv.AddTransport(transport.Websocket{KeepAlivePingInterval: 10 * time.Second})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(uploadTransport{transport.MultipartForm{
+		MaxUploadSize: s.cfg.MaxUploadBodyBytes(),
+		MaxMemory:     s.cfg.MaxUploadBodyBytes(),
+	}})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	cacheSize := int(s.cfg.GraphQLAPQCacheSize)
+	if cacheSize < 1 {
+		cacheSize = 1
+	}
+	apqCache := manifestCache{manifest: s.persistedQueries, cache: lru.New[string](cacheSize)}
+
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{Cache: apqCache})
+	if s.cfg.GraphQLPersistedOnly {
+		srv.Use(persistedQueryAllowlist{allowed: s.persistedQueries})
+	}
+	return srv
+}
+
+// manifestCache answers APQ lookups from the persisted query manifest before the
+// LRU of client-registered queries, so registrations can never evict manifest
+// entries.
+type manifestCache struct {
+	manifest map[string]string
+	cache    graphql.Cache[string]
+}
+
+var _ graphql.Cache[string] = manifestCache{}
+
+func (c manifestCache) Get(ctx context.Context, key string) (string, bool) {
+	if query, ok := c.manifest[key]; ok {
+		return query, true
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c manifestCache) Add(ctx context.Context, key string, query string) {
+	if _, ok := c.manifest[key]; ok {
+		return
+	}
+	c.cache.Add(ctx, key, query)
+}
+
+// persistedQueryAllowlist rejects operations whose query is not in the manifest.
+// It runs after the APQ extension has resolved hash-only requests.
+type persistedQueryAllowlist struct {
+	allowed map[string]string
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = persistedQueryAllowlist{}
+
+func (persistedQueryAllowlist) ExtensionName() string { return "PersistedQueryAllowlist" }
+
+func (persistedQueryAllowlist) Validate(graphql.ExecutableSchema) error { return nil }
+
+func (a persistedQueryAllowlist) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	if _, ok := a.allowed[hashQuery(params.Query)]; !ok {
+		return gqlerror.Errorf("operation is not in the persisted query allowlist")
+	}
+	return nil
+}
+
+func hashQuery(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hotlinkMiddleware rejects public/share downloads embedded from sites outside
+// the allowlist. The frontend host is always allowed. Requests without a Referer
+// (direct navigation, privacy-stripping browsers) pass unless disabled in config.
+func (s *Server) hotlinkMiddleware(next http.Handler) http.Handler {
+	if !s.cfg.HotlinkProtection {
+		return next
+	}
+
+	allowed := make([]string, 0, len(s.cfg.HotlinkAllowedDomains)+1)
+	if frontend, err := url.Parse(s.cfg.FrontendURL); err == nil && frontend.Hostname() != "" {
+		allowed = append(allowed, strings.ToLower(frontend.Hostname()))
+	}
+	for _, domain := range s.cfg.HotlinkAllowedDomains {
+		allowed = append(allowed, strings.ToLower(strings.TrimPrefix(domain, ".")))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			if !s.cfg.HotlinkAllowNoReferrer {
+				s.writeError(w, http.StatusForbidden, errors.New("hotlinking not allowed: missing referrer"))
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ref, err := url.Parse(referer)
+		if err != nil || !hostAllowed(strings.ToLower(ref.Hostname()), allowed) {
+			s.writeError(w, http.StatusForbidden, errors.New("hotlinking not allowed from this referrer"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hostAllowed reports whether host equals an allowed domain or is a subdomain of one.
+func hostAllowed(host string, allowed []string) bool {
+	if host == "" {
+		return false
+	}
+	for _, domain := range allowed {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
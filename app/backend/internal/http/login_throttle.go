@@ -0,0 +1,97 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// loginThrottle blocks an IP from the auth endpoints after too many failed OAuth
+// callbacks within a window. A successful login clears the IP's record.
+type loginThrottle struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+	limit    int
+	window   time.Duration
+	block    time.Duration
+}
+
+type loginFailures struct {
+	count        int
+	windowStart  time.Time
+	blockedUntil time.Time
+}
+
+// newLoginThrottle returns nil (no throttling) when limit is not positive.
+func newLoginThrottle(limit int, window, block time.Duration) *loginThrottle {
+	if limit <= 0 {
+		return nil
+	}
+	return &loginThrottle{
+		failures: make(map[string]*loginFailures),
+		limit:    limit,
+		window:   window,
+		block:    block,
+	}
+}
+
+// blockedFor returns how long ip remains blocked, or zero.
+func (t *loginThrottle) blockedFor(ip string, now time.Time) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.failures[ip]
+	if !ok || !now.Before(entry.blockedUntil) {
+		return 0
+	}
+	return entry.blockedUntil.Sub(now)
+}
+
+func (t *loginThrottle) fail(ip string, now time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.failures[ip]
+	if !ok || now.Sub(entry.windowStart) > t.window {
+		entry = &loginFailures{windowStart: now}
+		t.failures[ip] = entry
+	}
+	entry.count++
+	if entry.count >= t.limit {
+		entry.blockedUntil = now.Add(t.block)
+		entry.count = 0
+		entry.windowStart = now
+	}
+}
+
+func (t *loginThrottle) reset(ip string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.failures, ip)
+}
+
+// limitFailedLogins rejects auth requests from IPs that are currently blocked.
+func (s *Server) limitFailedLogins(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := s.loginThrottle.blockedFor(clientIPAddress(r.RemoteAddr), time.Now()); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())+1))
+			s.writeError(w, http.StatusTooManyRequests, errors.New("too many failed sign-in attempts, try again later"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
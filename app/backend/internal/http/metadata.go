@@ -0,0 +1,113 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// fileMetadata is the JSON manifest served by handleFileMetadata.
+type fileMetadata struct {
+	ID               string       `json:"id"`
+	Filename         string       `json:"filename"`
+	MimeDeclared     *string      `json:"mimeDeclared"`
+	SizeBytes        int64        `json:"sizeBytes"`
+	Description      *string      `json:"description"`
+	Tags             []string     `json:"tags"`
+	DownloadCount    int64        `json:"downloadCount"`
+	HiddenFromPublic bool         `json:"hiddenFromPublic"`
+	UploadedAt       time.Time    `json:"uploadedAt"`
+	ExpiresAt        *time.Time   `json:"expiresAt"`
+	Blob             blobMetadata `json:"blob"`
+	// Share is null when the file has never been shared.
+	Share *shareInfo `json:"share"`
+}
+
+type blobMetadata struct {
+	Sha256       string    `json:"sha256"`
+	SizeBytes    int64     `json:"sizeBytes"`
+	MimeDetected string    `json:"mimeDetected"`
+	Width        *int      `json:"width"`
+	Height       *int      `json:"height"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// handleFileMetadata returns the full metadata of an owned file as JSON, for
+// tooling that indexes the vault without downloading content.
+func (s *Server) handleFileMetadata(w http.ResponseWriter, r *http.Request) {
+	session, err := s.sessionFromRequest(r)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if session == nil {
+		s.writeError(w, http.StatusUnauthorized, errors.New("unauthenticated"))
+		return
+	}
+
+	ownerID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid session user"))
+		return
+	}
+
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileID"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file id"))
+		return
+	}
+
+	fileWithBlob, err := s.db.GetFileWithBlob(r.Context(), fileID, ownerID)
+	if err != nil {
+		log.Printf("file metadata: file lookup failed: %v", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("failed to load file"))
+		return
+	}
+	if fileWithBlob == nil {
+		s.writeError(w, http.StatusNotFound, errors.New("file not found"))
+		return
+	}
+
+	share, err := s.db.GetShareByFileID(r.Context(), fileID)
+	if err != nil {
+		log.Printf("file metadata: share lookup failed: %v", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("failed to load share"))
+		return
+	}
+
+	file, blob := fileWithBlob.File, fileWithBlob.Blob
+	tags := file.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	meta := fileMetadata{
+		ID:               file.ID.String(),
+		Filename:         file.FilenameOriginal,
+		MimeDeclared:     file.MimeDeclared,
+		SizeBytes:        file.SizeBytesOriginal,
+		Description:      file.Description,
+		Tags:             tags,
+		DownloadCount:    file.DownloadCount,
+		HiddenFromPublic: file.HiddenFromPublic,
+		UploadedAt:       file.UploadedAt,
+		ExpiresAt:        file.ExpiresAt,
+		Blob: blobMetadata{
+			Sha256:       blob.Sha256,
+			SizeBytes:    blob.SizeBytes,
+			MimeDetected: blob.MimeDetected,
+			Width:        blob.Width,
+			Height:       blob.Height,
+			CreatedAt:    blob.CreatedAt,
+		},
+	}
+	if share != nil {
+		info := newShareInfo(share, file, time.Now())
+		meta.Share = &info
+	}
+	s.writeJSON(w, http.StatusOK, meta)
+}
@@ -0,0 +1,13 @@
+package http
+
+import (
+	"strings"
+
+	"vault/internal/metrics"
+)
+
+// countDownload records a download attempt of the given access kind; a non-nil
+// err counts it as failed.
+func countDownload(kind string, err error) {
+	metrics.Downloads.WithLabelValues(strings.ToLower(kind), metrics.Outcome(err)).Inc()
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"vault/internal/files"
+)
+
+// handleFilePreview renders the start of an owned or granted text file inline.
+func (s *Server) handleFilePreview(w http.ResponseWriter, r *http.Request) {
+	session, err := s.sessionFromRequest(r)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if session == nil {
+		s.writeError(w, http.StatusUnauthorized, errors.New("unauthenticated"))
+		return
+	}
+
+	userID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid session user"))
+		return
+	}
+
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileID"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file id"))
+		return
+	}
+
+	preview, err := s.fileSvc.PreviewOwnedFile(r.Context(), fileID, userID, int(s.cfg.PreviewMaxBytes))
+	s.writePreview(w, preview, err, "file not found")
+}
+
+// handleSharePreview renders the start of a shared text file inline.
+func (s *Server) handleSharePreview(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing share token"))
+		return
+	}
+
+	preview, err := s.fileSvc.PreviewSharedFile(r.Context(), token, int(s.cfg.PreviewMaxBytes))
+	s.writePreview(w, preview, err, "share not found")
+}
+
+func (s *Server) writePreview(w http.ResponseWriter, preview *files.Preview, err error, notFound string) {
+	if err != nil {
+		switch {
+		case errors.Is(err, files.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, errors.New(notFound))
+		case errors.Is(err, files.ErrNotPreviewable):
+			s.writeError(w, http.StatusUnsupportedMediaType, err)
+		default:
+			s.writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	body := preview.Text
+	if preview.Truncated {
+		body = append(body, fmt.Sprintf("\n\n[preview truncated after %d bytes; download the file to see the rest]\n", len(preview.Text))...)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Preview-Truncated", strconv.FormatBool(preview.Truncated))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 1024
+)
+
+// handleShareQR renders the public download link of a share as a PNG QR code.
+// Only active PUBLIC or UNLISTED shares produce a code; ?size= sets the edge
+// length in pixels.
+func (s *Server) handleShareQR(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if token == "" {
+		s.writeError(w, http.StatusBadRequest, errors.New("missing share token"))
+		return
+	}
+
+	size := defaultQRSize
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < minQRSize || parsed > maxQRSize {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("size must be between %d and %d", minQRSize, maxQRSize))
+			return
+		}
+		size = parsed
+	}
+
+	_, _, share, err := s.db.GetFileByShareToken(r.Context(), token)
+	if err != nil || share == nil {
+		s.writeError(w, http.StatusNotFound, errors.New("share not found"))
+		return
+	}
+	switch strings.ToUpper(share.Visibility) {
+	case "PUBLIC", "UNLISTED":
+	default:
+		s.writeError(w, http.StatusNotFound, errors.New("share not found"))
+		return
+	}
+
+	link := s.absoluteURL(r, "/shares/"+token+"/download")
+	png, err := qrcode.Encode(link, qrcode.Medium, size)
+	if err != nil {
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(png)
+}
+
+// absoluteURL resolves path against PublicAPIURL, falling back to the scheme and
+// host the request arrived on.
+func (s *Server) absoluteURL(r *http.Request, path string) string {
+	if base := strings.TrimSuffix(s.cfg.PublicAPIURL, "/"); base != "" {
+		return base + path
+	}
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
@@ -1,11 +1,40 @@
 package http
 
 import (
+	"math"
 	"net"
 	"sync"
 	"time"
 )
 
+// requestLimiter decides whether a client identified by key may make a request.
+type requestLimiter interface {
+	Allow(key string, now time.Time) bool
+}
+
+const (
+	rateLimitTokenBucket   = "token_bucket"
+	rateLimitSlidingWindow = "sliding_window"
+)
+
+// newRequestLimiter builds the limiter selected by algorithm, allowing rate
+// requests per second on average. It returns nil (no limiting) when rate is not
+// positive.
+//
+// The token bucket refills continuously and tolerates bursts of up to twice the
+// per-second rate (at least five requests), which suits interactive clients. The
+// sliding window enforces a hard cap of rate*window requests in any window, at the
+// cost of rejecting bursts that a bucket would absorb.
+func newRequestLimiter(algorithm string, rate float64, window time.Duration) requestLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if algorithm == rateLimitSlidingWindow {
+		return newSlidingWindowLimiter(int(math.Ceil(rate*window.Seconds())), window)
+	}
+	return newRateLimiter(rate)
+}
+
 type rateLimiter struct {
 	mu       sync.Mutex
 	buckets  map[string]*tokenBucket
@@ -68,6 +97,65 @@ func (l *rateLimiter) Allow(key string, now time.Time) bool {
 	return true
 }
 
+// slidingWindowLimiter allows at most limit requests per key in any window. It
+// approximates a true sliding log by weighting the previous fixed window's count
+// by how much of it still overlaps the sliding window.
+type slidingWindowLimiter struct {
+	mu      sync.Mutex
+	windows map[string]*windowCounter
+	limit   int
+	window  time.Duration
+}
+
+type windowCounter struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+func newSlidingWindowLimiter(limit int, window time.Duration) *slidingWindowLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &slidingWindowLimiter{
+		windows: make(map[string]*windowCounter),
+		limit:   limit,
+		window:  window,
+	}
+}
+
+func (l *slidingWindowLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := now.Truncate(l.window)
+	counter, ok := l.windows[key]
+	if !ok {
+		counter = &windowCounter{start: start}
+		l.windows[key] = counter
+	}
+	switch elapsed := start.Sub(counter.start); {
+	case elapsed == l.window:
+		counter.previous, counter.current = counter.current, 0
+		counter.start = start
+	case elapsed > l.window:
+		counter.previous, counter.current = 0, 0
+		counter.start = start
+	}
+
+	overlap := 1 - float64(now.Sub(start))/float64(l.window)
+	estimate := float64(counter.previous)*overlap + float64(counter.current)
+	if estimate+1 > float64(l.limit) {
+		return false
+	}
+
+	counter.current++
+	return true
+}
+
 func clientIPAddress(remoteAddr string) string {
 	host, _, err := net.SplitHostPort(remoteAddr)
 	if err != nil {
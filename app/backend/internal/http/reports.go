@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+	"vault/internal/files"
+)
+
+// maxReportBodyBytes caps the JSON body accepted by the report endpoint.
+const maxReportBodyBytes = 8 << 10
+
+type fileReportRequest struct {
+	Reason string  `json:"reason"`
+	Note   *string `json:"note"`
+}
+
+// handleFileReport lets anyone, signed in or not, report a publicly shared file.
+// Submissions are rate limited per IP.
+func (s *Server) handleFileReport(w http.ResponseWriter, r *http.Request) {
+	ip := clientIPAddress(r.RemoteAddr)
+	if !s.reportLimiter.Allow("ip:"+ip, time.Now()) {
+		s.writeError(w, http.StatusTooManyRequests, errors.New("too many reports, try again later"))
+		return
+	}
+
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileID"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file id"))
+		return
+	}
+
+	var req fileReportRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReportBodyBytes)).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid report body"))
+		return
+	}
+
+	var reporterID *uuid.UUID
+	if session, err := s.sessionFromRequest(r); err == nil && session != nil {
+		if id, err := uuid.Parse(session.UserID); err == nil {
+			reporterID = &id
+		}
+	}
+
+	report, err := s.fileSvc.ReportPublicFile(r.Context(), fileID, reporterID, ip, req.Reason, req.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, files.ErrInvalidReport):
+			s.writeError(w, http.StatusBadRequest, err)
+		case errors.Is(err, files.ErrNotFound):
+			s.writeError(w, http.StatusNotFound, errors.New("public file not found"))
+		default:
+			s.writeError(w, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	s.writeJSON(w, http.StatusCreated, map[string]string{"id": report.ID.String(), "status": report.Status})
+}
+
+// handleAdminFileDownload lets an admin fetch any file, e.g. to review a report.
+// The role is read from the database so demotions apply immediately. Every
+// fetch is written to the audit log, and to the file's access log; it does not
+// count as a download.
+func (s *Server) handleAdminFileDownload(w http.ResponseWriter, r *http.Request) {
+	session, err := s.sessionFromRequest(r)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, err)
+		return
+	}
+	if session == nil {
+		s.writeError(w, http.StatusUnauthorized, errors.New("unauthenticated"))
+		return
+	}
+
+	adminID, err := uuid.Parse(session.UserID)
+	if err != nil {
+		s.writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid session user"))
+		return
+	}
+	admin, err := s.db.GetUserByID(r.Context(), adminID)
+	if err != nil {
+		log.Printf("admin download: user lookup failed: %v", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("failed to load user"))
+		return
+	}
+	if admin.Role != db.RoleAdmin {
+		s.writeError(w, http.StatusForbidden, errors.New("admin role required"))
+		return
+	}
+
+	fileID, err := uuid.Parse(chi.URLParam(r, "fileID"))
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid file id"))
+		return
+	}
+
+	downloaded, err := s.fileSvc.DownloadFileAsAdmin(r.Context(), fileID, adminID)
+	if err != nil {
+		if errors.Is(err, files.ErrNotFound) {
+			s.writeError(w, http.StatusNotFound, errors.New("file not found"))
+			return
+		}
+		log.Printf("admin download of file %s failed: %v", fileID, err)
+		s.writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.recordAccess(r, downloaded.File.ID, db.AccessKindAdmin, &adminID, nil)
+	s.writeFileResponse(w, r, downloaded, "")
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"mime"
 	"net/http"
 	"net/url"
@@ -14,36 +15,59 @@ import (
 	"strings"
 	"time"
 
-	"github.com/99designs/gqlgen/graphql/handler"
-	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
-	"vault/graph"
+	"vault/internal/apperr"
 	"vault/internal/auth"
 	"vault/internal/config"
 	"vault/internal/db"
 	"vault/internal/files"
+	"vault/internal/mail"
+	"vault/internal/storage"
+	"vault/internal/webhooks"
 )
 
 type Server struct {
-	cfg          config.Config
-	router       chi.Router
-	db           *db.Pool
-	fileSvc      *files.Service
+	cfg      config.Config
+	router   chi.Router
+	db       *db.Pool
+	fileSvc  *files.Service
+	webhooks *webhooks.Dispatcher
+	// mailer is nil when SMTP is not configured.
+	mailer       *mail.Mailer
 	oauth        *auth.GoogleOAuth
 	jwt          *auth.JWTManager
 	stateCookie  string
 	secureCookie bool
-	limiter      *rateLimiter
+	// anonLimiter applies to callers keyed by IP, userLimiter to signed-in users.
+	anonLimiter requestLimiter
+	userLimiter requestLimiter
+	// downloadIPLimiter and downloadTokenLimiter replace the above for public
+	// and share downloads, keyed by IP and share token respectively.
+	downloadIPLimiter    requestLimiter
+	downloadTokenLimiter requestLimiter
+	// downloadSlots bounds concurrent download streams per client.
+	downloadSlots *concurrencyLimiter
+	// uploadSlots bounds concurrent multipart uploads per client.
+	uploadSlots   *concurrencyLimiter
+	reportLimiter *rateLimiter
+	loginThrottle *loginThrottle
+	// sharePasswordThrottle blocks a client from one folder share after too
+	// many wrong passwords, using the sign-in failure limits.
+	sharePasswordThrottle *loginThrottle
+	// persistedQueries maps operation hashes to query text (see LoadPersistedQueries).
+	persistedQueries map[string]string
 }
 
-func NewServer(cfg config.Config, pool *db.Pool, fileSvc *files.Service, oauth *auth.GoogleOAuth, jwtMgr *auth.JWTManager) *Server {
+func NewServer(cfg config.Config, pool *db.Pool, fileSvc *files.Service, dispatcher *webhooks.Dispatcher, oauth *auth.GoogleOAuth, jwtMgr *auth.JWTManager, persistedQueries map[string]string) *Server {
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	router.Use(exposeRequestID)
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
@@ -54,22 +78,36 @@ func NewServer(cfg config.Config, pool *db.Pool, fileSvc *files.Service, oauth *
 	}
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{origin},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", sharePasswordHeader},
+		ExposedHeaders:   []string{requestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	server := &Server{
-		cfg:          cfg,
-		router:       router,
-		db:           pool,
-		fileSvc:      fileSvc,
-		oauth:        oauth,
-		jwt:          jwtMgr,
-		stateCookie:  "vault_oauth_state",
-		secureCookie: strings.HasPrefix(strings.ToLower(cfg.FrontendURL), "https://"),
-		limiter:      newRateLimiter(cfg.RateLimitRPS),
+		cfg:           cfg,
+		router:        router,
+		db:            pool,
+		fileSvc:       fileSvc,
+		webhooks:      dispatcher,
+		mailer:        mail.NewMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom),
+		oauth:         oauth,
+		jwt:           jwtMgr,
+		stateCookie:   "vault_oauth_state",
+		secureCookie:  cfg.SecureCookies(),
+		anonLimiter:   newRequestLimiter(cfg.RateLimitAlgorithm, cfg.RateLimitRPS, cfg.RateLimitWindow),
+		userLimiter:   newRequestLimiter(cfg.RateLimitAlgorithm, cfg.AuthenticatedRateLimitRPS, cfg.RateLimitWindow),
+		downloadSlots: newConcurrencyLimiter(cfg.MaxConcurrentDownloads),
+		uploadSlots:   newConcurrencyLimiter(cfg.MaxConcurrentUploads),
+		reportLimiter: newRateLimiter(cfg.ReportRateLimitRPS),
+		loginThrottle: newLoginThrottle(cfg.LoginFailureLimit, cfg.LoginFailureWindow, cfg.LoginBlockDuration),
+
+		downloadIPLimiter:    newRequestLimiter(cfg.RateLimitAlgorithm, cfg.PublicDownloadRateLimitRPS, cfg.RateLimitWindow),
+		downloadTokenLimiter: newRequestLimiter(cfg.RateLimitAlgorithm, cfg.ShareTokenRateLimitRPS, cfg.RateLimitWindow),
+		persistedQueries:     persistedQueries,
+
+		sharePasswordThrottle: newLoginThrottle(cfg.LoginFailureLimit, cfg.LoginFailureWindow, cfg.LoginBlockDuration),
 	}
 
 	router.Use(server.rateLimitMiddleware())
@@ -78,44 +116,82 @@ func NewServer(cfg config.Config, pool *db.Pool, fileSvc *files.Service, oauth *
 }
 
 func (s *Server) registerRoutes() {
-	s.router.Get("/healthz", s.handleHealth)
-	s.router.Get("/auth/google/start", s.handleGoogleStart)
-	s.router.Get("/auth/google/callback", s.handleGoogleCallback)
+	s.router.Get("/livez", s.handleLive)
+	s.router.Get("/readyz", s.handleReady)
+	s.router.Get("/healthz", s.handleReady)
+	s.router.Handle("/metrics", promhttp.Handler())
+	s.router.With(s.limitFailedLogins).Get("/auth/google/start", s.handleGoogleStart)
+	s.router.With(s.limitFailedLogins).Get("/auth/google/callback", s.handleGoogleCallback)
 	s.router.Get("/debug/cookies", s.handleDebugCookies)
 
+	// Anonymous share and public downloads are additionally subject to hotlink
+	// checks and their own rate limits.
+	publicDownloads := s.router.With(s.publicDownloadLimitMiddleware, s.downloadConcurrencyMiddleware, s.hotlinkMiddleware)
+
 	s.router.Route("/files", func(r chi.Router) {
-		r.Get("/{fileID}/download", s.handleFileDownload)
+		r.With(s.downloadConcurrencyMiddleware).Get("/{fileID}/download", s.handleFileDownload)
+		r.Head("/{fileID}/download", s.handleFileDownload)
 		r.Get("/{fileID}/share", s.handleShareInfo)
+		r.Get("/{fileID}/metadata", s.handleFileMetadata)
+		r.Get("/{fileID}/preview", s.handleFilePreview)
+		r.Get("/blobs/{sha256}", s.handleBlobLookup)
 	})
-	s.router.Get("/shares/{token}/download", s.handleShareDownload)
+	publicDownloads.Get("/shares/{token}/download", s.handleShareDownload)
+	// HEAD variants only read metadata, so they skip the download slots.
+	publicHeads := s.router.With(s.publicDownloadLimitMiddleware, s.hotlinkMiddleware)
+	publicHeads.Head("/shares/{token}/download", s.handleShareDownload)
+	publicHeads.Head("/folder-shares/{token}/files/{fileID}/download", s.handleFolderShareDownload)
+	publicHeads.Head("/public/files/{fileID}/download", s.handlePublicFileDownload)
+	s.router.Get("/shares/{token}/qr", s.handleShareQR)
+	s.router.Get("/shares/{token}/preview", s.handleSharePreview)
+	s.router.Get("/folder-shares/{token}", s.handleFolderShareListing)
+	publicDownloads.Get("/folder-shares/{token}/files/{fileID}/download", s.handleFolderShareDownload)
 
 	// Public download by file ID: resolves associated PUBLIC share and streams content
-	s.router.Get("/public/files/{fileID}/download", s.handlePublicFileDownload)
+	publicDownloads.Get("/public/files/{fileID}/download", s.handlePublicFileDownload)
+	s.router.Post("/public/files/{fileID}/report", s.handleFileReport)
+	s.router.With(s.downloadConcurrencyMiddleware).Get("/admin/files/{fileID}/download", s.handleAdminFileDownload)
 
-	gqlServer := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: graph.NewResolver(s.db, s.fileSvc)}))
-	gqlServer.AddTransport(transport.MultipartForm{
-		MaxUploadSize: s.cfg.MaxUploadBytes,
-		MaxMemory:     s.cfg.MaxUploadBytes,
-	})
+	gqlServer := s.newGraphQLServer()
 
-	s.router.Handle("/graphql", s.withSession(gqlServer))
+	s.router.With(s.uploadConcurrencyMiddleware).Handle("/graphql", s.withSession(gqlServer))
 	s.router.Get("/playground", func(w http.ResponseWriter, r *http.Request) {
 		playground.Handler("GraphQL", "/graphql").ServeHTTP(w, r)
 	})
 }
 
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+// handleLive reports process liveness only; it never touches dependencies.
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady checks the database and blob storage and answers 503 when either
+// is unavailable so the instance is taken out of rotation.
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	status := "ok"
-	if s.db != nil {
-		if err := s.db.Ping(ctx); err != nil {
-			status = "degraded"
-		}
+	checks := map[string]string{"database": "ok", "storage": "ok"}
+	ready := true
+	if s.db == nil {
+		checks["database"] = "not configured"
+		ready = false
+	} else if err := s.db.Ping(ctx); err != nil {
+		log.Printf("readiness: database check failed: %v", err)
+		checks["database"] = "unavailable"
+		ready = false
+	}
+	if err := s.fileSvc.PingStorage(ctx); err != nil {
+		log.Printf("readiness: storage check failed: %v", err)
+		checks["storage"] = "unavailable"
+		ready = false
 	}
 
-	s.writeJSON(w, http.StatusOK, map[string]string{"status": status})
+	status, code := "ok", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, code, map[string]any{"status": status, "checks": checks})
 }
 
 func (s *Server) handleGoogleStart(w http.ResponseWriter, r *http.Request) {
@@ -129,9 +205,10 @@ func (s *Server) handleGoogleStart(w http.ResponseWriter, r *http.Request) {
 		Name:     s.stateCookie,
 		Value:    state,
 		Path:     "/auth/google",
+		Domain:   s.cfg.CookieDomain,
 		HttpOnly: true,
 		Secure:   s.secureCookie,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: s.stateCookieSameSite(),
 		Expires:  time.Now().Add(5 * time.Minute),
 	})
 
@@ -141,7 +218,9 @@ func (s *Server) handleGoogleStart(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	ip := clientIPAddress(r.RemoteAddr)
 	if err := r.ParseForm(); err != nil {
+		s.loginThrottle.fail(ip, time.Now())
 		s.writeError(w, http.StatusBadRequest, fmt.Errorf("parse callback: %w", err))
 		return
 	}
@@ -150,12 +229,14 @@ func (s *Server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 	code := r.FormValue("code")
 
 	if !s.validateState(r, state) {
+		s.loginThrottle.fail(ip, time.Now())
 		s.writeError(w, http.StatusBadRequest, errors.New("invalid oauth state"))
 		return
 	}
 
 	user, err := s.oauth.Exchange(ctx, code)
 	if err != nil {
+		s.loginThrottle.fail(ip, time.Now())
 		s.writeError(w, http.StatusBadGateway, err)
 		return
 	}
@@ -165,6 +246,8 @@ func (s *Server) handleGoogleCallback(w http.ResponseWriter, r *http.Request) {
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
+	s.recordLogin(r, dbUser.ID)
+	s.loginThrottle.reset(ip)
 
 	token, claims, err := s.jwt.Sign(time.Now(), dbUser.ID.String(), dbUser.Email, user.Name, dbUser.Role)
 	if err != nil {
@@ -207,8 +290,33 @@ func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	downloaded, err := s.fileSvc.DownloadOwnedFile(r.Context(), fileID, ownerID)
+	if r.Method == http.MethodHead {
+		stat, err := s.fileSvc.StatOwnedFile(r.Context(), fileID, ownerID)
+		filename := s.filenameOverride(r, true)
+		if errors.Is(err, files.ErrNotFound) {
+			stat, err = s.fileSvc.StatGrantedFile(r.Context(), fileID, ownerID)
+			filename = s.filenameOverride(r, false)
+		}
+		s.writeFileHead(w, r, stat, err, filename)
+		return
+	}
+
+	accessKind := db.AccessKindOwner
+	stat, err := s.fileSvc.StatOwnedFile(r.Context(), fileID, ownerID)
+	if errors.Is(err, files.ErrNotFound) {
+		// Not the owner: fall back to files shared with this user directly.
+		accessKind = db.AccessKindGrant
+		stat, err = s.fileSvc.StatGrantedFile(r.Context(), fileID, ownerID)
+	}
+	var downloaded *files.DownloadedFile
+	if err == nil {
+		if s.writeNotModified(w, r, stat) {
+			return
+		}
+		downloaded, err = s.fileSvc.OpenFile(r.Context(), stat)
+	}
 	if err != nil {
+		countDownload(accessKind, err)
 		if errors.Is(err, files.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, errors.New("file not found"))
 			return
@@ -217,7 +325,12 @@ func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeFileResponse(w, downloaded)
+	s.recordAccess(r, downloaded.File.ID, accessKind, &ownerID, nil)
+	countDownload(accessKind, nil)
+	filename := s.filenameOverride(r, accessKind == db.AccessKindOwner)
+	if s.writeFileResponse(throttleResponse(w, r, s.cfg.OwnerDownloadBytesPerSec), r, downloaded, filename) {
+		s.fileSvc.RecordDownload(downloaded)
+	}
 }
 
 func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
@@ -227,8 +340,22 @@ func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	downloaded, err := s.fileSvc.DownloadSharedFile(r.Context(), token)
+	if r.Method == http.MethodHead {
+		stat, err := s.fileSvc.StatSharedFile(r.Context(), token)
+		s.writeFileHead(w, r, stat, err, s.filenameOverride(r, false))
+		return
+	}
+
+	stat, err := s.fileSvc.StatSharedFile(r.Context(), token)
+	var downloaded *files.DownloadedFile
+	if err == nil {
+		if s.writeNotModified(w, r, stat) {
+			return
+		}
+		downloaded, err = s.fileSvc.OpenFile(r.Context(), stat)
+	}
 	if err != nil {
+		countDownload(db.AccessKindShare, err)
 		if errors.Is(err, files.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, errors.New("share not found"))
 			return
@@ -237,7 +364,13 @@ func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeFileResponse(w, downloaded)
+	s.recordAccess(r, downloaded.File.ID, db.AccessKindShare, nil, &token)
+	s.publishDownload(downloaded.File, db.AccessKindShare)
+	s.notifyShareDownload(r, downloaded.File)
+	countDownload(db.AccessKindShare, nil)
+	if s.writeFileResponse(throttleResponse(w, r, s.cfg.DownloadBytesPerSec), r, downloaded, s.filenameOverride(r, false)) {
+		s.fileSvc.RecordDownload(downloaded)
+	}
 }
 
 // handlePublicFileDownload allows downloading a file by ID if it has a PUBLIC share.
@@ -251,6 +384,7 @@ func (s *Server) handlePublicFileDownload(w http.ResponseWriter, r *http.Request
 
 	share, err := s.db.GetShareByFileID(r.Context(), fileID)
 	if err != nil {
+		countDownload(db.AccessKindPublic, err)
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -259,8 +393,22 @@ func (s *Server) handlePublicFileDownload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	downloaded, err := s.fileSvc.DownloadSharedFile(r.Context(), *share.Token)
+	if r.Method == http.MethodHead {
+		stat, err := s.fileSvc.StatSharedFile(r.Context(), *share.Token)
+		s.writeFileHead(w, r, stat, err, s.filenameOverride(r, false))
+		return
+	}
+
+	stat, err := s.fileSvc.StatSharedFile(r.Context(), *share.Token)
+	var downloaded *files.DownloadedFile
+	if err == nil {
+		if s.writeNotModified(w, r, stat) {
+			return
+		}
+		downloaded, err = s.fileSvc.OpenFile(r.Context(), stat)
+	}
 	if err != nil {
+		countDownload(db.AccessKindPublic, err)
 		if errors.Is(err, files.ErrNotFound) {
 			s.writeError(w, http.StatusNotFound, errors.New("file not found"))
 			return
@@ -269,7 +417,39 @@ func (s *Server) handlePublicFileDownload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	s.writeFileResponse(w, downloaded)
+	s.recordAccess(r, downloaded.File.ID, db.AccessKindPublic, nil, share.Token)
+	s.publishDownload(downloaded.File, db.AccessKindPublic)
+	s.notifyShareDownload(r, downloaded.File)
+	countDownload(db.AccessKindPublic, nil)
+	if s.writeFileResponse(throttleResponse(w, r, s.cfg.DownloadBytesPerSec), r, downloaded, s.filenameOverride(r, false)) {
+		s.fileSvc.RecordDownload(downloaded)
+	}
+}
+
+type shareInfo struct {
+	ID         string     `json:"id"`
+	FileID     string     `json:"fileId"`
+	Visibility string     `json:"visibility"`
+	Token      *string    `json:"token"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	// Active is true when the token link currently downloads: the share has a
+	// token and neither the share nor the file has expired.
+	Active bool `json:"active"`
+}
+
+func newShareInfo(share *db.ShareRecord, file db.FileRecord, now time.Time) shareInfo {
+	return shareInfo{
+		ID:         share.ID.String(),
+		FileID:     share.FileID.String(),
+		Visibility: share.Visibility,
+		Token:      share.Token,
+		ExpiresAt:  share.ExpiresAt,
+		Active:     share.Active(now) && !file.Expired(now),
+	}
+}
+
+type shareInfoResponse struct {
+	Share shareInfo `json:"share"`
 }
 
 // handleShareInfo returns share details (visibility, token, expiresAt) for an owned file.
@@ -298,13 +478,19 @@ func (s *Server) handleShareInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	fileWithBlob, err := s.db.GetFileWithBlob(r.Context(), fileID, ownerID)
-	if err != nil || fileWithBlob == nil {
+	if err != nil {
+		log.Printf("share info: file lookup failed: %v", err)
+		s.writeError(w, http.StatusInternalServerError, errors.New("failed to load file"))
+		return
+	}
+	if fileWithBlob == nil {
 		s.writeError(w, http.StatusNotFound, errors.New("file not found"))
 		return
 	}
 
 	share, err := s.db.GetShareByFileID(r.Context(), fileID)
 	if err != nil {
+		log.Printf("share info: share lookup failed: %v", err)
 		s.writeError(w, http.StatusInternalServerError, err)
 		return
 	}
@@ -313,40 +499,128 @@ func (s *Server) handleShareInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resp := map[string]any{
-		"share": map[string]any{
-			"id":         share.ID.String(),
-			"visibility": share.Visibility,
-			"token":      share.Token,
-			"expiresAt":  share.ExpiresAt,
-		},
+	s.writeJSON(w, http.StatusOK, shareInfoResponse{Share: newShareInfo(share, fileWithBlob.File, time.Now())})
+}
+
+// writeNotModified answers 304 when the client's cached copy of the stated file
+// is current, reporting whether it did. Download handlers call it before
+// opening storage, so a revalidation is neither fetched nor logged, published
+// or counted as a download.
+func (s *Server) writeNotModified(w http.ResponseWriter, r *http.Request, stat *files.DownloadedFile) bool {
+	if !notModified(r, stat.Blob) {
+		return false
 	}
-	s.writeJSON(w, http.StatusOK, resp)
+	setValidators(w, stat.Blob)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNotModified)
+	return true
 }
 
-func (s *Server) writeFileResponse(w http.ResponseWriter, payload *files.DownloadedFile) {
-	if payload == nil {
+// writeFileResponse sends the file content, or 304 when the client's cached copy
+// is current. A non-empty filename replaces the stored name in
+// Content-Disposition (see filenameOverride). It reports whether the whole body
+// was delivered.
+func (s *Server) writeFileResponse(w http.ResponseWriter, r *http.Request, payload *files.DownloadedFile, filename string) bool {
+	if payload == nil || payload.Body == nil {
 		s.writeError(w, http.StatusInternalServerError, errors.New("missing file payload"))
-		return
+		return false
 	}
+	defer payload.Body.Close()
 
 	contentType := payload.ContentType
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
-	filename := payload.File.FilenameOriginal
 	if filename == "" {
-		filename = payload.File.ID.String()
+		filename = storedFilename(payload.File)
+	}
+
+	setValidators(w, payload.Blob)
+	w.Header().Set("Cache-Control", "no-store")
+	if notModified(r, payload.Blob) {
+		w.WriteHeader(http.StatusNotModified)
+		return false
 	}
 
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Length", strconv.Itoa(len(payload.Data)))
+	w.Header().Set("Content-Length", strconv.FormatInt(payload.Blob.SizeBytes, 10))
 	w.Header().Set("Content-Disposition", buildContentDisposition(filename))
+
+	w.WriteHeader(http.StatusOK)
+	if err := writeBody(w, r, payload.Body); err != nil {
+		log.Printf("download of file %s aborted: %v", payload.File.ID, err)
+		return false
+	}
+	return true
+}
+
+// writeFileHead answers a HEAD request with the headers the matching GET would
+// send, taking the length from blob metadata instead of the content.
+func (s *Server) writeFileHead(w http.ResponseWriter, r *http.Request, payload *files.DownloadedFile, err error, filename string) {
+	if err != nil {
+		switch {
+		case errors.Is(err, files.ErrNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, files.ErrSharePasswordRequired):
+			w.WriteHeader(http.StatusUnauthorized)
+		case errors.Is(err, files.ErrSharePasswordInvalid):
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			log.Printf("head download failed: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if s.writeNotModified(w, r, payload) {
+		return
+	}
+
+	if filename == "" {
+		filename = storedFilename(payload.File)
+	}
+	setValidators(w, payload.Blob)
 	w.Header().Set("Cache-Control", "no-store")
 
+	w.Header().Set("Content-Type", payload.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(payload.Blob.SizeBytes, 10))
+	w.Header().Set("Content-Disposition", buildContentDisposition(filename))
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(payload.Data)
+}
+
+func storedFilename(file db.FileRecord) string {
+	if file.FilenameOriginal == "" {
+		return file.ID.String()
+	}
+	return file.FilenameOriginal
+}
+
+// maxFilenameOverrideRunes bounds the ?filename= download override.
+const maxFilenameOverrideRunes = 255
+
+// filenameOverride returns the sanitized ?filename= value to send instead of the
+// stored filename, or "" when none is given. Only owners may rename downloads,
+// unless ALLOW_SHARE_FILENAME_OVERRIDE extends it to share and grant downloads;
+// otherwise a link could present someone else's file under a misleading name.
+func (s *Server) filenameOverride(r *http.Request, owner bool) string {
+	if !owner && !s.cfg.AllowShareFilenameOverride {
+		return ""
+	}
+	requested := strings.TrimSpace(r.URL.Query().Get("filename"))
+	if requested == "" {
+		return ""
+	}
+	name := sanitizeFilename(requested)
+	if runes := []rune(name); len(runes) > maxFilenameOverrideRunes {
+		name = string(runes[:maxFilenameOverrideRunes])
+	}
+	return name
+}
+
+// blobETag is a strong validator: the blob hash identifies the exact content.
+func blobETag(blob db.FileBlob) string {
+	return `"` + blob.Sha256 + `"`
 }
 
 func buildContentDisposition(filename string) string {
@@ -374,26 +648,30 @@ func sanitizeFilename(name string) string {
 }
 
 func (s *Server) rateLimitMiddleware() func(http.Handler) http.Handler {
-	if s.limiter == nil {
+	if s.anonLimiter == nil && s.userLimiter == nil {
 		return func(next http.Handler) http.Handler { return next }
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodOptions {
+			if r.Method == http.MethodOptions || isPublicDownloadPath(r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			key := ""
-			if session, err := s.sessionFromRequest(r); err == nil && session != nil && session.UserID != "" {
-				key = "user:" + session.UserID
-			} else {
-				key = "ip:" + clientIPAddress(r.RemoteAddr)
+			session, _ := s.sessionFromRequest(r)
+			if s.cfg.RateLimitExemptAdmins && session != nil && session.Role == "ADMIN" {
+				next.ServeHTTP(w, r)
+				return
 			}
 
-			if !s.limiter.Allow(key, time.Now()) {
-				s.writeError(w, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+			key := sessionClientKey(r, session)
+			limiter := s.anonLimiter
+			if strings.HasPrefix(key, "user:") {
+				limiter = s.userLimiter
+			}
+			if limiter != nil && !limiter.Allow(key, time.Now()) {
+				s.writeError(w, http.StatusTooManyRequests, apperr.ErrRateLimited)
 				return
 			}
 
@@ -402,6 +680,21 @@ func (s *Server) rateLimitMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// clientKey identifies the caller for per-client limits: the user ID for
+// authenticated sessions, otherwise the remote IP.
+func (s *Server) clientKey(r *http.Request) string {
+	session, _ := s.sessionFromRequest(r)
+	return sessionClientKey(r, session)
+}
+
+// sessionClientKey is clientKey for a session that has already been parsed.
+func sessionClientKey(r *http.Request, session *auth.Session) string {
+	if session != nil && session.UserID != "" {
+		return "user:" + session.UserID
+	}
+	return "ip:" + clientIPAddress(r.RemoteAddr)
+}
+
 func (s *Server) withSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		session, err := s.sessionFromRequest(r)
@@ -455,9 +748,10 @@ func (s *Server) clearStateCookie(w http.ResponseWriter) {
 		Name:     s.stateCookie,
 		Value:    "",
 		Path:     "/auth/google",
+		Domain:   s.cfg.CookieDomain,
 		HttpOnly: true,
 		Secure:   s.secureCookie,
-		SameSite: http.SameSiteLaxMode,
+		SameSite: s.stateCookieSameSite(),
 		MaxAge:   -1,
 	})
 }
@@ -474,7 +768,18 @@ func (s *Server) writeError(w http.ResponseWriter, code int, err error) {
 	if err == nil {
 		err = errors.New("unknown error")
 	}
-	s.writeJSON(w, code, map[string]string{"error": err.Error()})
+	if code == http.StatusInternalServerError && errors.Is(err, db.ErrQueryTimeout) {
+		code = http.StatusGatewayTimeout
+	}
+	if code == http.StatusInternalServerError && errors.Is(err, storage.ErrUnavailable) {
+		code = http.StatusBadGateway
+		w.Header().Set("Retry-After", storageRetryAfter)
+	}
+	s.writeJSON(w, code, errorResponse{
+		Error:     err.Error(),
+		Code:      errorCode(code, err),
+		RequestID: w.Header().Get(requestIDHeader),
+	})
 }
 
 func (s *Server) writeJSON(w http.ResponseWriter, code int, payload any) {
@@ -525,21 +830,46 @@ func (s *Server) handleDebugCookies(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, http.StatusOK, payload)
 }
 
-// setSessionCookie writes the session cookie with attributes suitable for cross-site usage.
-// If the frontend is HTTPS (s.secureCookie), we set SameSite=None; Secure and add the Partitioned attribute (CHIPS)
-// to improve compatibility when third-party cookies are restricted by the browser.
-func (s *Server) setSessionCookie(w http.ResponseWriter, name, value string, expires time.Time) {
-	// Default to Lax for same-site local development
-	sameSite := http.SameSiteLaxMode
+// sessionSameSite returns the configured COOKIE_SAMESITE, defaulting to None for
+// https frontends (cross-site deployments) and Lax otherwise.
+func (s *Server) sessionSameSite() http.SameSite {
+	switch s.cfg.CookieSameSite {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	}
 	if s.secureCookie {
-		sameSite = http.SameSiteNoneMode
+		return http.SameSiteNoneMode
 	}
+	return http.SameSiteLaxMode
+}
+
+// stateCookieSameSite follows the session setting except that Strict is relaxed
+// to Lax: the OAuth callback is a cross-site redirect from Google, which would
+// never carry a Strict cookie.
+func (s *Server) stateCookieSameSite() http.SameSite {
+	if s.cfg.CookieSameSite == "none" {
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// setSessionCookie writes the session cookie using COOKIE_DOMAIN and the SameSite
+// mode from sessionSameSite. Cross-site (SameSite=None) cookies over HTTPS also get
+// the Partitioned attribute (CHIPS) so they keep working when third-party cookies
+// are restricted.
+func (s *Server) setSessionCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	sameSite := s.sessionSameSite()
 
 	// Attempt to use net/http Cookie first
 	base := &http.Cookie{
 		Name:     name,
 		Value:    value,
 		Path:     "/",
+		Domain:   s.cfg.CookieDomain,
 		HttpOnly: true,
 		Secure:   s.secureCookie,
 		SameSite: sameSite,
@@ -549,14 +879,17 @@ func (s *Server) setSessionCookie(w http.ResponseWriter, name, value string, exp
 	// Write base cookie
 	http.SetCookie(w, base)
 
-	// Add Partitioned attribute for CHIPS, if serving over HTTPS.
+	// Add Partitioned attribute for CHIPS, if serving cross-site over HTTPS.
 	// Older Go versions don't have Cookie.Partitioned; appending a second header with the attribute works.
-	if s.secureCookie {
+	if s.secureCookie && sameSite == http.SameSiteNoneMode {
 		// Rebuild cookie string to append "; Partitioned" once.
 		// Format time as per RFC1123 with GMT timezone.
 		expiresStr := expires.UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
-		// Note: Do not set Domain so the cookie is host-only for the backend host.
-		cookieStr := fmt.Sprintf("%s=%s; Path=/; Expires=%s; HttpOnly; Secure; SameSite=None; Partitioned", name, value, expiresStr)
+		domain := ""
+		if s.cfg.CookieDomain != "" {
+			domain = "; Domain=" + s.cfg.CookieDomain
+		}
+		cookieStr := fmt.Sprintf("%s=%s; Path=/%s; Expires=%s; HttpOnly; Secure; SameSite=None; Partitioned", name, value, domain, expiresStr)
 		w.Header().Add("Set-Cookie", cookieStr)
 	}
 }
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"vault/internal/auth"
+	"vault/internal/config"
+	"vault/internal/db"
+	"vault/internal/db/dbtest"
+	"vault/internal/files"
+	"vault/internal/storage/storagetest"
+)
+
+func TestDeletedFileSharesAreNotFound(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	store := storagetest.NewServer(t)
+	svc := files.NewService(pool, store.Storage(), files.Options{})
+	jwtMgr := auth.NewJWTManager("test-secret", time.Hour)
+	s := NewServer(config.Config{}, pool, svc, nil, nil, jwtMgr, nil)
+	ctx := context.Background()
+
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+	file := dbtest.InsertFile(t, pool, owner.ID, "report.txt", "quarterly numbers")
+	share, err := svc.ShareFile(ctx, file.File.ID, owner.ID, "PUBLIC", nil, false)
+	if err != nil {
+		t.Fatalf("ShareFile: %v", err)
+	}
+	bearer, _, err := jwtMgr.Sign(time.Now(), owner.ID.String(), owner.Email, "", owner.Role)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	routes := []struct {
+		name   string
+		path   string
+		method string
+	}{
+		{"share info", "/files/" + file.File.ID.String() + "/share", http.MethodGet},
+		{"token download", "/shares/" + *share.Token + "/download", http.MethodHead},
+		{"public download", "/public/files/" + file.File.ID.String() + "/download", http.MethodHead},
+	}
+	serve := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// HEAD proves the links work without recording a download.
+	for _, route := range routes {
+		if code := serve(route.method, route.path); code != http.StatusOK {
+			t.Fatalf("%s before delete: status %d, want 200", route.name, code)
+		}
+	}
+
+	if _, err := svc.DeleteFile(ctx, file.File.ID, owner.ID); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+
+	for _, route := range routes {
+		methods := []string{http.MethodGet}
+		if route.method != http.MethodGet {
+			methods = append(methods, route.method)
+		}
+		for _, method := range methods {
+			if code := serve(method, route.path); code != http.StatusNotFound {
+				t.Errorf("%s %s after delete: status %d, want 404", route.name, method, code)
+			}
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 600_000_000, time.UTC)
+	blob := db.FileBlob{Sha256: "abc123", CreatedAt: created}
+	atCreation := created.Format(http.TimeFormat)
+	secondBefore := created.Add(-time.Second).Format(http.TimeFormat)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		ifModified  string
+		want        bool
+	}{
+		{name: "no validators", want: false},
+		{name: "matching etag", ifNoneMatch: `"abc123"`, want: true},
+		{name: "weak etag", ifNoneMatch: `W/"abc123"`, want: true},
+		{name: "etag in list", ifNoneMatch: `"other", "abc123"`, want: true},
+		{name: "wildcard", ifNoneMatch: "*", want: true},
+		{name: "other etag", ifNoneMatch: `"other"`, want: false},
+		{name: "etag wins over date", ifNoneMatch: `"other"`, ifModified: atCreation, want: false},
+		{name: "matching etag ignores stale date", ifNoneMatch: `"abc123"`, ifModified: secondBefore, want: true},
+		// Created at .6s past the second the HTTP date names.
+		{name: "date within the same second", ifModified: atCreation, want: true},
+		{name: "date a second early", ifModified: secondBefore, want: false},
+		{name: "malformed date", ifModified: "yesterday", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if tt.ifModified != "" {
+				r.Header.Set("If-Modified-Since", tt.ifModified)
+			}
+			if got := notModified(r, blob); got != tt.want {
+				t.Errorf("notModified = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevalidationSkipsStorage(t *testing.T) {
+	pool := dbtest.NewPool(t)
+	store := storagetest.NewServer(t)
+	svc := files.NewService(pool, store.Storage(), files.Options{})
+	jwtMgr := auth.NewJWTManager("test-secret", time.Hour)
+	s := NewServer(config.Config{}, pool, svc, nil, nil, jwtMgr, nil)
+	ctx := context.Background()
+
+	owner := dbtest.CreateUser(t, pool, "owner@example.com")
+	file := dbtest.InsertFile(t, pool, owner.ID, "report.txt", "quarterly numbers")
+	store.Put(file.Blob.StorageKey, []byte("quarterly numbers"), "text/plain")
+	share, err := svc.ShareFile(ctx, file.File.ID, owner.ID, "PUBLIC", nil, false)
+	if err != nil {
+		t.Fatalf("ShareFile: %v", err)
+	}
+	bearer, _, err := jwtMgr.Sign(time.Now(), owner.ID.String(), owner.Email, "", owner.Role)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	get := func(path, etag string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		req.Header.Set("If-None-Match", etag)
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	etag := blobETag(file.Blob)
+	for _, path := range []string{
+		"/files/" + file.File.ID.String() + "/download",
+		"/shares/" + *share.Token + "/download",
+		"/public/files/" + file.File.ID.String() + "/download",
+	} {
+		if code := get(path, etag); code != http.StatusNotModified {
+			t.Errorf("GET %s: status %d, want 304", path, code)
+		}
+	}
+	if n := store.Downloads(); n != 0 {
+		t.Fatalf("revalidations made %d storage requests, want 0", n)
+	}
+
+	if code := get("/files/"+file.File.ID.String()+"/download", `"stale"`); code != http.StatusOK {
+		t.Fatalf("GET with stale etag: status %d, want 200", code)
+	}
+	if n := store.Downloads(); n != 1 {
+		t.Fatalf("download made %d storage requests, want 1", n)
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vault/internal/db"
+)
+
+const shareNotifyTimeout = 30 * time.Second
+
+// notifyShareDownload emails the owner about a download through the file's
+// share when the share opted in. It runs in the background; the per-share
+// interval is claimed in the database before sending, so a failed send still
+// counts against it rather than retrying on every download.
+func (s *Server) notifyShareDownload(r *http.Request, file db.FileRecord) {
+	if s.mailer == nil || s.db == nil {
+		return
+	}
+
+	downloadedAt := time.Now().UTC()
+	ip := ""
+	if s.cfg.ShareNotifyIncludeIP {
+		ip = clientIPAddress(r.RemoteAddr)
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shareNotifyTimeout)
+		defer cancel()
+		notification, err := s.db.ClaimShareDownloadNotification(ctx, file.ID, s.cfg.ShareNotifyInterval)
+		if err != nil {
+			log.Printf("share download notification failed: %v", err)
+			return
+		}
+		if notification == nil {
+			return
+		}
+
+		subject := fmt.Sprintf("Your shared file %q was downloaded", notification.Filename)
+		var body strings.Builder
+		fmt.Fprintf(&body, "Your shared file %q was downloaded.\n\n", notification.Filename)
+		fmt.Fprintf(&body, "Time: %s\n", downloadedAt.Format(time.RFC1123))
+		if ip != "" {
+			fmt.Fprintf(&body, "IP address: %s\n", ip)
+		}
+		if s.cfg.ShareNotifyInterval > 0 {
+			fmt.Fprintf(&body, "\nFurther downloads within %s are not reported.\n", s.cfg.ShareNotifyInterval)
+		}
+		if err := s.mailer.Send(notification.OwnerEmail, subject, body.String()); err != nil {
+			log.Printf("share download notification failed: %v", err)
+		}
+	}()
+}
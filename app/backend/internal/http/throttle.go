@@ -0,0 +1,97 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// throttleInterval is how often a throttled writer releases a slice of its budget.
+const throttleInterval = 100 * time.Millisecond
+
+// throttledWriter paces body writes to at most bytesPerSec, giving up early when
+// the request context is canceled.
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	chunk int
+}
+
+// bodyWriteChunk is how much of a download body is copied between checks for a
+// canceled request.
+const bodyWriteChunk = 64 << 10
+
+// writeBody copies body to w in slices, stopping as soon as the request context
+// is canceled so a disconnected client releases the storage transfer and the
+// handler straight away.
+func writeBody(w http.ResponseWriter, r *http.Request, body io.Reader) error {
+	ctx := r.Context()
+	buf := make([]byte, bodyWriteChunk)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// throttleResponse wraps w so the body is written at no more than bytesPerSec.
+// A non-positive rate returns w unchanged.
+func throttleResponse(w http.ResponseWriter, r *http.Request, bytesPerSec int64) http.ResponseWriter {
+	if bytesPerSec <= 0 {
+		return w
+	}
+	chunk := int(bytesPerSec * int64(throttleInterval) / int64(time.Second))
+	if chunk < 1 {
+		chunk = 1
+	}
+	return &throttledWriter{ResponseWriter: w, ctx: r.Context(), chunk: chunk}
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := t.chunk
+		if n > len(p) {
+			n = len(p)
+		}
+		start := time.Now()
+		m, err := t.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		if len(p) == 0 {
+			break
+		}
+
+		wait := throttleInterval - time.Since(start)
+		if wait <= 0 {
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-t.ctx.Done():
+			timer.Stop()
+			return written, t.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return written, nil
+}
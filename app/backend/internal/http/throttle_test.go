@@ -0,0 +1,73 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+// cancelAfterRead cancels the request context once the first read is served,
+// as if the client disconnected mid-download.
+type cancelAfterRead struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterRead) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.cancel()
+	return n, err
+}
+
+func TestWriteBodyCopiesWholeBody(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3*bodyWriteChunk+7)
+	rec := httptest.NewRecorder()
+
+	if err := writeBody(rec, httptest.NewRequest("GET", "/", nil), bytes.NewReader(data)); err != nil {
+		t.Fatalf("writeBody: %v", err)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Fatalf("wrote %d bytes, want %d", rec.Body.Len(), len(data))
+	}
+}
+
+func TestWriteBodyStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	body := bytes.NewReader(bytes.Repeat([]byte("x"), 2*bodyWriteChunk))
+
+	err := writeBody(rec, req, body)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("wrote %d bytes after cancellation", rec.Body.Len())
+	}
+	if body.Len() != 2*bodyWriteChunk {
+		t.Fatalf("read %d bytes of the body after cancellation", 2*bodyWriteChunk-body.Len())
+	}
+}
+
+func TestWriteBodyStopsWhenClientGoesAway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	source := bytes.NewReader(bytes.Repeat([]byte("x"), 4*bodyWriteChunk))
+
+	err := writeBody(rec, req, cancelAfterRead{Reader: source, cancel: cancel})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if rec.Body.Len() != bodyWriteChunk {
+		t.Fatalf("wrote %d bytes, want one slice of %d", rec.Body.Len(), bodyWriteChunk)
+	}
+	if source.Len() != 3*bodyWriteChunk {
+		t.Fatalf("kept reading after cancellation: %d bytes left, want %d", source.Len(), 3*bodyWriteChunk)
+	}
+}
@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"vault/internal/apperr"
+)
+
+// uploadTransport wraps gqlgen's multipart transport so requests over
+// MaxUploadSize get a 413 with a FILE_TOO_LARGE error carrying the limit,
+// instead of gqlgen's untyped "failed to parse" and "failed to read" messages.
+type uploadTransport struct {
+	transport.MultipartForm
+}
+
+var _ graphql.Transport = uploadTransport{}
+
+func (t uploadTransport) Do(w http.ResponseWriter, r *http.Request, exec graphql.GraphExecutor) {
+	limit := t.MaxUploadSize
+	if r.ContentLength > limit {
+		writeUploadTooLarge(w, limit)
+		return
+	}
+
+	// Bodies without a Content-Length only show their size while gqlgen reads
+	// them; its error response is then replaced with ours.
+	body := &sizeWatchReader{r: r.Body, limit: limit}
+	r.Body = body
+	guarded := &tooLargeWriter{ResponseWriter: w, body: body}
+	t.MultipartForm.Do(guarded, r, exec)
+	if body.exceeded && !guarded.wrote {
+		writeUploadTooLarge(w, limit)
+	}
+}
+
+func writeUploadTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	_ = json.NewEncoder(w).Encode(&graphql.Response{Errors: gqlerror.List{{
+		Message: fmt.Sprintf("upload exceeds the maximum size of %d bytes", limit),
+		Extensions: map[string]any{
+			"code":  apperr.CodeFileTooLarge,
+			"limit": limit,
+		},
+	}}})
+}
+
+// sizeWatchReader notes when more than limit bytes have been read from the body.
+type sizeWatchReader struct {
+	r        io.ReadCloser
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (s *sizeWatchReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.read += int64(n)
+	if s.read > s.limit {
+		s.exceeded = true
+	}
+	return n, err
+}
+
+func (s *sizeWatchReader) Close() error { return s.r.Close() }
+
+// tooLargeWriter discards gqlgen's response once the body went over the limit.
+type tooLargeWriter struct {
+	http.ResponseWriter
+	body  *sizeWatchReader
+	wrote bool
+}
+
+func (w *tooLargeWriter) WriteHeader(status int) {
+	if w.body.exceeded {
+		return
+	}
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tooLargeWriter) Write(p []byte) (int, error) {
+	if w.body.exceeded {
+		return len(p), nil
+	}
+	w.wrote = true
+	return w.ResponseWriter.Write(p)
+}
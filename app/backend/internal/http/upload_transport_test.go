@@ -0,0 +1,116 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"vault/internal/apperr"
+	"vault/internal/config"
+)
+
+// uploadRequestBody builds a GraphQL multipart request uploading content.
+func uploadRequestBody(t *testing.T, content []byte) ([]byte, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fields := map[string]string{
+		"operations": `{"query":"mutation ($files: [Upload!]!) { uploadFiles(files: $files) { files { id } } }","variables":{"files":[null]}}`,
+		"map":        `{"0":["variables.files.0"]}`,
+	}
+	for _, name := range []string{"operations", "map"} {
+		if err := mw.WriteField(name, fields[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	part, err := mw.CreateFormFile("0", "upload.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), mw.FormDataContentType()
+}
+
+// postUpload sends body to a GraphQL server whose multipart limit is limit.
+// A negative contentLength sends the body without a Content-Length.
+func postUpload(t *testing.T, body []byte, contentType string, limit int64, contentLength int64) *httptest.ResponseRecorder {
+	t.Helper()
+	s := &Server{cfg: config.Config{MaxUploadBytes: limit}}
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = contentLength
+	rec := httptest.NewRecorder()
+	s.newGraphQLServer().ServeHTTP(rec, req)
+	return rec
+}
+
+func errorCodes(t *testing.T, rec *httptest.ResponseRecorder) []any {
+	t.Helper()
+	var resp struct {
+		Errors []struct {
+			Extensions map[string]any `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	codes := make([]any, 0, len(resp.Errors))
+	for _, e := range resp.Errors {
+		codes = append(codes, e.Extensions["code"])
+	}
+	return codes
+}
+
+func TestUploadOneByteOverLimitIsFileTooLarge(t *testing.T) {
+	body, contentType := uploadRequestBody(t, bytes.Repeat([]byte("x"), 1024))
+	limit := int64(len(body)) - 1
+
+	for name, contentLength := range map[string]int64{"content length": int64(len(body)), "chunked": -1} {
+		t.Run(name, func(t *testing.T) {
+			rec := postUpload(t, body, contentType, limit, contentLength)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want 413; body %s", rec.Code, rec.Body.String())
+			}
+			var resp struct {
+				Errors []struct {
+					Extensions struct {
+						Code  string `json:"code"`
+						Limit int64  `json:"limit"`
+					} `json:"extensions"`
+				} `json:"errors"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions.Code != apperr.CodeFileTooLarge || resp.Errors[0].Extensions.Limit != limit {
+				t.Fatalf("errors = %+v, want one FILE_TOO_LARGE with limit %d", resp.Errors, limit)
+			}
+		})
+	}
+}
+
+func TestUploadAtLimitPassesTransport(t *testing.T) {
+	body, contentType := uploadRequestBody(t, bytes.Repeat([]byte("x"), 1024))
+	limit := int64(len(body))
+
+	for name, contentLength := range map[string]int64{"content length": int64(len(body)), "chunked": -1} {
+		t.Run(name, func(t *testing.T) {
+			rec := postUpload(t, body, contentType, limit, contentLength)
+			if rec.Code == http.StatusRequestEntityTooLarge {
+				t.Fatalf("upload at the limit rejected: %s", rec.Body.String())
+			}
+			// The request reaches the resolver, which rejects it for lack of
+			// a session.
+			codes := errorCodes(t, rec)
+			if len(codes) != 1 || codes[0] != apperr.CodeUnauthorized {
+				t.Fatalf("error codes = %v, want [%s]", codes, apperr.CodeUnauthorized)
+			}
+		})
+	}
+}
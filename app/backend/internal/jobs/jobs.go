@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Locker coordinates job runs between every instance of the app.
+// WithAdvisoryLock runs fn while holding a lock named after the job, reporting
+// false without running fn when another instance holds it. ClaimJobRun records
+// a run unless the last one, on any instance, is younger than minAge.
+type Locker interface {
+	WithAdvisoryLock(ctx context.Context, name string, fn func(context.Context) error) (bool, error)
+	ClaimJobRun(ctx context.Context, name string, minAge time.Duration) (bool, error)
+}
+
+// Runner schedules periodic jobs. With a Locker, each job runs on one instance
+// per interval: every instance ticks, but a tick only runs the job when no
+// instance has run it within the interval.
+type Runner struct {
+	locker Locker
+}
+
+// A tick may come up to interval/jobIntervalSlack early and still run the job,
+// so the instance that ran last keeps its slot despite ticker drift.
+const jobIntervalSlack = 10
+
+// NewRunner returns a Runner; a nil locker runs every job on every instance.
+func NewRunner(locker Locker) *Runner {
+	return &Runner{locker: locker}
+}
+
+// Every runs fn in a background goroutine once per interval until ctx is done.
+// Errors are logged and do not stop the schedule. A non-positive interval
+// disables the job.
+func (r *Runner) Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	if interval <= 0 {
+		log.Printf("job %s disabled", name)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.run(ctx, name, interval, fn); err != nil && ctx.Err() == nil {
+					log.Printf("job %s failed: %v", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// run executes fn unless another instance is running the job or ran it within
+// the interval. A failed run still counts; the next one is an interval later.
+func (r *Runner) run(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) error {
+	if r.locker == nil {
+		return fn(ctx)
+	}
+	_, err := r.locker.WithAdvisoryLock(ctx, "vault.jobs."+name, func(ctx context.Context) error {
+		due, err := r.locker.ClaimJobRun(ctx, name, interval-interval/jobIntervalSlack)
+		if err != nil || !due {
+			return err
+		}
+		return fn(ctx)
+	})
+	return err
+}
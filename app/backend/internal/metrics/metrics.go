@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcome label values.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	FilesUploaded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_files_uploaded_total",
+		Help: "Files uploaded, by outcome.",
+	}, []string{"outcome"})
+
+	BytesUploaded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "vault_uploaded_bytes_total",
+		Help: "Original bytes of successfully uploaded files.",
+	})
+
+	DedupHits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "vault_dedup_hits_total",
+		Help: "Uploads that reused an existing blob instead of storing new content.",
+	})
+
+	Downloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_downloads_total",
+		Help: "File downloads, by access kind and outcome.",
+	}, []string{"kind", "outcome"})
+
+	SharesCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vault_shares_created_total",
+		Help: "Share links created or updated, by outcome.",
+	}, []string{"outcome"})
+
+	StoredBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "vault_stored_deduped_bytes",
+		Help: "Total size of all distinct blobs, refreshed periodically.",
+	})
+)
+
+// Outcome maps an error to its outcome label.
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
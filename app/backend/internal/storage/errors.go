@@ -0,0 +1,7 @@
+package storage
+
+import "errors"
+
+// ErrUnavailable marks failures of the storage service itself, as opposed to
+// bad requests from this service. Callers may retry them later.
+var ErrUnavailable = errors.New("storage unavailable")
@@ -0,0 +1,106 @@
+// Package storagetest serves an in-memory stand-in for the Supabase Storage API
+// used by storage.SupabaseClient.
+package storagetest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"vault/internal/storage"
+)
+
+// Bucket is the bucket every client of a Server uses.
+const Bucket = "test-bucket"
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+// Server keeps uploaded objects in memory and counts downloads.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	objects   map[string]object
+	downloads int
+}
+
+// NewServer starts a server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{objects: make(map[string]object)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Storage returns a client for the server's bucket.
+func (s *Server) Storage() *storage.SupabaseClient {
+	return storage.NewSupabaseClient(s.URL, Bucket, "test-key")
+}
+
+// Put stores an object as if it had been uploaded.
+func (s *Server) Put(key string, data []byte, contentType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = object{data: data, contentType: contentType}
+}
+
+// Get returns a stored object and whether it exists.
+func (s *Server) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	return obj.data, ok
+}
+
+// Downloads counts the object GETs served so far.
+func (s *Server) Downloads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downloads
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/storage/v1/bucket/"+Bucket {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/storage/v1/object/"+Bucket+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Put(key, data, r.Header.Get("Content-Type"))
+	case http.MethodGet:
+		s.mu.Lock()
+		obj, found := s.objects[key]
+		s.downloads++
+		s.mu.Unlock()
+		if !found {
+			http.Error(w, "object not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, key)
+		s.mu.Unlock()
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
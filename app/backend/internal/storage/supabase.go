@@ -69,7 +69,10 @@ func (c *SupabaseClient) Delete(ctx context.Context, objectPath string) error {
     return nil
 }
 
-func (c *SupabaseClient) Download(ctx context.Context, objectPath string) ([]byte, string, error) {
+// OpenDownload starts fetching the object at objectPath and returns its body
+// for the caller to stream and close, with the content type storage reports.
+// The transfer is bound to ctx: canceling it aborts any pending read.
+func (c *SupabaseClient) OpenDownload(ctx context.Context, objectPath string) (io.ReadCloser, string, error) {
     url := fmt.Sprintf("%s/object/%s/%s", c.baseURL, c.bucket, objectPath)
     req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
     if err != nil {
@@ -79,18 +82,60 @@ func (c *SupabaseClient) Download(ctx context.Context, objectPath string) ([]byt
 
     resp, err := c.httpClient.Do(req)
     if err != nil {
-        return nil, "", err
+        if ctx.Err() != nil {
+            return nil, "", err
+        }
+        return nil, "", fmt.Errorf("%w: supabase download: %v", ErrUnavailable, err)
     }
-    defer resp.Body.Close()
 
     if resp.StatusCode >= http.StatusBadRequest {
+        defer resp.Body.Close()
         data, _ := io.ReadAll(resp.Body)
+        if resp.StatusCode >= http.StatusInternalServerError {
+            return nil, "", fmt.Errorf("%w: supabase download failed: %s", ErrUnavailable, string(data))
+        }
         return nil, "", fmt.Errorf("supabase download failed: %s", string(data))
     }
+    return resp.Body, resp.Header.Get("Content-Type"), nil
+}
 
-    data, err := io.ReadAll(resp.Body)
+// Download reads the whole object at objectPath into memory. Prefer
+// OpenDownload when the content is passed on to a client.
+func (c *SupabaseClient) Download(ctx context.Context, objectPath string) ([]byte, string, error) {
+    body, contentType, err := c.OpenDownload(ctx, objectPath)
     if err != nil {
         return nil, "", err
     }
-    return data, resp.Header.Get("Content-Type"), nil
+    defer body.Close()
+
+    data, err := io.ReadAll(body)
+    if err != nil {
+        if ctx.Err() != nil {
+            return nil, "", err
+        }
+        return nil, "", fmt.Errorf("%w: supabase download: %v", ErrUnavailable, err)
+    }
+    return data, contentType, nil
+}
+
+// Ping checks that storage is reachable and the configured bucket exists.
+func (c *SupabaseClient) Ping(ctx context.Context) error {
+    url := fmt.Sprintf("%s/bucket/%s", c.baseURL, c.bucket)
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return err
+    }
+    req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.serviceKey))
+
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode >= http.StatusBadRequest {
+        data, _ := io.ReadAll(resp.Body)
+        return fmt.Errorf("supabase bucket check failed: %s", string(data))
+    }
+    return nil
 }
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenDownloadReleasesTransferOnCancel(t *testing.T) {
+	released := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first part"))
+		w.(http.Flusher).Flush()
+		// Hold the rest of the body until the client goes away.
+		<-r.Context().Done()
+		close(released)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	body, _, err := NewSupabaseClient(server.URL, "bucket", "key").OpenDownload(ctx, "object")
+	if err != nil {
+		t.Fatalf("OpenDownload: %v", err)
+	}
+	defer body.Close()
+
+	buf := make([]byte, len("first part"))
+	if _, err := io.ReadFull(body, buf); err != nil {
+		t.Fatalf("read first part: %v", err)
+	}
+	cancel()
+	if _, err := body.Read(buf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("read after cancel: err = %v, want context.Canceled", err)
+	}
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Fatal("storage connection still open after cancellation")
+	}
+}
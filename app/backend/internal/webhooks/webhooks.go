@@ -0,0 +1,242 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vault/internal/db"
+)
+
+// Events a webhook can subscribe to.
+const (
+	EventFileUploaded   = "file.uploaded"
+	EventShareCreated   = "share.created"
+	EventFileDownloaded = "file.downloaded"
+)
+
+// Headers sent with every delivery. SignatureHeader carries "sha256=" followed
+// by the hex HMAC-SHA256 of the request body keyed with the webhook secret.
+const (
+	SignatureHeader = "X-Vault-Signature"
+	EventHeader     = "X-Vault-Event"
+	DeliveryHeader  = "X-Vault-Delivery"
+)
+
+const (
+	enqueueTimeout     = 5 * time.Second
+	deliveryBatchSize  = 50
+	deliveryWorkers    = 4
+	firstRetryDelay    = 30 * time.Second
+	maxRetryDelay      = time.Hour
+	maxResponseErrBody = 512
+)
+
+// MinSecretLength is the shortest signing secret accepted for a webhook.
+const MinSecretLength = 16
+
+var ErrInvalidURL = errors.New("webhook url must be an absolute http(s) url")
+
+// ValidEvent reports whether event is one webhooks can subscribe to.
+func ValidEvent(event string) bool {
+	switch event {
+	case EventFileUploaded, EventShareCreated, EventFileDownloaded:
+		return true
+	}
+	return false
+}
+
+// ValidateURL checks that rawURL is an absolute http(s) URL.
+func ValidateURL(rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || !target.IsAbs() || target.Host == "" {
+		return ErrInvalidURL
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// envelope is the JSON body POSTed to webhooks.
+type envelope struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// FileData describes the file of file.uploaded and file.downloaded events.
+type FileData struct {
+	FileID    string `json:"fileId"`
+	Filename  string `json:"filename"`
+	SizeBytes int64  `json:"sizeBytes"`
+	// Via names the link a file.downloaded event came through: share, public or
+	// folder_share.
+	Via string `json:"via,omitempty"`
+}
+
+// FileDataFor builds the event data for file.
+func FileDataFor(file db.FileRecord) FileData {
+	return FileData{FileID: file.ID.String(), Filename: file.FilenameOriginal, SizeBytes: file.SizeBytesOriginal}
+}
+
+// ShareData describes the share of a share.created event.
+type ShareData struct {
+	ShareID    string     `json:"shareId"`
+	FileID     string     `json:"fileId"`
+	Filename   string     `json:"filename"`
+	Visibility string     `json:"visibility"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+// Dispatcher queues events for delivery and delivers them. Publishing only
+// writes to the delivery queue; the HTTP calls happen in DeliverPending, which
+// runs as a background job, so slow endpoints never hold up user requests.
+type Dispatcher struct {
+	repo        *db.Pool
+	client      *http.Client
+	maxAttempts int
+}
+
+// NewDispatcher returns a Dispatcher that sends with client, bounding each
+// attempt by timeout, and gives up on a delivery after maxAttempts attempts.
+func NewDispatcher(repo *db.Pool, client *http.Client, timeout time.Duration, maxAttempts int) *Dispatcher {
+	if client == nil {
+		client = &http.Client{}
+	}
+	client.Timeout = timeout
+	return &Dispatcher{repo: repo, client: client, maxAttempts: max(maxAttempts, 1)}
+}
+
+// Publish queues event for the owner's subscribed webhooks in the background.
+// Failures are logged; a nil Dispatcher publishes nothing.
+func (d *Dispatcher) Publish(ownerID uuid.UUID, event string, data any) {
+	if d == nil || d.repo == nil {
+		return
+	}
+
+	payload, err := json.Marshal(envelope{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("encode webhook event %s failed: %v", event, err)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), enqueueTimeout)
+		defer cancel()
+		if _, err := d.repo.EnqueueWebhookEvent(ctx, ownerID, event, payload); err != nil {
+			log.Printf("enqueue webhook event %s failed: %v", event, err)
+		}
+	}()
+}
+
+// DeliverPending attempts every due delivery once and returns how many
+// succeeded. Failed attempts are rescheduled with exponential backoff until
+// maxAttempts is reached.
+func (d *Dispatcher) DeliverPending(ctx context.Context) (int, error) {
+	due, err := d.repo.DueWebhookDeliveries(ctx, deliveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		delivered atomic.Int64
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, deliveryWorkers)
+	)
+	for _, delivery := range due {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if d.attempt(ctx, delivery) {
+				delivered.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	return int(delivered.Load()), ctx.Err()
+}
+
+// attempt sends one delivery and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery db.DueWebhookDelivery) bool {
+	status, err := d.send(ctx, delivery)
+
+	var outcome db.WebhookAttempt
+	if status != 0 {
+		outcome.ResponseStatus = &status
+	}
+	if err != nil {
+		msg := err.Error()
+		outcome.Error = &msg
+		if attempts := delivery.Attempts + 1; attempts < d.maxAttempts {
+			next := time.Now().Add(retryDelay(attempts))
+			outcome.NextAttemptAt = &next
+		}
+	}
+
+	if recordErr := d.repo.RecordWebhookAttempt(ctx, delivery.ID, outcome); recordErr != nil {
+		log.Printf("record webhook delivery %s failed: %v", delivery.ID, recordErr)
+	}
+	return err == nil
+}
+
+// send POSTs the payload and returns the response status. Any non-2xx status is
+// an error.
+func (d *Dispatcher) send(ctx context.Context, delivery db.DueWebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "vault-webhooks/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseErrBody))
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseErrBody))
+	return resp.StatusCode, nil
+}
+
+// retryDelay doubles from firstRetryDelay after each failed attempt, capped at
+// maxRetryDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := firstRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,11 @@
+create table if not exists folder_shares (
+    id uuid primary key default gen_random_uuid(),
+    folder_id uuid not null unique references folders(id) on delete cascade,
+    owner_id uuid not null references users(id) on delete cascade,
+    token text not null unique,
+    expires_at timestamptz,
+    password_hash text,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists idx_folder_shares_owner on folder_shares(owner_id);
@@ -0,0 +1,15 @@
+create table if not exists share_grants (
+    id uuid primary key default gen_random_uuid(),
+    file_id uuid not null references files(id) on delete cascade,
+    grantee_id uuid references users(id) on delete cascade,
+    grantee_email text not null,
+    permission text not null default 'DOWNLOAD',
+    granted_by uuid not null references users(id) on delete cascade,
+    created_at timestamptz not null default now(),
+    constraint share_grants_permission_check check (permission in ('VIEW', 'DOWNLOAD'))
+);
+
+create unique index if not exists uq_share_grants_file_email
+    on share_grants(file_id, lower(grantee_email));
+create index if not exists idx_share_grants_grantee_email on share_grants(lower(grantee_email));
+create index if not exists idx_share_grants_grantee_id on share_grants(grantee_id);
@@ -0,0 +1,20 @@
+alter table file_blobs
+    add column if not exists chunked boolean not null default false;
+
+create table if not exists chunks (
+    id uuid primary key default gen_random_uuid(),
+    sha256 text not null unique,
+    size_bytes bigint not null,
+    storage_key text not null,
+    ref_count integer not null default 1,
+    created_at timestamptz not null default now()
+);
+
+create table if not exists blob_chunks (
+    blob_id uuid not null references file_blobs(id) on delete cascade,
+    seq integer not null,
+    chunk_id uuid not null references chunks(id),
+    primary key (blob_id, seq)
+);
+
+create index if not exists idx_blob_chunks_chunk on blob_chunks(chunk_id);
@@ -0,0 +1,3 @@
+alter table file_blobs
+    add column if not exists encoding text not null default 'identity',
+    add column if not exists stored_size_bytes bigint;
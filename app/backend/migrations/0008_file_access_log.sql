@@ -0,0 +1,11 @@
+create table if not exists file_access_log (
+    id uuid primary key default gen_random_uuid(),
+    file_id uuid not null references files(id) on delete cascade,
+    access_kind text not null,
+    share_token text,
+    ip text,
+    user_agent text,
+    accessed_at timestamptz not null default now()
+);
+
+create index if not exists idx_file_access_log_file_at on file_access_log(file_id, accessed_at desc);
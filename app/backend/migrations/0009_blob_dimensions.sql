@@ -0,0 +1,3 @@
+alter table file_blobs
+    add column if not exists width integer,
+    add column if not exists height integer;
@@ -0,0 +1,9 @@
+create table if not exists saved_searches (
+    id uuid primary key default gen_random_uuid(),
+    owner_id uuid not null references users(id) on delete cascade,
+    name text not null,
+    filter jsonb not null default '{}'::jsonb,
+    created_at timestamptz not null default now()
+);
+
+create unique index if not exists uq_saved_searches_owner_name on saved_searches(owner_id, lower(name));
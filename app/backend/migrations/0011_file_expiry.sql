@@ -0,0 +1,5 @@
+alter table files
+    add column if not exists expires_at timestamptz;
+
+create index if not exists idx_files_expires_at on files(expires_at)
+    where expires_at is not null and is_deleted = false;
@@ -0,0 +1,17 @@
+create table if not exists file_reports (
+    id uuid primary key default gen_random_uuid(),
+    file_id uuid not null references files(id) on delete cascade,
+    reporter_id uuid references users(id) on delete set null,
+    reporter_ip text,
+    reason text not null,
+    note text,
+    status text not null default 'OPEN',
+    created_at timestamptz not null default now(),
+    resolved_at timestamptz,
+    resolved_by uuid references users(id) on delete set null,
+    constraint file_reports_reason_check check (reason in ('SPAM', 'MALWARE', 'ILLEGAL', 'COPYRIGHT', 'HARASSMENT', 'OTHER')),
+    constraint file_reports_status_check check (status in ('OPEN', 'DISMISSED', 'TAKEN_DOWN'))
+);
+
+create index if not exists idx_file_reports_status_created on file_reports(status, created_at desc);
+create index if not exists idx_file_reports_file on file_reports(file_id);
@@ -0,0 +1,8 @@
+create table if not exists storage_usage_history (
+    user_id uuid not null references users(id) on delete cascade,
+    day date not null,
+    original_bytes bigint not null,
+    deduped_bytes bigint not null,
+    recorded_at timestamptz not null default now(),
+    primary key (user_id, day)
+);
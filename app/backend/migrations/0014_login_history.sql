@@ -0,0 +1,13 @@
+alter table users
+    add column if not exists last_login_at timestamptz;
+
+create table if not exists login_events (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid not null references users(id) on delete cascade,
+    provider text not null,
+    ip text,
+    user_agent text,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists idx_login_events_user_created on login_events(user_id, created_at desc);
@@ -0,0 +1 @@
+create index if not exists idx_files_blob_id on files(blob_id) where is_deleted = false;
@@ -0,0 +1,5 @@
+alter table file_access_log
+    add column if not exists user_id uuid references users(id) on delete set null;
+
+create index if not exists idx_file_access_log_user_at on file_access_log(user_id, accessed_at desc)
+    where user_id is not null;
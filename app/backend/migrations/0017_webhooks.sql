@@ -0,0 +1,28 @@
+create table if not exists webhooks (
+    id uuid primary key default gen_random_uuid(),
+    owner_id uuid not null references users(id) on delete cascade,
+    url text not null,
+    secret text not null,
+    events text[] not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists idx_webhooks_owner on webhooks(owner_id);
+
+create table if not exists webhook_deliveries (
+    id uuid primary key default gen_random_uuid(),
+    webhook_id uuid not null references webhooks(id) on delete cascade,
+    event text not null,
+    payload jsonb not null,
+    status text not null default 'PENDING',
+    attempts int not null default 0,
+    response_status int,
+    last_error text,
+    next_attempt_at timestamptz not null default now(),
+    created_at timestamptz not null default now(),
+    delivered_at timestamptz
+);
+
+create index if not exists idx_webhook_deliveries_due on webhook_deliveries(next_attempt_at)
+    where status = 'PENDING';
+create index if not exists idx_webhook_deliveries_webhook_created on webhook_deliveries(webhook_id, created_at desc);
@@ -0,0 +1,3 @@
+alter table shares
+    add column if not exists notify_on_download boolean not null default false,
+    add column if not exists last_notified_at timestamptz;
@@ -0,0 +1,2 @@
+alter table files
+    add column if not exists description text;
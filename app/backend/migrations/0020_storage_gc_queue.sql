@@ -0,0 +1,8 @@
+-- Storage objects whose delete failed after their rows were removed; retried by
+-- the storage-gc job.
+create table if not exists storage_gc_queue (
+    storage_key text primary key,
+    attempts int not null default 0,
+    last_error text,
+    enqueued_at timestamptz not null default now()
+);
@@ -0,0 +1,4 @@
+alter table file_blobs
+    add column if not exists last_accessed_at timestamptz;
+
+create index if not exists idx_file_blobs_accessed on file_blobs(coalesce(last_accessed_at, created_at));
@@ -0,0 +1,2 @@
+alter table files
+    add column if not exists hidden_from_public boolean not null default false;
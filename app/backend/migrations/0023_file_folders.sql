@@ -0,0 +1,10 @@
+-- Extra folders a file appears in when FOLDER_LABELS is enabled. files.folder_id
+-- stays the file's home folder.
+create table if not exists file_folders (
+    file_id uuid not null references files(id) on delete cascade,
+    folder_id uuid not null references folders(id) on delete cascade,
+    created_at timestamptz not null default now(),
+    primary key (file_id, folder_id)
+);
+
+create index if not exists idx_file_folders_folder on file_folders(folder_id);
@@ -0,0 +1,5 @@
+-- Soft-deleted files now keep their blob reference until the trash is purged.
+-- Recount every blob from its file rows, giving back the references earlier
+-- deletes dropped; the result is the same however often this runs.
+update file_blobs b
+set ref_count = (select count(*) from files f where f.blob_id = b.id);
@@ -0,0 +1,24 @@
+-- 0003 folded shares.file_id into target_type/target_id, but file shares are
+-- still keyed by file_id (folder links live in folder_shares). Bring the
+-- column and its unique constraint back wherever that conversion ran.
+do $$
+begin
+    if not exists (
+        select 1 from information_schema.columns
+        where table_schema = current_schema() and table_name = 'shares' and column_name = 'file_id'
+    ) then
+        alter table shares add column file_id uuid references files(id) on delete cascade;
+        update shares set file_id = target_id where target_type = 'FILE';
+        delete from shares where file_id is null;
+        alter table shares add constraint shares_file_id_unique unique (file_id);
+    end if;
+
+    if exists (
+        select 1 from information_schema.columns
+        where table_schema = current_schema() and table_name = 'shares' and column_name = 'target_id'
+    ) then
+        alter table shares alter column target_type drop not null;
+        alter table shares alter column target_id drop not null;
+    end if;
+end
+$$;
@@ -0,0 +1,6 @@
+-- When each periodic job last ran on any instance, so that replicas run it once
+-- per interval between them.
+create table if not exists job_runs (
+    name text primary key,
+    last_run_at timestamptz not null
+);
@@ -375,6 +375,10 @@ export default function FilesPage() {
               files {
                 id
               }
+              failures {
+                filename
+                message
+              }
             }
           }
         `,
@@ -407,6 +411,11 @@ export default function FilesPage() {
       }
 
       refetch();
+
+      const failures: { filename: string; message: string }[] = result.data?.uploadFiles?.failures ?? [];
+      if (failures.length > 0) {
+        setUploadError(failures.map((failure) => `${failure.filename}: ${failure.message}`).join("; "));
+      }
     } catch (err) {
       setUploadError(err instanceof Error ? err.message : "Upload failed");
     } finally {
@@ -708,6 +717,11 @@ export default function FilesPage() {
             <p className="mt-3 text-xs text-slate-300/70">
               {formatBytes(storageStats?.totalUsageBytes ?? 0)} used of {formatBytes(viewer.quotaBytes ?? 0)} quota.
             </p>
+            {storageStats?.nearingQuota ? (
+              <p className="mt-2 text-xs text-amber-300">
+                Almost out of space: {formatBytes(storageStats.remainingBytes ?? 0)} left before uploads are rejected.
+              </p>
+            ) : null}
           </div>
           <div className="rounded-2xl border border-white/10 bg-white/5 p-5 shadow-surface">
             <div className="flex items-center justify-between text-xs text-slate-400">
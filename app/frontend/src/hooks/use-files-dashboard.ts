@@ -31,6 +31,8 @@ const DASHBOARD_QUERY = /* GraphQL */ `
       originalUsageBytes
       savingsBytes
       savingsPercent
+      remainingBytes
+      nearingQuota
     }
   }
 `;
@@ -65,6 +67,8 @@ interface FilesDashboardResponse {
     originalUsageBytes: number;
     savingsBytes: number;
     savingsPercent: number;
+    remainingBytes: number | null;
+    nearingQuota: boolean;
   };
 }
 